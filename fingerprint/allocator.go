@@ -0,0 +1,209 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+//ErrLibraryFull - No free position is left in the library or the group
+var ErrLibraryFull = errors.New("no free position in the library")
+
+//ErrSlotReserved - The position is held by another enrollment
+var ErrSlotReserved = errors.New("position is reserved")
+
+//ErrSlotOccupied - The position given to ReserveSlotAt or Enroll holds a template already
+var ErrSlotOccupied = errors.New("position holds a template")
+
+//AllocationPolicy - Chooses a position between first and last for which free is true, -1 if there is none
+type AllocationPolicy interface {
+	Next(free func(position int) bool, first int, last int) int
+}
+
+//FirstFit - The lowest free position, the library fills from the start
+type FirstFit struct{}
+
+//Next - See AllocationPolicy
+func (FirstFit) Next(free func(position int) bool, first int, last int) int {
+	for position := first; position <= last; position++ {
+		if free(position) {
+			return position
+		}
+	}
+	return -1
+}
+
+//RoundRobin - The next free position after the one handed out before, wrapping around at the end of the range.
+//Positions freed by deletes are reused last, which spreads the flash writes over the whole range.
+type RoundRobin struct {
+	mu   sync.Mutex
+	last map[int]int
+}
+
+//Next - See AllocationPolicy, ranges are told apart by their first position
+func (r *RoundRobin) Next(free func(position int) bool, first int, last int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		r.last = make(map[int]int)
+	}
+	previous, ok := r.last[first]
+	if !ok || previous < first || previous > last {
+		previous = last
+	}
+	count := last - first + 1
+	for i := 1; i <= count; i++ {
+		position := first + (previous-first+i)%count
+		if free(position) {
+			r.last[first] = position
+			return position
+		}
+	}
+	return -1
+}
+
+//Allocator - Hands out free positions under a policy. A reserved position is skipped by every allocation until
+//it is released, so enrollments running at the same time never pick the same one. Safe for concurrent use.
+type Allocator struct {
+	policy AllocationPolicy
+	mu     sync.Mutex
+	//reserved - The scanner holding each reserved position, nil for reservations made on the Allocator itself
+	reserved map[int]*scanner
+}
+
+//NewAllocator - FirstFit when policy is nil
+func NewAllocator(policy AllocationPolicy) *Allocator {
+	if policy == nil {
+		policy = FirstFit{}
+	}
+	return &Allocator{policy: policy, reserved: make(map[int]*scanner)}
+}
+
+//Allocate - A position free in the index and not reserved, between first and last. With reserve it is held
+//until Release.
+func (a *Allocator) Allocate(index []bool, first int, last int, reserve bool) (int, error) {
+	return a.allocate(nil, index, first, last, reserve)
+}
+
+func (a *Allocator) allocate(holder *scanner, index []bool, first int, last int, reserve bool) (int, error) {
+	if last >= len(index) {
+		last = len(index) - 1
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	position := a.policy.Next(func(p int) bool {
+		_, reserved := a.reserved[p]
+		return !index[p] && !reserved
+	}, first, last)
+	if position < 0 {
+		return -1, ErrLibraryFull
+	}
+	if reserve {
+		a.reserved[position] = holder
+	}
+	return position, nil
+}
+
+//Reserve - Holds the given position, ErrSlotReserved if it is held already
+func (a *Allocator) Reserve(position int) error {
+	return a.claim(nil, nil, position, true)
+}
+
+//claim - Checks a position chosen by the caller before it is written: ErrSlotOccupied when the index shows a
+//template there, ErrSlotReserved while it is reserved, unless holder itself holds it and it is not to be reserved
+//again. With reserve it is held for holder. A nil index skips the occupancy check.
+func (a *Allocator) claim(holder *scanner, index []bool, position int, reserve bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if position < len(index) && index[position] {
+		return fmt.Errorf("%w: %d", ErrSlotOccupied, position)
+	}
+	if owner, reserved := a.reserved[position]; reserved && (reserve || owner != holder) {
+		return fmt.Errorf("%w: %d", ErrSlotReserved, position)
+	}
+	if reserve {
+		a.reserved[position] = holder
+	}
+	return nil
+}
+
+//Release - Frees a reservation, releasing a position that is not reserved does nothing
+func (a *Allocator) Release(position int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.reserved, position)
+}
+
+//Reserved - Whether the position is held
+func (a *Allocator) Reserved(position int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, reserved := a.reserved[position]
+	return reserved
+}
+
+//WithAllocator - Positions for StoreTemplate and AutoEnroll with position -1 and for ReserveSlot come from a,
+//which may be shared by several scanners only if they share the library. Without it FirstFit is used.
+func WithAllocator(a *Allocator) Option {
+	return func(s *scanner) {
+		s.allocator = a
+	}
+}
+
+func (s *scanner) slots() *Allocator {
+	if s.allocator == nil {
+		s.allocator = NewAllocator(nil)
+	}
+	return s.allocator
+}
+
+//allocatePosition - Free position of the whole library for a store with position -1, not reserved
+func (s *scanner) allocatePosition() (int, error) {
+	index, err := s.TemplateIndex()
+	if err != nil {
+		return -1, err
+	}
+	return s.slots().Allocate(index, 0, len(index)-1, false)
+}
+
+//ReserveSlot - Picks a free position of the named group, or of the whole library for "", and holds it until
+//ReleaseSlot. Enroll the finger at the returned position, then release it.
+func (s *scanner) ReserveSlot(group string) (int, error) {
+	first, last := 0, s.getStorageCapacity()-1
+	if group != "" {
+		r, err := s.SlotRange(group)
+		if err != nil {
+			return -1, err
+		}
+		first, last = r.First, r.Last
+	}
+	index, err := s.TemplateIndex()
+	if err != nil {
+		return -1, err
+	}
+	return s.slots().allocate(s, index, first, last, true)
+}
+
+//ReserveSlotAt - Holds a position chosen by the caller, ErrSlotReserved if another enrollment holds it and
+//ErrSlotOccupied if it holds a template
+func (s *scanner) ReserveSlotAt(position int) error {
+	if position < 0 || position >= s.getStorageCapacity() {
+		return errors.New("The given position number is invalid")
+	}
+	index, err := s.TemplateIndex()
+	if err != nil {
+		return err
+	}
+	return s.slots().claim(s, index, position, true)
+}
+
+//checkStorePosition - A position given to StoreTemplate must not be held by another scanner's reservation or one
+//made on the Allocator itself. A template stored there is overwritten, as restoring a backup needs.
+func (s *scanner) checkStorePosition(position int) error {
+	return s.slots().claim(s, nil, position, false)
+}
+
+//ReleaseSlot - Ends a reservation of ReserveSlot or ReserveSlotAt
+func (s *scanner) ReleaseSlot(position int) {
+	s.slots().Release(position)
+}
@@ -0,0 +1,67 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExplicitPositions(t *testing.T) {
+	fingers := map[int]string{1: "thumb"}
+	shared := NewAllocator(nil)
+	a, la := newScripted(libraryModule(fingers), WithAllocator(shared))
+	b, lb := newScripted(libraryModule(fingers), WithAllocator(shared))
+
+	if err := a.ReserveSlotAt(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReserveSlotAt(3); !errors.Is(err, ErrSlotReserved) {
+		t.Errorf("ReserveSlotAt of a reserved position = %v", err)
+	}
+	if _, err := b.StoreTemplate(3, FINGERPRINT_CHARBUFFER1); !errors.Is(err, ErrSlotReserved) {
+		t.Errorf("StoreTemplate at a position reserved by another scanner = %v", err)
+	}
+	if err := a.ReserveSlotAt(1); !errors.Is(err, ErrSlotOccupied) {
+		t.Errorf("ReserveSlotAt of an occupied position = %v", err)
+	}
+	if _, err := Enroll(context.Background(), b, 1, nil); !errors.Is(err, ErrSlotOccupied) {
+		t.Errorf("Enroll at an occupied position = %v", err)
+	}
+	if n := countSent(lb, FINGERPRINT_STORETEMPLATE); n != 0 {
+		t.Errorf("%d templates stored", n)
+	}
+
+	//Restoring a backup overwrites, without reading the index
+	lb.sent = nil
+	if err := ImportTemplate(b, 1, make([]byte, 512)); err != nil {
+		t.Errorf("ImportTemplate over a template = %v", err)
+	}
+	if countSent(lb, FINGERPRINT_STORETEMPLATE) != 1 || countSent(lb, FINGERPRINT_TEMPLATEINDEX) != 0 {
+		t.Errorf("restore sent %x", lb.sent)
+	}
+
+	if position, err := a.StoreTemplate(3, FINGERPRINT_CHARBUFFER1); err != nil || position != 3 {
+		t.Errorf("StoreTemplate at the own reservation = %d, %v", position, err)
+	}
+	if err := shared.Reserve(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.StoreTemplate(5, FINGERPRINT_CHARBUFFER1); !errors.Is(err, ErrSlotReserved) {
+		t.Errorf("StoreTemplate at a position reserved on the allocator = %v", err)
+	}
+	if n := countSent(la, FINGERPRINT_STORETEMPLATE); n != 1 {
+		t.Errorf("%d templates stored", n)
+	}
+}
+
+func TestAutoEnrollLibraryFull(t *testing.T) {
+	s, _ := newScripted(func(command []byte) [][]byte {
+		if command[0] == FINGERPRINT_AUTOENROLL {
+			return [][]byte{{FINGERPRINT_ERROR_LIBRARYFULL, 0, 0}}
+		}
+		return libraryModule(nil)(command)
+	})
+	if _, err := s.AutoEnroll(context.Background(), 4, nil); err != ErrLibraryFull {
+		t.Errorf("AutoEnroll = %v", err)
+	}
+}
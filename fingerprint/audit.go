@@ -0,0 +1,307 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrAuditTampered - A record of the audit log was changed, removed or inserted. Records cut off at the end
+//leave an intact chain, they are only detected by VerifyAuditHead against a head kept elsewhere.
+var ErrAuditTampered = errors.New("audit log hash chain broken")
+
+//auditGenesis - Previous hash of the first record
+var auditGenesis = strings.Repeat("0", sha256.Size*2)
+
+//AuditRecord - One operation, stored as one JSON line. Hash covers the record with an empty hash, including
+//the hash of the previous record, so any change to the log breaks the chain from there on.
+type AuditRecord struct {
+	Seq       uint64         `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Scanner   string         `json:"scanner,omitempty"`
+	Operation string         `json:"op"`
+	Fields    map[string]int `json:"fields,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Prev      string         `json:"prev"`
+	Hash      string         `json:"hash"`
+}
+
+//computeHash - Fields are encoded in key order, so the hash does not depend on map iteration
+func (r *AuditRecord) computeHash() string {
+	unhashed := *r
+	unhashed.Hash = ""
+	b, _ := json.Marshal(&unhashed)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+//AuditHead - Sequence number and hash of the last record. Kept off the host, it reveals records cut off
+//at the end of the log, which the chain alone cannot.
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+//String - The seq:hash form read by ParseAuditHead
+func (h AuditHead) String() string {
+	return fmt.Sprintf("%d:%s", h.Seq, h.Hash)
+}
+
+//ParseAuditHead - Reads a head in the seq:hash form printed by fpctl verify-audit
+func ParseAuditHead(text string) (AuditHead, error) {
+	var h AuditHead
+	i := strings.IndexByte(text, ':')
+	if i < 0 {
+		return h, fmt.Errorf("audit head %q is not seq:hash", text)
+	}
+	seq, err := strconv.ParseUint(text[:i], 10, 64)
+	if err != nil {
+		return h, fmt.Errorf("audit head %q: %v", text, err)
+	}
+	if _, err = hex.DecodeString(text[i+1:]); err != nil || len(text)-i-1 != sha256.Size*2 {
+		return h, fmt.Errorf("audit head %q has no valid hash", text)
+	}
+	h.Seq, h.Hash = seq, text[i+1:]
+	return h, nil
+}
+
+//AuditLog - Append-only log of sensor operations with a hash chain
+type AuditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	last string
+}
+
+//OpenAuditLog - Opens or creates the log at path and continues its chain. The chain is verified first,
+//a log that was tampered with returns ErrAuditTampered instead of being extended.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	head, err := verifyAudit(f, nil)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &AuditLog{f: f, seq: head.Seq, last: head.Hash}, nil
+}
+
+//Head - The last record written to the log, ship it off the host to detect truncation later
+func (a *AuditLog) Head() AuditHead {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AuditHead{Seq: a.seq, Hash: a.last}
+}
+
+//Close - Closes the file
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
+
+//Record - Appends an operation and syncs it to disk, applications may add their own operations
+func (a *AuditLog) Record(scanner string, operation string, fields map[string]int, opErr error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec := AuditRecord{Seq: a.seq + 1, Time: time.Now().UTC(), Scanner: scanner, Operation: operation, Fields: fields, Prev: a.last}
+	if opErr != nil {
+		rec.Error = opErr.Error()
+	}
+	rec.Hash = rec.computeHash()
+
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	if _, err = a.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err = a.f.Sync(); err != nil {
+		return err
+	}
+	a.seq, a.last = rec.Seq, rec.Hash
+	return nil
+}
+
+//readAudit - Parses every line of the log
+func readAudit(r io.Reader, fn func(line int, rec *AuditRecord) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		rec := &AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return fmt.Errorf("audit line %d: %v", line, err)
+		}
+		if err := fn(line, rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+//VerifyAudit - Checks the hash chain of a log written by AuditLog and returns the number of records.
+//A broken chain returns ErrAuditTampered naming the first record that does not fit.
+//Records removed from the end are not detected, see VerifyAuditHead.
+func VerifyAudit(r io.Reader) (int, error) {
+	head, err := verifyAudit(r, nil)
+	return int(head.Seq), err
+}
+
+//VerifyAuditHead - VerifyAudit, and the log has to contain the record of a head taken earlier. Returns the
+//head of the log, or of its last intact record. Records written after the given head are fine, a log ending
+//before it was truncated.
+func VerifyAuditHead(r io.Reader, head AuditHead) (AuditHead, error) {
+	var found string
+	last, err := verifyAudit(r, func(rec *AuditRecord) {
+		if rec.Seq == head.Seq {
+			found = rec.Hash
+		}
+	})
+	switch {
+	case err != nil:
+		return last, err
+	case head.Seq == 0:
+		return last, nil
+	case last.Seq < head.Seq:
+		return last, fmt.Errorf("%w: log ends at record %d, records up to %d were removed", ErrAuditTampered, last.Seq, head.Seq)
+	case found != head.Hash:
+		return last, fmt.Errorf("%w: record %d does not match the head", ErrAuditTampered, head.Seq)
+	}
+	return last, nil
+}
+
+//verifyAudit - Checks the chain and returns its head, the sequence numbers count the records from 1.
+//visit is optional and sees every intact record.
+func verifyAudit(r io.Reader, visit func(rec *AuditRecord)) (AuditHead, error) {
+	head := AuditHead{Hash: auditGenesis}
+	err := readAudit(r, func(line int, rec *AuditRecord) error {
+		switch {
+		case rec.Prev != head.Hash:
+			return fmt.Errorf("%w: line %d does not follow the previous record", ErrAuditTampered, line)
+		case rec.Seq != head.Seq+1:
+			return fmt.Errorf("%w: line %d has sequence %d, expected %d", ErrAuditTampered, line, rec.Seq, head.Seq+1)
+		case rec.computeHash() != rec.Hash:
+			return fmt.Errorf("%w: line %d was modified", ErrAuditTampered, line)
+		}
+		if visit != nil {
+			visit(rec)
+		}
+		head = AuditHead{Seq: rec.Seq, Hash: rec.Hash}
+		return nil
+	})
+	return head, err
+}
+
+//WithAudit - Records enroll, delete, clear database, password and parameter changes and identification
+//results of the scanner in the audit log, under the given scanner name
+func WithAudit(a *AuditLog, name string) Option {
+	return func(s *scanner) {
+		s.audit = a
+		s.auditName = name
+	}
+}
+
+//record - Adds the operation to the audit log if the scanner has one
+func (s *scanner) record(operation string, fields map[string]int, opErr error) {
+	if s.audit == nil {
+		return
+	}
+	if err := s.audit.Record(s.auditName, operation, fields, opErr); err != nil {
+		log.Println("Unable to write audit log:", err.Error())
+	}
+}
+
+//auditBatcher - Scanners recording a composite operation as one record instead of one per command
+type auditBatcher interface {
+	auditBatch(operation string, fn func() (map[string]int, error)) error
+}
+
+//auditBatch - Runs fn without recording its commands, then records the operation with the fields fn returns
+func (s *scanner) auditBatch(operation string, fn func() (map[string]int, error)) error {
+	audit := s.audit
+	s.audit = nil
+	fields, err := fn()
+	s.audit = audit
+	s.record(operation, fields, err)
+	return err
+}
+
+func searchFields(result *SearchResult) map[string]int {
+	if result == nil {
+		return nil
+	}
+	return map[string]int{"position": result.PositionNumber, "score": result.AccuracyScore}
+}
+
+//Operations recorded in the audit log, the commands themselves are sent by the unexported methods
+
+//StoreTemplate - Stores the template of the char buffer at the position, -1 picks a free one. A given position
+//has to be empty and not reserved through another scanner, see ReserveSlotAt.
+func (s *scanner) StoreTemplate(Position int, CharBufferNo int) (int, error) {
+	stored, err := s.storeTemplate(Position, CharBufferNo)
+	s.record("store_template", map[string]int{"position": stored, "char_buffer": CharBufferNo}, err)
+	return stored, err
+}
+
+//DeleteFingerprint - Deletes count templates starting at the position
+func (s *scanner) DeleteFingerprint(position int, count int) (bool, error) {
+	deleted, err := s.deleteFingerprint(position, count)
+	s.record("delete_template", map[string]int{"position": position, "count": count}, err)
+	return deleted, err
+}
+
+//ClearDatabase - Deletes every template
+func (s *scanner) ClearDatabase() error {
+	err := s.clearDatabase()
+	s.record("clear_database", nil, err)
+	if err == nil {
+		s.observeLibrary(make([]bool, s.getStorageCapacity()))
+	}
+	return err
+}
+
+//SearchTemplate - Searches count templates from startPos for the char buffer, position -1 when none matches
+func (s *scanner) SearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error) {
+	result, err := s.searchTemplate(charBufferNo, startPos, count)
+	s.record("search", searchFields(result), err)
+	return result, err
+}
+
+//CompareCharacteristics - Compares both char buffers, score 0 when they do not match
+func (s *scanner) CompareCharacteristics() (int, error) {
+	score, err := s.compareCharacteristics()
+	s.record("compare", map[string]int{"score": score}, err)
+	return score, err
+}
+
+//SetPassword - Sets the module password, the password used by the host changes once the module confirmed it.
+//The password itself is not recorded.
+func (s *scanner) SetPassword(password uint) error {
+	err := s.setPassword(password)
+	s.record("set_password", nil, err)
+	return err
+}
+
+//SetSystemParameter - Baud rate (content N for N*BaudRateUnit, 1-12), security level (1-5) or package size (0-3 for 32-256 bytes)
+func (s *scanner) SetSystemParameter(parameterNo int, content int) error {
+	err := s.setSystemParameter(parameterNo, content)
+	s.record("set_parameter", map[string]int{"parameter": parameterNo, "content": content}, err)
+	return err
+}
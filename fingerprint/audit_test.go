@@ -0,0 +1,128 @@
+package fingerprint
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//writeAudit - Log with a record for every audited command and one written after reopening it
+func writeAudit(t *testing.T) (string, AuditHead) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newScripted(func(command []byte) [][]byte {
+		if command[0] == FINGERPRINT_SEARCHTEMPLATE {
+			return [][]byte{{FINGERPRINT_OK, 0, 5, 0, 77}}
+		}
+		return [][]byte{{FINGERPRINT_OK}}
+	}, WithAudit(a, "door"))
+	s.StoreTemplate(3, 1)
+	s.SearchTemplate(1, 0, -1)
+	s.DeleteFingerprint(3, 1)
+	s.SetPassword(99357415)
+	s.SetSystemParameter(FINGERPRINT_SETSYSTEMPARAMETER_SECURITY_LEVEL, 3)
+	s.ClearDatabase()
+	a.Close()
+
+	if a, err = OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if err = a.Record("door", "security_lockout", map[string]int{"failures": 5}, nil); err != nil {
+		t.Fatal(err)
+	}
+	return path, a.Head()
+}
+
+func readLines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestAuditChain(t *testing.T) {
+	path, head := writeAudit(t)
+	lines := readLines(t, path)
+	log := strings.Join(lines, "")
+	if strings.Contains(log, "99357415") {
+		t.Error("password written to the audit log")
+	}
+	if n, err := VerifyAudit(strings.NewReader(log)); err != nil || n != 7 {
+		t.Fatalf("%d records, %v", n, err)
+	}
+	if head.Seq != 7 {
+		t.Errorf("head %s", head)
+	}
+	last, err := VerifyAuditHead(strings.NewReader(log), head)
+	if err != nil || last != head {
+		t.Errorf("head %s, %v", last, err)
+	}
+
+	parsed, err := ParseAuditHead(head.String())
+	if err != nil || parsed != head {
+		t.Errorf("parsed %s, %v", parsed, err)
+	}
+	for _, text := range []string{"7", "x:" + head.Hash, "7:abc"} {
+		if _, err := ParseAuditHead(text); err == nil {
+			t.Errorf("%q parsed", text)
+		}
+	}
+}
+
+func TestAuditTampered(t *testing.T) {
+	path, head := writeAudit(t)
+	lines := readLines(t, path)
+	tests := map[string]string{
+		"modified": strings.Replace(strings.Join(lines, ""), `"position":3`, `"position":4`, 1),
+		"removed":  strings.Join(append(append([]string(nil), lines[:2]...), lines[3:]...), ""),
+		"inserted": strings.Join(append(append(append([]string(nil), lines[:3]...), lines[1]), lines[3:]...), ""),
+	}
+	for name, log := range tests {
+		if _, err := VerifyAudit(strings.NewReader(log)); !errors.Is(err, ErrAuditTampered) {
+			t.Errorf("%s: %v", name, err)
+		}
+		if _, err := VerifyAuditHead(strings.NewReader(log), head); !errors.Is(err, ErrAuditTampered) {
+			t.Errorf("%s against the head: %v", name, err)
+		}
+	}
+}
+
+func TestAuditTruncated(t *testing.T) {
+	path, head := writeAudit(t)
+	truncated := strings.Join(readLines(t, path)[:5], "")
+
+	//The chain alone cannot tell
+	if n, err := VerifyAudit(strings.NewReader(truncated)); err != nil || n != 5 {
+		t.Fatalf("%d records, %v", n, err)
+	}
+	last, err := VerifyAuditHead(strings.NewReader(truncated), head)
+	if !errors.Is(err, ErrAuditTampered) || last.Seq != 5 {
+		t.Errorf("head %s, %v", last, err)
+	}
+	//An earlier head is still contained
+	if _, err = VerifyAuditHead(strings.NewReader(truncated), last); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenAuditLogTampered(t *testing.T) {
+	path, _ := writeAudit(t)
+	log := strings.Join(readLines(t, path), "")
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(log, `"count":1`, `"count":2`, 1)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditLog(path); !errors.Is(err, ErrAuditTampered) {
+		t.Fatalf("tampered log opened: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); !bytes.Contains(b, []byte(`"count":2`)) || bytes.Count(b, []byte("\n")) != 7 {
+		t.Error("tampered log was changed")
+	}
+}
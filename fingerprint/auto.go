@@ -0,0 +1,234 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+//AutoEnroller - Modules running enroll and identify on their own, reporting each step with a further ack packet
+type AutoEnroller interface {
+	AutoEnroll(ctx context.Context, position int, progress func(EnrollStep)) (int, error)
+	AutoIdentify(ctx context.Context, progress func(IdentifyStep)) (*SearchResult, error)
+}
+
+//IdentifyStep - Stages reported while identifying a finger
+type IdentifyStep int
+
+const (
+	//IdentifyWaitFinger - Waiting for the finger to be placed
+	IdentifyWaitFinger IdentifyStep = iota
+	//IdentifySearch - Searching the library
+	IdentifySearch
+	//IdentifyDone - Search finished
+	IdentifyDone
+)
+
+func (i IdentifyStep) String() string {
+	switch i {
+	case IdentifyWaitFinger:
+		return "wait_finger"
+	case IdentifySearch:
+		return "search"
+	case IdentifyDone:
+		return "done"
+	}
+	return "unknown"
+}
+
+//Steps reported in the ack packets of AutoEnroll and AutoIdentify
+const (
+	autoStepLegality   = 0x00
+	autoStepImage      = 0x01
+	autoStepFeature    = 0x02
+	autoStepLeave      = 0x03
+	autoStepMerge      = 0x04
+	autoStepDuplicate  = 0x05
+	autoStepStore      = 0x06
+	autoStepIdentified = 0x05
+)
+
+//autoEnrollCaptures - Times the finger is captured, matches the host driven Enroll
+const autoEnrollCaptures = 2
+
+//cancelDrainTimeout - How long acks of a cancelled workflow may still arrive
+const cancelDrainTimeout = 500 * time.Millisecond
+
+func getPayloadForAutoEnroll(position int, captures int) []byte {
+	//Flags 0: LED on, status after every step, no overwrite, no duplicates, finger has to leave in between
+	return []byte{FINGERPRINT_AUTOENROLL, byte(position >> 8), byte(position), byte(captures), 0, 0}
+}
+
+func getPayloadForAutoIdentify(securityLevel int, startPos int, count int) []byte {
+	return []byte{FINGERPRINT_AUTOIDENTIFY, byte(securityLevel), byte(startPos >> 8), byte(startPos), byte(count >> 8), byte(count), 0, 0, 1}
+}
+
+//autoError - Confirmation codes ending an on-device workflow
+func autoError(tp *ThumbPacket) error {
+	switch tp.PayLoad[0] {
+	case FINGERPRINT_ERROR_ALREADYENROLLED:
+		return ErrAlreadyEnrolled
+	case FINGERPRINT_ERROR_NOTEMPLATEFOUND, FINGERPRINT_ERROR_NOTMATCHING:
+		return ErrNoMatch
+	case FINGERPRINT_ERROR_FINGERTIMEOUT:
+		return context.DeadlineExceeded
+	case FINGERPRINT_ERROR_LIBRARYFULL:
+		return ErrLibraryFull
+	}
+	_, _, errDesc := anyCommonErrors(tp)
+	return errDesc
+}
+
+//nextAutoAck - Reads the next ack of a running workflow, the workflow is cancelled on the module when ctx is done
+func (s *scanner) nextAutoAck(ctx context.Context, minLength int) (*ThumbPacket, error) {
+	tp, err := s.readPacketContext(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.cancelAuto()
+		}
+		return nil, err
+	}
+	return tp, checkAutoAck(tp, minLength)
+}
+
+//checkAutoAck - Acks of a successful step carry the step and its values after the confirmation code
+func checkAutoAck(tp *ThumbPacket, minLength int) error {
+	if tp.PacketType != FINGERPRINT_ACKPACKET || len(tp.PayLoad) == 0 {
+		return errors.New("the received packet is no ack packet")
+	}
+	if tp.PayLoad[0] == FINGERPRINT_OK && len(tp.PayLoad) < minLength {
+		return errShortPayload
+	}
+	return nil
+}
+
+//cancelAuto - Stops a running workflow and drops the acks still on their way
+func (s *scanner) cancelAuto() {
+	if _, err := s.writePacket(FINGERPRINT_COMMANDPACKET, []byte{FINGERPRINT_CANCEL}); err != nil {
+		log.Println("Unable to cancel the running command:", err.Error())
+	}
+	s.drain(cancelDrainTimeout)
+}
+
+//AutoEnroll - Enrolls a finger with the AutoEnroll instruction, position -1 picks a free one, a given one is
+//checked against reservations like in StoreTemplate.
+//Modules without the instruction return ErrNotSupported, Enroll falls back to the host driven steps then.
+func (s *scanner) AutoEnroll(ctx context.Context, position int, progress func(EnrollStep)) (int, error) {
+	enrolled, err := s.autoEnroll(ctx, position, progress)
+	if err != ErrNotSupported {
+		s.record("auto_enroll", map[string]int{"position": enrolled}, err)
+	}
+	return enrolled, err
+}
+
+func (s *scanner) autoEnroll(ctx context.Context, position int, progress func(EnrollStep)) (int, error) {
+	report := func(step EnrollStep) {
+		if progress != nil {
+			progress(step)
+		}
+	}
+
+	//Enroll falls back to the host driven steps, which pick the position again
+	if s.unsupported[FINGERPRINT_AUTOENROLL] {
+		return -1, ErrNotSupported
+	}
+	if position == -1 {
+		var err error
+		if position, err = s.allocatePosition(); err != nil {
+			return -1, err
+		}
+	} else if position < 0 || position >= s.getStorageCapacity() {
+		return -1, errors.New("The given position number is invalid")
+	} else if err := s.checkStorePosition(position); err != nil {
+		return -1, err
+	}
+
+	//The legality check is answered at once, so the probe timeout applies to it only
+	tp, err := s.optionalCommand(FINGERPRINT_AUTOENROLL, getPayloadForAutoEnroll(position, autoEnrollCaptures))
+	if err == nil {
+		err = checkAutoAck(tp, 3)
+	}
+	if err != nil {
+		return -1, err
+	}
+	for {
+		if tp.PayLoad[0] != FINGERPRINT_OK {
+			return -1, autoError(tp)
+		}
+
+		step, entry := tp.PayLoad[1], int(tp.PayLoad[2])
+		switch {
+		case step == autoStepLegality:
+			report(EnrollWaitFirstFinger)
+		case step == autoStepFeature && entry < autoEnrollCaptures:
+			report(EnrollRemoveFinger)
+		case step == autoStepFeature:
+			report(EnrollCompare)
+		case step == autoStepLeave:
+			report(EnrollWaitSecondFinger)
+		case step == autoStepMerge:
+			report(EnrollCheckDuplicate)
+		case step == autoStepDuplicate:
+			report(EnrollStore)
+		case step == autoStepStore:
+			report(EnrollDone)
+			return position, nil
+		}
+
+		if tp, err = s.nextAutoAck(ctx, 3); err != nil {
+			return -1, err
+		}
+	}
+}
+
+//AutoIdentify - Captures a finger and searches the whole library with the AutoIdentify instruction.
+//Modules without the instruction return ErrNotSupported, Identify falls back to the host driven steps then.
+func (s *scanner) AutoIdentify(ctx context.Context, progress func(IdentifyStep)) (*SearchResult, error) {
+	result, err := s.autoIdentify(ctx, progress)
+	if err != ErrNotSupported {
+		s.record("auto_identify", searchFields(result), err)
+	}
+	return result, err
+}
+
+func (s *scanner) autoIdentify(ctx context.Context, progress func(IdentifyStep)) (*SearchResult, error) {
+	report := func(step IdentifyStep) {
+		if progress != nil {
+			progress(step)
+		}
+	}
+
+	payLoad := getPayloadForAutoIdentify(int(s.param.SecurityLevel), 0, s.getStorageCapacity())
+	tp, err := s.optionalCommand(FINGERPRINT_AUTOIDENTIFY, payLoad)
+	if err == nil {
+		err = checkAutoAck(tp, 6)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if tp.PayLoad[0] != FINGERPRINT_OK {
+			err = autoError(tp)
+			if err == ErrNoMatch {
+				report(IdentifyDone)
+				return &SearchResult{-1, -1}, err
+			}
+			return nil, err
+		}
+
+		switch tp.PayLoad[1] {
+		case autoStepLegality:
+			report(IdentifyWaitFinger)
+		case autoStepImage:
+			report(IdentifySearch)
+		case autoStepIdentified:
+			report(IdentifyDone)
+			return &SearchResult{PositionNumber: be16(tp.PayLoad[2:]), AccuracyScore: be16(tp.PayLoad[4:])}, nil
+		}
+
+		if tp, err = s.nextAutoAck(ctx, 6); err != nil {
+			return nil, err
+		}
+	}
+}
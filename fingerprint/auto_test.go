@@ -0,0 +1,106 @@
+package fingerprint
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//autoModule - Runs AutoEnroll with the given acks, positions 0-2 are used
+func autoModule(acks ...[]byte) func(command []byte) [][]byte {
+	return func(command []byte) [][]byte {
+		switch command[0] {
+		case FINGERPRINT_AUTOENROLL:
+			return acks
+		case FINGERPRINT_TEMPLATEINDEX:
+			return [][]byte{append([]byte{FINGERPRINT_OK, 0x07}, make([]byte, 31)...)}
+		}
+		return ack(command)
+	}
+}
+
+func TestAutoEnrollSteps(t *testing.T) {
+	s, l := newScripted(autoModule(
+		[]byte{FINGERPRINT_OK, autoStepLegality, 0},
+		[]byte{FINGERPRINT_OK, autoStepImage, 1}, []byte{FINGERPRINT_OK, autoStepFeature, 1},
+		[]byte{FINGERPRINT_OK, autoStepLeave, 1},
+		[]byte{FINGERPRINT_OK, autoStepImage, 2}, []byte{FINGERPRINT_OK, autoStepFeature, 2},
+		[]byte{FINGERPRINT_OK, autoStepMerge, 0xF0}, []byte{FINGERPRINT_OK, autoStepDuplicate, 0xF1},
+		[]byte{FINGERPRINT_OK, autoStepStore, 0xF2}))
+	var steps []EnrollStep
+	position, err := s.AutoEnroll(context.Background(), -1, func(step EnrollStep) { steps = append(steps, step) })
+	if err != nil || position != 3 {
+		t.Fatalf("AutoEnroll = %d, %v", position, err)
+	}
+	want := []EnrollStep{EnrollWaitFirstFinger, EnrollRemoveFinger, EnrollWaitSecondFinger, EnrollCompare,
+		EnrollCheckDuplicate, EnrollStore, EnrollDone}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps %v, want %v", steps, want)
+	}
+	if command := l.sent[len(l.sent)-1]; command[0] != FINGERPRINT_AUTOENROLL || command[1] != 0 || command[2] != 3 {
+		t.Errorf("sent %x", command)
+	}
+}
+
+func TestAutoEnrollUnsupported(t *testing.T) {
+	shortProbes(t)
+	s, l := newScripted(autoModule([]byte{FINGERPRINT_ERROR_COMMUNICATION}))
+	if _, err := s.AutoEnroll(context.Background(), -1, nil); err != ErrNotSupported {
+		t.Fatalf("AutoEnroll = %v", err)
+	}
+	//Known to be missing, nothing is sent, not even the index read
+	l.sent = nil
+	if _, err := s.AutoEnroll(context.Background(), -1, nil); err != ErrNotSupported || len(l.sent) != 0 {
+		t.Errorf("AutoEnroll = %v, sent %x", err, l.sent)
+	}
+}
+
+func TestAutoEnrollTimeout(t *testing.T) {
+	//The module gives up waiting for the second finger
+	s, _ := newScripted(autoModule([]byte{FINGERPRINT_OK, autoStepLegality, 0},
+		[]byte{FINGERPRINT_OK, autoStepImage, 1}, []byte{FINGERPRINT_OK, autoStepFeature, 1},
+		[]byte{FINGERPRINT_ERROR_FINGERTIMEOUT, autoStepImage, 2}))
+	if _, err := s.AutoEnroll(context.Background(), 4, nil); err != context.DeadlineExceeded {
+		t.Errorf("finger timeout on the module = %v", err)
+	}
+
+	//The module stops answering, the workflow is cancelled when the deadline passes
+	s, l := newScripted(autoModule([]byte{FINGERPRINT_OK, autoStepLegality, 0}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.AutoEnroll(ctx, 4, nil); err != context.DeadlineExceeded {
+		t.Errorf("silent module = %v", err)
+	}
+	if n := countSent(l, FINGERPRINT_CANCEL); n != 1 {
+		t.Errorf("%d cancels sent", n)
+	}
+}
+
+func TestAutoEnrollCancel(t *testing.T) {
+	enroll := autoModule([]byte{FINGERPRINT_OK, autoStepLegality, 0})
+	s, l := newScripted(func(command []byte) [][]byte {
+		if command[0] == FINGERPRINT_CANCEL {
+			//A step ack still on its way, then the answer to the cancel
+			return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION, autoStepImage, 1}, {FINGERPRINT_ERROR_COMMUNICATION}}
+		}
+		return enroll(command)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := s.AutoEnroll(ctx, 4, func(step EnrollStep) {
+		if step == EnrollWaitFirstFinger {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("AutoEnroll = %v", err)
+	}
+	if command := l.sent[len(l.sent)-1]; command[0] != FINGERPRINT_CANCEL {
+		t.Errorf("last sent %x", command)
+	}
+	//The acks after the cancel are dropped, the next command gets its own response
+	if !s.ConvertImage(FINGERPRINT_CHARBUFFER1) {
+		t.Error("command after the cancel failed")
+	}
+}
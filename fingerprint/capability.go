@@ -0,0 +1,82 @@
+package fingerprint
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrNotSupported - The module does not implement the command, e.g. LED control on an R307
+var ErrNotSupported = errors.New("the module does not support this command")
+
+//ErrTimeout - The sensor did not answer in time
+var ErrTimeout = errors.New("no response from the sensor")
+
+//probeTimeout - How long to wait for the answer to a command the module may not know
+var probeTimeout = 2 * time.Second
+
+//lateReplyTimeout - How long an answer may still arrive after the probe gave up on it
+var lateReplyTimeout = 500 * time.Millisecond
+
+//optionalCommand - Sends an instruction only newer modules implement. An instruction the module never answered
+//is sent once more when it is rejected or not answered, as that may be noise on the line. Modules failing both
+//are remembered, later calls fail with ErrNotSupported without touching the sensor. Once the module answered
+//the instruction, failures are returned as ErrCommunication or ErrTimeout like those of any other command.
+func (s *scanner) optionalCommand(instruction int, payLoad []byte) (*ThumbPacket, error) {
+	if s.unsupported[instruction] {
+		return nil, ErrNotSupported
+	}
+
+	tp, err := s.probe(payLoad)
+	if (err == ErrCommunication || err == ErrTimeout) && !s.answered[instruction] {
+		tp, err = s.probe(payLoad)
+		if err == ErrCommunication || err == ErrTimeout {
+			if s.unsupported == nil {
+				s.unsupported = make(map[int]bool)
+			}
+			s.unsupported[instruction] = true
+			return nil, ErrNotSupported
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.answered == nil {
+		s.answered = make(map[int]bool)
+	}
+	s.answered[instruction] = true
+	return tp, nil
+}
+
+//probe - Sends the command and waits probeTimeout for the response. Older modules answer instructions they do
+//not know with a receive error, returned as ErrCommunication.
+func (s *scanner) probe(payLoad []byte) (*ThumbPacket, error) {
+	if _, err := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad); err != nil {
+		return nil, err
+	}
+
+	s.responseTimeout = probeTimeout
+	tp, err := s.readPacket()
+	s.responseTimeout = 0
+
+	if err == ErrTimeout {
+		s.drain(lateReplyTimeout)
+		return nil, err
+	}
+	if err == nil && tp.PacketType == FINGERPRINT_ACKPACKET && len(tp.PayLoad) > 0 && tp.PayLoad[0] == FINGERPRINT_ERROR_COMMUNICATION {
+		return nil, ErrCommunication
+	}
+	return tp, err
+}
+
+//drain - Drops packets arriving until the sensor was silent for timeout, they would be taken for the
+//response to the next command
+func (s *scanner) drain(timeout time.Duration) {
+	s.responseTimeout = timeout
+	for {
+		if _, err := s.readPacket(); err != nil {
+			break
+		}
+	}
+	s.responseTimeout = 0
+	s.rxBuffer = nil
+}
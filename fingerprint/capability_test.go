@@ -0,0 +1,129 @@
+package fingerprint
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+//shortProbes - Lets probes time out quickly for the duration of the test
+func shortProbes(t *testing.T) {
+	probe, late := probeTimeout, lateReplyTimeout
+	probeTimeout, lateReplyTimeout = 30*time.Millisecond, 60*time.Millisecond
+	t.Cleanup(func() { probeTimeout, lateReplyTimeout = probe, late })
+}
+
+//ledModule - Answers the LED instruction with the codes in turn, FINGERPRINT_OK after them and -1 not at all
+func ledModule(codes ...int) func([]byte) [][]byte {
+	return func(command []byte) [][]byte {
+		if command[0] != FINGERPRINT_AURALEDCONFIG {
+			return ack(command)
+		}
+		if len(codes) == 0 {
+			return ack(command)
+		}
+		code := codes[0]
+		codes = codes[1:]
+		if code < 0 {
+			return nil
+		}
+		return [][]byte{{byte(code)}}
+	}
+}
+
+func countSent(l *scriptLink, instruction byte) int {
+	n := 0
+	for _, command := range l.sent {
+		if command[0] == instruction {
+			n++
+		}
+	}
+	return n
+}
+
+func TestOptionalCommandUnsupported(t *testing.T) {
+	shortProbes(t)
+	for name, code := range map[string]int{"Rejected": FINGERPRINT_ERROR_COMMUNICATION, "Silent": -1} {
+		s, l := newScripted(ledModule(code, code, FINGERPRINT_OK))
+		if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != ErrNotSupported {
+			t.Errorf("%s: %v", name, err)
+		}
+		if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != ErrNotSupported {
+			t.Errorf("%s: second call %v", name, err)
+		}
+		if n := countSent(l, FINGERPRINT_AURALEDCONFIG); n != 2 {
+			t.Errorf("%s: sent %d times", name, n)
+		}
+	}
+}
+
+func TestOptionalCommandGlitch(t *testing.T) {
+	shortProbes(t)
+	//Noise on the first probe
+	s, l := newScripted(ledModule(FINGERPRINT_ERROR_COMMUNICATION, FINGERPRINT_OK, -1, FINGERPRINT_ERROR_COMMUNICATION))
+	if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	//The module knows the instruction now, failures are passed on and not remembered
+	if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != ErrTimeout {
+		t.Errorf("silent module: %v", err)
+	}
+	if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != ErrCommunication {
+		t.Errorf("receive error: %v", err)
+	}
+	if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != nil {
+		t.Errorf("after the glitches: %v", err)
+	}
+	if n := countSent(l, FINGERPRINT_AURALEDCONFIG); n != 5 {
+		t.Errorf("sent %d times", n)
+	}
+}
+
+//TestOptionalCommandLateReply - An answer arriving after the probe gave up is not taken for the next response
+func TestOptionalCommandLateReply(t *testing.T) {
+	shortProbes(t)
+	s, l := newScripted(func(command []byte) [][]byte {
+		if command[0] == FINGERPRINT_GETSYSTEMPARAMETERS {
+			return [][]byte{{FINGERPRINT_OK, 0, 0, 0, 0, 0, 0xC8, 0, 3, 0xFF, 0xFF, 0xFF, 0xFF, 0, 2, 0, 6}}
+		}
+		return ack(command)
+	})
+	if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	l.hold = 45 * time.Millisecond
+	if err := s.SetLED(LEDBlue, LEDOn, 0, 0); err != ErrTimeout {
+		t.Fatalf("late answer: %v", err)
+	}
+	l.hold = 0
+	p, err := s.GetSystemParameters()
+	if err != nil || p.StorageCapacity != 0xC8 {
+		t.Errorf("parameters %+v, %v", p, err)
+	}
+}
+
+//TestOptionalCommandRetry - The retry policy sees receive errors of instructions the module answered before
+func TestOptionalCommandRetry(t *testing.T) {
+	shortProbes(t)
+	searches := 0
+	s, l := newScripted(func(command []byte) [][]byte {
+		if command[0] != FINGERPRINT_FASTSEARCH {
+			return ack(command)
+		}
+		if searches++; searches == 2 {
+			return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION}}
+		}
+		return [][]byte{{FINGERPRINT_OK, 0x00, 0x05, 0x00, 0x78}}
+	}, WithRetry(RetryPolicy{Backoff: time.Millisecond}))
+
+	for i := 0; i < 2; i++ {
+		result, err := s.FastSearchTemplate(FINGERPRINT_CHARBUFFER1, 0, 10)
+		if err != nil || result.PositionNumber != 5 {
+			t.Fatalf("search %d = %+v, %v", i, result, err)
+		}
+	}
+	if !bytes.Equal(l.sent[len(l.sent)-1], getPayloadForFastSearch(FINGERPRINT_CHARBUFFER1, 0, 10)) || searches != 3 {
+		t.Errorf("%d searches", searches)
+	}
+}
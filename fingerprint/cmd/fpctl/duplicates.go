@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//runDuplicates - Lists positions holding the same finger, e.g. people enrolled twice under different IDs
+func runDuplicates(args []string) error {
+	flags := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	scanner := addScannerFlags(flags)
+	asJSON := flags.Bool("json", false, "print the pairs as JSON")
+	flags.Parse(args)
+
+	s, _, err := scanner.open()
+	if err != nil {
+		return err
+	}
+	defer s.Release()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		stop()
+	}()
+
+	pairs, err := fingerprint.FindDuplicates(ctx, s, func(done int, total int) {
+		fmt.Fprintf(os.Stderr, "\rchecked %d of %d templates", done, total)
+	})
+	fmt.Fprintln(os.Stderr)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(pairs)
+	} else {
+		for _, pair := range pairs {
+			fmt.Println(pair)
+		}
+		fmt.Printf("%d duplicate pairs\n", len(pairs))
+	}
+	return err
+}
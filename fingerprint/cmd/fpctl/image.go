@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//runImage - Captures a finger image, or converts a raw dump, and writes it with its metadata sidecar
+func runImage(args []string) error {
+	flags := flag.NewFlagSet("image", flag.ExitOnError)
+	scanner := addScannerFlags(flags)
+	input := flags.String("in", "", "convert a raw image read earlier instead of capturing one")
+	width := flags.Int("width", 0, "image width, taken from the module or the image size when 0")
+	height := flags.Int("height", 0, "image height, taken from the module or the image size when 0")
+	format := flags.String("format", fingerprint.ImagePNG, "png, bmp, pgm or raw")
+	output := flags.String("o", "", "output file, finger-<time>.<format> by default")
+	name := flags.String("name", "", "scanner name written to the sidecar")
+	timeout := flags.Duration("timeout", 10*time.Second, "time to wait for a finger")
+	flags.Parse(args)
+
+	meta := &fingerprint.ImageMetadata{Scanner: *name, Time: time.Now().UTC(), Format: *format}
+	var raw []byte
+	var err error
+	switch {
+	case *input != "":
+		raw, err = ioutil.ReadFile(*input)
+	case scanner.given():
+		raw, err = captureImage(scanner, *timeout, meta)
+	default:
+		return fmt.Errorf("one of -serial, -network or -in is needed")
+	}
+	if err != nil {
+		return err
+	}
+	if *width > 0 && *height > 0 {
+		meta.Width, meta.Height = *width, *height
+	}
+
+	img, err := fingerprint.DecodeImage(raw, meta.Width, meta.Height)
+	if err != nil {
+		return err
+	}
+	meta.Width, meta.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	meta.Quality = fingerprint.AssessImage(img)
+
+	path := *output
+	if path == "" {
+		path = "finger-" + meta.Time.Format("20060102-150405") + "." + *format
+	}
+	if err := writeFile(path, func(f *os.File) error { return fingerprint.WriteImage(f, img, *format) }); err != nil {
+		return err
+	}
+	sidecar := strings.TrimSuffix(path, "."+*format) + ".json"
+	if err := writeFile(sidecar, func(f *os.File) error { return fingerprint.WriteImageMetadata(f, meta) }); err != nil {
+		return err
+	}
+	fmt.Printf("%s %dx%d, metadata in %s\nquality %v\n", path, meta.Width, meta.Height, sidecar, meta.Quality)
+	return nil
+}
+
+//captureImage - Waits for a finger, the image size and model are taken from the product info when the module has it
+func captureImage(scanner *scannerFlags, timeout time.Duration, meta *fingerprint.ImageMetadata) ([]byte, error) {
+	s, name, err := scanner.open()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Release()
+	if meta.Scanner == "" {
+		meta.Scanner = name
+	}
+
+	if info, err := s.ProductInfo(); err == nil {
+		meta.Model, meta.Sensor = info.Model, info.SensorType
+		meta.Width, meta.Height = info.ImageWidth, info.ImageHeight
+	}
+
+	fmt.Fprintln(os.Stderr, "place a finger on the sensor")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fingerprint.CaptureImage(ctx, s)
+}
+
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
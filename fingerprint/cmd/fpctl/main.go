@@ -0,0 +1,138 @@
+//fpctl is the command line tool for fingerprint scanners and their traffic
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/protocol"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"decode":       {"decode [-trace file] [hex ...]  decode packets given as arguments, on stdin or recorded with WithTrace", runDecode},
+	"duplicates":   {"duplicates [-serial port | -network addr] [-json]  find fingers stored at more than one position", runDuplicates},
+	"image":        {"image [-serial port | -network addr | -in raw] [-format png|bmp|pgm|raw] [-o file]  capture or convert a finger image for review", runImage},
+	"match":        {"match [-width w -height h] [-v] image image  compare two raw or PNG finger images on the host", runMatch},
+	"verify-audit": {"verify-audit [-head seq:hash] file  check the hash chain of an audit log written by fpd or WithAudit", runVerifyAudit},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fpctl <command> [arguments]")
+	for _, c := range commands {
+		fmt.Fprintln(os.Stderr, "  fpctl "+c.usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	c, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := c.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "fpctl:", err)
+		os.Exit(1)
+	}
+}
+
+func runDecode(args []string) error {
+	flags := flag.NewFlagSet("decode", flag.ExitOnError)
+	tracePath := flags.String("trace", "", "JSON lines trace written by fingerprint.WithTrace")
+	flags.Parse(args)
+
+	if *tracePath != "" {
+		return decodeTrace(*tracePath)
+	}
+
+	var dump string
+	if flags.NArg() > 0 {
+		dump = strings.Join(flags.Args(), " ")
+	} else {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		dump = string(b)
+	}
+	data, err := protocol.ParseHex(dump)
+	if err != nil {
+		return err
+	}
+	printFrames(protocol.Decode(data))
+	return nil
+}
+
+//decodeTrace - Packets split over several reads are joined, replies are matched to the commands sent
+func decodeTrace(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := fingerprint.ReadTrace(f)
+	if err != nil {
+		return err
+	}
+
+	var decoder protocol.Decoder
+	pending := map[string][]byte{}
+	for _, rec := range records {
+		data, _ := protocol.ParseHex(rec.Data)
+		var frames []protocol.Frame
+		frames, pending[rec.Direction] = decoder.Next(append(pending[rec.Direction], data...))
+		for _, frame := range frames {
+			fmt.Printf("%s %s %s\n", rec.Time.Format("15:04:05.000"), strings.ToUpper(rec.Direction), frame)
+		}
+	}
+	return nil
+}
+
+func runVerifyAudit(args []string) error {
+	flags := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	headText := flags.String("head", "", "head printed by an earlier run, the log must still contain it")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: fpctl verify-audit [-head seq:hash] file")
+	}
+
+	var head fingerprint.AuditHead
+	if *headText != "" {
+		var err error
+		if head, err = fingerprint.ParseAuditHead(*headText); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	last, err := fingerprint.VerifyAuditHead(f, head)
+	if err != nil {
+		return fmt.Errorf("%v (after %d intact records)", err, last.Seq)
+	}
+	fmt.Printf("%d records, hash chain intact, head %s\n", last.Seq, last)
+	return nil
+}
+
+func printFrames(frames []protocol.Frame) {
+	if len(frames) == 0 {
+		fmt.Println("no packets found")
+	}
+	for _, frame := range frames {
+		fmt.Println(frame)
+	}
+}
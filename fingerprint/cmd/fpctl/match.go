@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"math"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/match"
+)
+
+//runMatch - Compares two images on the host, for review of sensor decisions
+func runMatch(args []string) error {
+	flags := flag.NewFlagSet("match", flag.ExitOnError)
+	width := flags.Int("width", 0, "width of raw images, taken from the image size when 0")
+	height := flags.Int("height", 0, "height of raw images, taken from the image size when 0")
+	verbose := flags.Bool("v", false, "list the minutiae of both images")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: fpctl match [-width w -height h] [-v] image image")
+	}
+
+	var templates [2]*match.Template
+	for i, path := range flags.Args() {
+		img, err := loadImage(path, *width, *height)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		templates[i] = match.Extract(img)
+		fmt.Printf("%s: %d minutiae\n", path, len(templates[i].Minutiae))
+		if *verbose {
+			for _, m := range templates[i].Minutiae {
+				fmt.Printf("  %-11s x=%-3d y=%-3d angle=%.0f°\n", m.Kind, m.X, m.Y, m.Angle*180/math.Pi)
+			}
+		}
+	}
+	fmt.Println(match.Compare(templates[0], templates[1]))
+	return nil
+}
+
+//loadImage - PNG as written by fpctl image, anything else is taken as the raw sensor format
+func loadImage(path string, width int, height int) (*image.Gray, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("\x89PNG")) {
+		return fingerprint.DecodeImage(data, width, height)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if gray, ok := decoded.(*image.Gray); ok {
+		return gray, nil
+	}
+	gray := image.NewGray(decoded.Bounds())
+	draw.Draw(gray, gray.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	return gray, nil
+}
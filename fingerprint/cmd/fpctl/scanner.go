@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"github.com/tarm/serial"
+)
+
+//scannerFlags - How the commands working on a sensor reach it
+type scannerFlags struct {
+	serial   *string
+	baud     *int
+	network  *string
+	password *uint
+}
+
+func addScannerFlags(flags *flag.FlagSet) *scannerFlags {
+	return &scannerFlags{
+		serial:   flags.String("serial", "", "serial port of the scanner"),
+		baud:     flags.Int("baud", fingerprint.DefaultBaud, "baud rate of the serial port"),
+		network:  flags.String("network", "", "host:port of a serial-to-ethernet bridge"),
+		password: flags.Uint("password", 0, "module password"),
+	}
+}
+
+func (f *scannerFlags) given() bool {
+	return *f.serial != "" || *f.network != ""
+}
+
+//open - Captured scanner and the port or address it was reached at, Release it when done
+func (f *scannerFlags) open() (fingerprint.ScannerIO, string, error) {
+	var s fingerprint.ScannerIO
+	var name string
+	switch {
+	case *f.serial != "":
+		s = fingerprint.NewSerial(&serial.Config{Name: *f.serial, Baud: *f.baud, ReadTimeout: time.Millisecond * 500}, *f.password)
+		name = *f.serial
+	case *f.network != "":
+		s = fingerprint.NewNetwork(&fingerprint.NetworkConfig{Address: *f.network}, *f.password)
+		name = *f.network
+	default:
+		return nil, "", errors.New("one of -serial or -network is needed")
+	}
+	if err := s.Capture(); err != nil {
+		return nil, "", err
+	}
+	return s, name, nil
+}
@@ -0,0 +1,236 @@
+//fpd owns fingerprint scanners and serves them over the REST API of the server package
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/metrics"
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/server"
+	"github.com/tarm/serial"
+)
+
+//scannerConfig - One scanner, either Serial, Network or USBVID/USBPID has to be set
+type scannerConfig struct {
+	Name        string `json:"name"`
+	Serial      string `json:"serial"`
+	Network     string `json:"network"`
+	NetworkMode string `json:"network_mode"`
+	Baud        int    `json:"baud"`
+	USBVID      uint16 `json:"usb_vid"`
+	USBPID      uint16 `json:"usb_pid"`
+	Password    uint   `json:"password"`
+	Trace       string `json:"trace"`
+
+	//Groups - Slot ranges like "staff: 0-299" for identify?group=
+	Groups []string `json:"groups"`
+	//Allocation - Policy for enrollments without a position, "first_fit" (default) or "round_robin"
+	Allocation string `json:"allocation"`
+	//Retry - Repeats commands failing with communication or checksum errors, as on long cables
+	Retry *retryConfig `json:"retry"`
+}
+
+//retryConfig - Zero values take the library defaults
+type retryConfig struct {
+	MaxAttempts   int     `json:"max_attempts"`
+	BackoffMillis int     `json:"backoff_ms"`
+	Jitter        float64 `json:"jitter"`
+	StateChanging bool    `json:"state_changing"`
+}
+
+//lockoutConfig - Brute-force protection of identify and verify, zero values take the library defaults
+type lockoutConfig struct {
+	MaxFailures       int `json:"max_failures"`
+	LockoutSeconds    int `json:"lockout_seconds"`
+	MaxLockoutSeconds int `json:"max_lockout_seconds"`
+}
+
+type config struct {
+	Listen   string          `json:"listen"`
+	Scanners []scannerConfig `json:"scanners"`
+	Lockout  *lockoutConfig  `json:"lockout"`
+	Audit    string          `json:"audit"`
+	//Metrics - Serves Prometheus metrics of every scanner on /metrics
+	Metrics bool `json:"metrics"`
+}
+
+func loadConfig(path string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	//The API has no authentication, other hosts have to be allowed explicitly
+	cfg := &config{Listen: "127.0.0.1:8080"}
+	if err = json.Unmarshal(b, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func openScanner(sc scannerConfig, audit *fingerprint.AuditLog, registry *metrics.Registry) fingerprint.ScannerIO {
+	var opts []fingerprint.Option
+	if audit != nil {
+		opts = append(opts, fingerprint.WithAudit(audit, sc.Name))
+	}
+	if registry != nil {
+		opts = append(opts, fingerprint.WithMetrics(registry, sc.Name))
+	}
+	if len(sc.Groups) > 0 {
+		ranges := make([]fingerprint.SlotRange, 0, len(sc.Groups))
+		for _, text := range sc.Groups {
+			r, err := fingerprint.ParseSlotRange(text)
+			if err != nil {
+				log.Fatalf("Invalid groups for scanner %s: %v", sc.Name, err)
+			}
+			ranges = append(ranges, r)
+		}
+		if err := fingerprint.ValidateSlotRanges(ranges); err != nil {
+			log.Fatalf("Invalid groups for scanner %s: %v", sc.Name, err)
+		}
+		opts = append(opts, fingerprint.WithSlotRanges(ranges...))
+	}
+	if rc := sc.Retry; rc != nil {
+		name := sc.Name
+		opts = append(opts, fingerprint.WithRetry(fingerprint.RetryPolicy{
+			MaxAttempts:   rc.MaxAttempts,
+			Backoff:       time.Duration(rc.BackoffMillis) * time.Millisecond,
+			Jitter:        rc.Jitter,
+			StateChanging: rc.StateChanging,
+			OnRetry: func(operation string, attempt int, err error) {
+				log.Printf("Scanner %s: %s attempt %d failed: %v", name, operation, attempt, err)
+			},
+		}))
+	}
+	switch sc.Allocation {
+	case "", "first_fit":
+	case "round_robin":
+		opts = append(opts, fingerprint.WithAllocator(fingerprint.NewAllocator(&fingerprint.RoundRobin{})))
+	default:
+		log.Fatalf("Invalid allocation for scanner %s: %q", sc.Name, sc.Allocation)
+	}
+	if sc.Trace != "" {
+		f, err := os.OpenFile(sc.Trace, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			log.Fatalf("Unable to open trace for scanner %s: %v", sc.Name, err)
+		}
+		opts = append(opts, fingerprint.WithTrace(f))
+	}
+	if sc.Serial != "" {
+		baud := sc.Baud
+		if baud == 0 {
+			baud = fingerprint.DefaultBaud
+		}
+		return fingerprint.NewSerial(&serial.Config{Name: sc.Serial, Baud: baud, ReadTimeout: time.Millisecond * 500}, sc.Password, opts...)
+	}
+	if sc.Network != "" {
+		mode := fingerprint.NetworkRaw
+		if sc.NetworkMode == "rfc2217" {
+			mode = fingerprint.NetworkRFC2217
+		}
+		return fingerprint.NewNetwork(&fingerprint.NetworkConfig{Address: sc.Network, Mode: mode, Baud: sc.Baud}, sc.Password, opts...)
+	}
+	return fingerprint.NewUSB(sc.USBVID, sc.USBPID, sc.Password, opts...)
+}
+
+//securityEventLogger - One JSON line per event for the log shipper to alert on, also kept in the audit log
+func securityEventLogger(audit *fingerprint.AuditLog) func(fingerprint.SecurityEvent) {
+	return func(ev fingerprint.SecurityEvent) {
+		b, _ := json.Marshal(ev)
+		log.Printf("Security event: %s", b)
+		if audit != nil {
+			fields := map[string]int{"position": ev.Position, "failures": ev.Failures}
+			if err := audit.Record(ev.Scanner, "security_"+ev.Kind, fields, nil); err != nil {
+				log.Printf("Unable to write audit log: %v", err)
+			}
+		}
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "/etc/fpd.json", "path to the JSON configuration")
+	listen := flag.String("listen", "", "listen address, overrides the configuration")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Unable to load configuration: %v", err)
+	}
+	if *listen != "" {
+		cfg.Listen = *listen
+	}
+	if len(cfg.Scanners) == 0 {
+		log.Fatal("No scanners configured")
+	}
+
+	var audit *fingerprint.AuditLog
+	if cfg.Audit != "" {
+		if audit, err = fingerprint.OpenAuditLog(cfg.Audit); err != nil {
+			log.Fatalf("Unable to open audit log: %v", err)
+		}
+		log.Printf("Audit log head %s", audit.Head())
+	}
+
+	var registry *metrics.Registry
+	if cfg.Metrics {
+		registry = metrics.NewRegistry()
+	}
+
+	srv := server.New()
+	if lc := cfg.Lockout; lc != nil {
+		srv.Lockout = &fingerprint.LockoutPolicy{
+			MaxFailures: lc.MaxFailures,
+			Lockout:     time.Duration(lc.LockoutSeconds) * time.Second,
+			MaxLockout:  time.Duration(lc.MaxLockoutSeconds) * time.Second,
+			OnEvent:     securityEventLogger(audit),
+		}
+	}
+	var captured []fingerprint.ScannerIO
+	release := func() {
+		for _, s := range captured {
+			s.Release()
+		}
+	}
+
+	for _, sc := range cfg.Scanners {
+		s := openScanner(sc, audit, registry)
+		if err = s.Capture(); err != nil {
+			release()
+			log.Fatalf("Unable to capture scanner %s: %v", sc.Name, err)
+		}
+		captured = append(captured, s)
+		if err = srv.Add(sc.Name, s); err != nil {
+			release()
+			log.Fatal(err)
+		}
+		log.Printf("Scanner %s ready", sc.Name)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		release()
+		os.Exit(0)
+	}()
+
+	var handler http.Handler = srv
+	if registry != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", registry)
+		mux.Handle("/", srv)
+		handler = mux
+	}
+
+	log.Printf("Listening on %s", cfg.Listen)
+	err = http.ListenAndServe(cfg.Listen, handler)
+	release()
+	log.Fatal(err)
+}
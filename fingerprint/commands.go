@@ -2,21 +2,38 @@ package fingerprint
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
-	"fmt"
-
-	"github.com/lunixbochs/struc"
+	"sync"
 )
 
-//ThumbPacket  -
+//packetHeaderSize - Start code, address, packet type and length
+const packetHeaderSize = 9
+
+//maxPacketLength - Length field of the largest packet, 256 bytes of data and the checksum
+const maxPacketLength = 256 + 2
+
+//errShortPayload - The payload ends before all fields are read
+var errShortPayload = errors.New("the received payload is too short")
+
+//errInvalidPacket - The received bytes are no packet, e.g. line noise or a faulty device
+var errInvalidPacket = errors.New("the received data is no valid packet")
+
+//ThumbPacket  - A decoded packet, PayLoad shares memory with the received bytes
 type ThumbPacket struct {
-	StartCode      uint `struc:"uint16,big"`
-	Address        uint `struc:"uint32,big"`
-	PacketType     uint `struc:"uint8,big"`
-	PacketLength   uint `struc:"uint16,big"`
-	PayLoad        string
-	PacketChecksum uint `struc:"int16,big"`
+	StartCode      uint
+	Address        uint
+	PacketType     uint
+	PacketLength   uint
+	PayLoad        []byte
+	PacketChecksum uint
+}
+
+//packetPool - Buffers for outgoing packets, data packets of the largest size fit without growing
+var packetPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256+packetHeaderSize+2)
+		return &b
+	},
 }
 
 func calculateChecksum(packetType int, packetLength int, packetPayload []byte) int {
@@ -29,258 +46,206 @@ func calculateChecksum(packetType int, packetLength int, packetPayload []byte) i
 	return packetChecksum
 }
 
-func buildCommandPacket(packetType int, packetPayload []byte) []byte {
-
-	var buf bytes.Buffer
-
-	tp := &ThumbPacket{}
-	tp.StartCode = FINGERPRINT_STARTCODE
-	tp.Address = 0xFFFFFFFF //0x00000002
-	tp.PacketType = uint(packetType)
-	tp.PayLoad = string(packetPayload)
-
+//appendPacket - Appends the complete packet to dst, growing it like append when the packet does not fit
+func appendPacket(dst []byte, packetType int, packetPayload []byte) []byte {
 	packetLength := len(packetPayload) + 2 //2 Is for last 2 bytes of checksum
-	//Calculate Checksum , By rotating and adding Liitle Endian
-	packetChecksum := packetType + packetLength>>8 /*Shift Right 8*/ + packetLength>>0 /*Shift Right 0*/
+	checksum := calculateChecksum(packetType, packetLength, packetPayload)
 
-	//Write payload
-	for _, b := range packetPayload {
-		packetChecksum += int(b)
-	}
-
-	tp.PacketChecksum = uint(calculateChecksum(packetType, packetLength, packetPayload))
-	tp.PacketLength = uint(packetLength)
-
-	err := struc.Pack(&buf, tp)
-
-	if err != nil {
-		fmt.Println("Ohhh its error ->", err)
-	}
+	dst = append(dst,
+		FINGERPRINT_STARTCODE>>8, FINGERPRINT_STARTCODE&0xFF,
+		0xFF, 0xFF, 0xFF, 0xFF, //Address 0xFFFFFFFF
+		byte(packetType),
+		byte(packetLength>>8), byte(packetLength),
+	)
+	dst = append(dst, packetPayload...)
+	return append(dst, byte(checksum>>8), byte(checksum))
+}
 
-	return buf.Bytes()
+//buildCommandPacket - The packet in a new slice, writePacket appends to a pooled buffer instead
+func buildCommandPacket(packetType int, packetPayload []byte) []byte {
+	return appendPacket(make([]byte, 0, len(packetPayload)+packetHeaderSize+2), packetType, packetPayload)
 }
 
 func verifyChecksum(tp *ThumbPacket) error {
 	var err error
-	checkSum := uint(calculateChecksum(int(tp.PacketType), int(tp.PacketLength), []byte(tp.PayLoad)))
+	checkSum := uint(calculateChecksum(int(tp.PacketType), int(tp.PacketLength), tp.PayLoad)) & 0xFFFF
 	if tp.PacketChecksum != checkSum {
-		err = errors.New("Checksum match error")
+		err = ErrChecksum
 	}
 	return err
 }
 
-func decodeResponsePacket(opBuf []byte) (*ThumbPacket, error) {
-	var err error
-	op := new(ThumbPacket)
-	buff := bytes.NewBuffer(opBuf)
-	err = struc.Unpack(buff, op)
-	if err != nil {
-		//Struc decode error
-		op = nil
+//startCode - First two bytes of every packet
+var startCode = []byte{FINGERPRINT_STARTCODE >> 8, FINGERPRINT_STARTCODE & 0xFF}
+
+//nextPacket - Splits the first packet off buf, packet is nil while more bytes are needed.
+//Bytes before a start code, e.g. line noise, are dropped, and so is a start code followed by an impossible length.
+func nextPacket(buf []byte) (packet []byte, rest []byte) {
+	for {
+		start := bytes.Index(buf, startCode)
+		if start < 0 {
+			//The start code may be split over two reads
+			if len(buf) > 0 && buf[len(buf)-1] == startCode[0] {
+				return nil, buf[len(buf)-1:]
+			}
+			return nil, nil
+		}
+		buf = buf[start:]
+		if len(buf) < packetHeaderSize {
+			return nil, buf
+		}
+		packetLength := int(buf[7])<<8 | int(buf[8])
+		if packetLength < 2 || packetLength > maxPacketLength {
+			buf = buf[len(startCode):]
+			continue
+		}
+		packetSize := packetHeaderSize + packetLength
+		if len(buf) < packetSize {
+			return nil, buf
+		}
+		return buf[:packetSize], buf[packetSize:]
 	}
-	//We are doing this as struc does not understand the payload length here.
-	l := len(opBuf)
-	op.PayLoad = string(opBuf[9 : l-2])
-	chksum := uint16(opBuf[l-2]) << 8
-	chksum = chksum | uint16(opBuf[l-1])
-	op.PacketChecksum = uint(chksum)
-	return op, err
 }
 
-func strucToBytes(pl interface{}) []byte {
-	var payLoad bytes.Buffer
-	err := struc.Pack(&payLoad, pl)
+//NextPacket - Splits the first packet off captured traffic the way the driver frames what it reads, see nextPacket
+func NextPacket(buf []byte) (packet []byte, rest []byte) {
+	return nextPacket(buf)
+}
 
-	if err != nil {
-		fmt.Println("Ohhh its error=>", err)
+//Checksum - Checksum field of a packet with the given type, length field and payload
+func Checksum(packetType byte, packetLength int, payload []byte) uint {
+	return uint(calculateChecksum(int(packetType), packetLength, payload)) & 0xFFFF
+}
+
+//decodePacket - Fills tp from one complete packet without copying the payload
+func decodePacket(opBuf []byte, tp *ThumbPacket) error {
+	l := len(opBuf)
+	if l < packetHeaderSize+2 || l != packetHeaderSize+(int(opBuf[7])<<8|int(opBuf[8])) {
+		return errInvalidPacket
+	}
+	tp.StartCode = uint(opBuf[0])<<8 | uint(opBuf[1])
+	tp.Address = uint(opBuf[2])<<24 | uint(opBuf[3])<<16 | uint(opBuf[4])<<8 | uint(opBuf[5])
+	tp.PacketType = uint(opBuf[6])
+	tp.PacketLength = uint(opBuf[7])<<8 | uint(opBuf[8])
+	tp.PayLoad = opBuf[packetHeaderSize : l-2]
+	tp.PacketChecksum = uint(opBuf[l-2])<<8 | uint(opBuf[l-1])
+	return nil
+}
+
+func decodeResponsePacket(opBuf []byte) (*ThumbPacket, error) {
+	op := new(ThumbPacket)
+	if err := decodePacket(opBuf, op); err != nil {
+		return nil, err
 	}
-	return payLoad.Bytes()
+	return op, nil
 }
 
-//systemParams -
-type simplePayLoadStruc struct {
-	PayLoadType uint `struc:"int8,big"`
+//be16 - Big endian 16 bit field at the start of b
+func be16(b []byte) int {
+	return int(b[0])<<8 | int(b[1])
+}
+
+func be32(b []byte) uint {
+	return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
 }
 
 func getPayloadForSystemParams() []byte {
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_GETSYSTEMPARAMETERS
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_GETSYSTEMPARAMETERS}
 }
 
 func getPayloadForCreateTemplate() []byte {
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_CREATETEMPLATE
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_CREATETEMPLATE}
 }
 
 func getPayloadForTemplateCount() []byte {
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_READIMAGE
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_READIMAGE}
 }
 
 func getPayloadForReadImage() []byte {
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_READIMAGE
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_READIMAGE}
 }
 
 func getPayloadForDownloadImage() []byte {
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_DOWNLOADIMAGE
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_DOWNLOADIMAGE}
+}
+
+func getPayloadForUploadImage() []byte {
+	return []byte{FINGERPRINT_UPLOADIMAGE}
 }
 
 func getPayloadForClearDatabase() []byte {
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_CLEARDATABASE
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_CLEARDATABASE}
 }
 
 func getPayloadForCompareCharacteristics() []byte {
-
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_COMPARECHARACTERISTICS
-	return strucToBytes(pl)
-
+	return []byte{FINGERPRINT_COMPARECHARACTERISTICS}
 }
 
 func getPayloadForGenerateRandomNumber() []byte {
+	return []byte{FINGERPRINT_GENERATERANDOMNUMBER}
+}
 
-	pl := &simplePayLoadStruc{}
-	pl.PayLoadType = FINGERPRINT_GENERATERANDOMNUMBER
-	return strucToBytes(pl)
+func getPayloadForHandshake() []byte {
+	return []byte{FINGERPRINT_HANDSHAKE}
+}
 
+func getPayloadForCheckSensor() []byte {
+	return []byte{FINGERPRINT_CHECKSENSOR}
 }
 
-type complexPayloadStruc8N32 struct {
-	PayLoadType uint `struc:"uint8,big"`
-	DataValue   uint `struc:"uint32,big"`
+//payload8N32 - Instruction followed by a 32 bit value
+func payload8N32(instruction byte, value uint) []byte {
+	return []byte{instruction, byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}
 }
 
 func getPayloadForVerifyPassword(password uint) []byte {
-	cpl := &complexPayloadStruc8N32{}
-	cpl.PayLoadType = FINGERPRINT_VERIFYPASSWORD
-	cpl.DataValue = password
-	return strucToBytes(cpl)
+	return payload8N32(FINGERPRINT_VERIFYPASSWORD, password)
 }
 
 func getPayloadForSetPassword(password uint) []byte {
-	cpl := &complexPayloadStruc8N32{}
-	cpl.PayLoadType = FINGERPRINT_SETPASSWORD
-	cpl.DataValue = password
-	return strucToBytes(cpl)
+	return payload8N32(FINGERPRINT_SETPASSWORD, password)
 }
 
 func getPayloadForSetAddress(newAddress uint) []byte {
-	cpl := &complexPayloadStruc8N32{}
-	cpl.PayLoadType = FINGERPRINT_SETADDRESS
-	cpl.DataValue = newAddress
-	return strucToBytes(cpl)
-}
-
-type complexPayloadStruc8N8 struct {
-	PayLoadType int `struc:"int8,big"`
-	DataValue   int `struc:"int8,big"`
+	return payload8N32(FINGERPRINT_SETADDRESS, newAddress)
 }
 
 func getPayloadForTemplateIndex(page int) []byte {
-	cpl := &complexPayloadStruc8N8{}
-	cpl.PayLoadType = FINGERPRINT_TEMPLATEINDEX
-	cpl.DataValue = page
-	return strucToBytes(cpl)
+	return []byte{FINGERPRINT_TEMPLATEINDEX, byte(page)}
 }
 
 func getPayloadForConvertImage(charBufferNo int) []byte {
-	cpl := &complexPayloadStruc8N8{}
-	cpl.PayLoadType = FINGERPRINT_CONVERTIMAGE
-	cpl.DataValue = charBufferNo
-	return strucToBytes(cpl)
+	return []byte{FINGERPRINT_CONVERTIMAGE, byte(charBufferNo)}
 }
 
 func getPayloadForDownloadCharacteristics(CharBufferNo int) []byte {
-	cpl := &complexPayloadStruc8N8{}
-	cpl.PayLoadType = FINGERPRINT_DOWNLOADCHARACTERISTICS
-	cpl.DataValue = CharBufferNo
-	return strucToBytes(cpl)
+	return []byte{FINGERPRINT_DOWNLOADCHARACTERISTICS, byte(CharBufferNo)}
 }
 
 func getPayloadForUploadCharacteristics(CharBufferNo int) []byte {
-	cpl := &complexPayloadStruc8N8{}
-	cpl.PayLoadType = FINGERPRINT_UPLOADCHARACTERISTICS
-	cpl.DataValue = CharBufferNo
-	return strucToBytes(cpl)
+	return []byte{FINGERPRINT_UPLOADCHARACTERISTICS, byte(CharBufferNo)}
 }
 
-type searchImageStruc struct {
-	PayLoadType   int `struc:"int8,big"`
-	CharBufferNo  int `struc:"int8,big"`
-	StartPos      int `struc:"int16,big"`
-	TemplateCount int `struc:"int16,big"`
+func getPayloadForSetSystemParameter(parameterNo int, content int) []byte {
+	return []byte{FINGERPRINT_SETSYSTEMPARAMETER, byte(parameterNo), byte(content)}
 }
 
 func getPayloadForSearchImage(charBufferNo int, startPos int, count int) []byte {
-	pl := &searchImageStruc{}
-	pl.PayLoadType = FINGERPRINT_SEARCHTEMPLATE
-	pl.CharBufferNo = charBufferNo
-	pl.StartPos = startPos
-	pl.TemplateCount = count
-	return strucToBytes(pl)
+	return []byte{FINGERPRINT_SEARCHTEMPLATE, byte(charBufferNo), byte(startPos >> 8), byte(startPos), byte(count >> 8), byte(count)}
 }
 
-type storeTemplateSturc struct {
-	PayLoadType    int `struc:"int8,big"`
-	CharBufferNo   int `struc:"int8,big"`
-	PositionNumber int `struc:"int16,big"`
+func getPayloadForFastSearch(charBufferNo int, startPos int, count int) []byte {
+	return []byte{FINGERPRINT_FASTSEARCH, byte(charBufferNo), byte(startPos >> 8), byte(startPos), byte(count >> 8), byte(count)}
 }
 
 func getPayloadForStoreTemplate(Position int, CharBufferNo int) []byte {
-
-	pl := &storeTemplateSturc{}
-	pl.PayLoadType = FINGERPRINT_STORETEMPLATE
-	pl.CharBufferNo = CharBufferNo
-	pl.PositionNumber = Position
-	return strucToBytes(pl)
-
-}
-
-type loadTemplatestruc struct {
-	PayLoadType    int `struc:"int8,big"`
-	CharBufferNo   int `struc:"int8,big"`
-	PositionNumber int `struc:"int16,big"`
+	return []byte{FINGERPRINT_STORETEMPLATE, byte(CharBufferNo), byte(Position >> 8), byte(Position)}
 }
 
 func getPayloadForLoadTemplate(Position int, CharBufferNo int) []byte {
-
-	pl := &loadTemplatestruc{}
-	pl.PayLoadType = FINGERPRINT_LOADTEMPLATE
-	pl.CharBufferNo = CharBufferNo
-	pl.PositionNumber = Position
-	return strucToBytes(pl)
-
-}
-
-type deleteTemplateStruc struct {
-	PayLoadType    int `struc:"int8,big"`
-	PositionNumber int `struc:"int16,big"`
-	Count          int `struc:"int16,big"`
+	return []byte{FINGERPRINT_LOADTEMPLATE, byte(CharBufferNo), byte(Position >> 8), byte(Position)}
 }
 
 func getPayloadForDeleteTemplate(Position int, count int) []byte {
-	pl := &deleteTemplateStruc{}
-	pl.PayLoadType = FINGERPRINT_DELETETEMPLATE
-	pl.PositionNumber = Position
-	pl.Count = count
-	return strucToBytes(pl)
-
-}
-
-//testUnpack - This is test function to be removed.
-func testUnpack(payLoad []byte) {
-	b := bytes.NewBuffer(payLoad)
-	o := &searchImageStruc{}
-	struc.Unpack(b, o)
-	out, _ := json.Marshal(o)
-	fmt.Printf(string(out))
+	return []byte{FINGERPRINT_DELETETEMPLATE, byte(Position >> 8), byte(Position), byte(count >> 8), byte(count)}
 }
@@ -0,0 +1,270 @@
+package fingerprint
+
+import (
+	"bytes"
+	"testing"
+)
+
+var payloadTests = []struct {
+	name   string
+	got    []byte
+	golden []byte
+	//struc - Output of the former encoder, nil for instructions added after it
+	struc []byte
+}{
+	{"SystemParams", getPayloadForSystemParams(), []byte{0x0F},
+		strucBytes(&strucPayload8{FINGERPRINT_GETSYSTEMPARAMETERS})},
+	{"CreateTemplate", getPayloadForCreateTemplate(), []byte{0x05},
+		strucBytes(&strucPayload8{FINGERPRINT_CREATETEMPLATE})},
+	//Has always been READIMAGE
+	{"TemplateCount", getPayloadForTemplateCount(), []byte{0x01},
+		strucBytes(&strucPayload8{FINGERPRINT_READIMAGE})},
+	{"ReadImage", getPayloadForReadImage(), []byte{0x01},
+		strucBytes(&strucPayload8{FINGERPRINT_READIMAGE})},
+	{"DownloadImage", getPayloadForDownloadImage(), []byte{0x0A},
+		strucBytes(&strucPayload8{FINGERPRINT_DOWNLOADIMAGE})},
+	{"UploadImage", getPayloadForUploadImage(), []byte{0x0B}, nil},
+	{"ClearDatabase", getPayloadForClearDatabase(), []byte{0x0D},
+		strucBytes(&strucPayload8{FINGERPRINT_CLEARDATABASE})},
+	{"CompareCharacteristics", getPayloadForCompareCharacteristics(), []byte{0x03},
+		strucBytes(&strucPayload8{FINGERPRINT_COMPARECHARACTERISTICS})},
+	{"GenerateRandomNumber", getPayloadForGenerateRandomNumber(), []byte{0x14},
+		strucBytes(&strucPayload8{FINGERPRINT_GENERATERANDOMNUMBER})},
+	{"Handshake", getPayloadForHandshake(), []byte{0x40}, nil},
+	{"CheckSensor", getPayloadForCheckSensor(), []byte{0x36}, nil},
+	{"VerifyPassword", getPayloadForVerifyPassword(0xDEADBEEF), []byte{0x13, 0xDE, 0xAD, 0xBE, 0xEF},
+		strucBytes(&strucPayload8N32{FINGERPRINT_VERIFYPASSWORD, 0xDEADBEEF})},
+	{"SetPassword", getPayloadForSetPassword(7), []byte{0x12, 0x00, 0x00, 0x00, 0x07},
+		strucBytes(&strucPayload8N32{FINGERPRINT_SETPASSWORD, 7})},
+	{"SetAddress", getPayloadForSetAddress(0xFFFFFFFE), []byte{0x15, 0xFF, 0xFF, 0xFF, 0xFE},
+		strucBytes(&strucPayload8N32{FINGERPRINT_SETADDRESS, 0xFFFFFFFE})},
+	{"TemplateIndex", getPayloadForTemplateIndex(3), []byte{0x1F, 0x03},
+		strucBytes(&strucPayload8N8{FINGERPRINT_TEMPLATEINDEX, 3})},
+	{"ConvertImage", getPayloadForConvertImage(2), []byte{0x02, 0x02},
+		strucBytes(&strucPayload8N8{FINGERPRINT_CONVERTIMAGE, 2})},
+	{"DownloadCharacteristics", getPayloadForDownloadCharacteristics(1), []byte{0x08, 0x01},
+		strucBytes(&strucPayload8N8{FINGERPRINT_DOWNLOADCHARACTERISTICS, 1})},
+	{"UploadCharacteristics", getPayloadForUploadCharacteristics(2), []byte{0x09, 0x02},
+		strucBytes(&strucPayload8N8{FINGERPRINT_UPLOADCHARACTERISTICS, 2})},
+	{"SetSystemParameter", getPayloadForSetSystemParameter(4, 12), []byte{0x0E, 0x04, 0x0C},
+		strucBytes(&strucSystemParameter{FINGERPRINT_SETSYSTEMPARAMETER, 4, 12})},
+	{"SearchImage", getPayloadForSearchImage(1, 300, 1000), []byte{0x04, 0x01, 0x01, 0x2C, 0x03, 0xE8},
+		strucBytes(&strucSearch{FINGERPRINT_SEARCHTEMPLATE, 1, 300, 1000})},
+	{"FastSearch", getPayloadForFastSearch(2, 0, 1000), []byte{0x1B, 0x02, 0x00, 0x00, 0x03, 0xE8}, nil},
+	{"StoreTemplate", getPayloadForStoreTemplate(999, 1), []byte{0x06, 0x01, 0x03, 0xE7},
+		strucBytes(&strucTemplate{FINGERPRINT_STORETEMPLATE, 1, 999})},
+	{"LoadTemplate", getPayloadForLoadTemplate(258, 2), []byte{0x07, 0x02, 0x01, 0x02},
+		strucBytes(&strucTemplate{FINGERPRINT_LOADTEMPLATE, 2, 258})},
+	{"DeleteTemplate", getPayloadForDeleteTemplate(513, 20), []byte{0x0C, 0x02, 0x01, 0x00, 0x14},
+		strucBytes(&strucDelete{FINGERPRINT_DELETETEMPLATE, 513, 20})},
+}
+
+func TestPayloads(t *testing.T) {
+	for _, tt := range payloadTests {
+		if !bytes.Equal(tt.got, tt.golden) {
+			t.Errorf("%s: % X, want % X", tt.name, tt.got, tt.golden)
+		}
+		if tt.struc != nil && !bytes.Equal(tt.golden, tt.struc) {
+			t.Errorf("%s: golden % X, struc encoded % X", tt.name, tt.golden, tt.struc)
+		}
+	}
+}
+
+var packetTests = []struct {
+	name       string
+	packetType int
+	payload    []byte
+	golden     []byte
+}{
+	{"VerifyPassword", FINGERPRINT_COMMANDPACKET, getPayloadForVerifyPassword(0),
+		[]byte{0xEF, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x00, 0x07, 0x13, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1B}},
+	{"SearchImage", FINGERPRINT_COMMANDPACKET, getPayloadForSearchImage(1, 0, 1000),
+		[]byte{0xEF, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x00, 0x08, 0x04, 0x01, 0x00, 0x00, 0x03, 0xE8, 0x00, 0xF9}},
+	{"Ack", FINGERPRINT_ACKPACKET, []byte{0x00, 0x00, 0x05, 0x00, 0x78},
+		[]byte{0xEF, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0x07, 0x00, 0x07, 0x00, 0x00, 0x05, 0x00, 0x78, 0x00, 0x8B}},
+	{"EndData", FINGERPRINT_ENDDATAPACKET, []byte{0xFF, 0xFF, 0xFF, 0xFF},
+		[]byte{0xEF, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0x08, 0x00, 0x06, 0xFF, 0xFF, 0xFF, 0xFF, 0x04, 0x0A}},
+}
+
+func TestBuildCommandPacket(t *testing.T) {
+	for _, tt := range packetTests {
+		packet := buildCommandPacket(tt.packetType, tt.payload)
+		if !bytes.Equal(packet, tt.golden) {
+			t.Errorf("%s: % X, want % X", tt.name, packet, tt.golden)
+		}
+		if old := strucBuildCommandPacket(tt.packetType, tt.payload); !bytes.Equal(packet, old) {
+			t.Errorf("%s: % X, struc encoded % X", tt.name, packet, old)
+		}
+	}
+
+	//The largest data packet, the checksum overflows the low byte
+	data := bytes.Repeat([]byte{0xFF}, 256)
+	if packet, old := buildCommandPacket(FINGERPRINT_DATAPACKET, data), strucBuildCommandPacket(FINGERPRINT_DATAPACKET, data); !bytes.Equal(packet, old) {
+		t.Errorf("data packet % X, struc encoded % X", packet, old)
+	}
+	for _, tt := range payloadTests {
+		packet := buildCommandPacket(FINGERPRINT_COMMANDPACKET, tt.golden)
+		if old := strucBuildCommandPacket(FINGERPRINT_COMMANDPACKET, tt.golden); !bytes.Equal(packet, old) {
+			t.Errorf("%s: % X, struc encoded % X", tt.name, packet, old)
+		}
+	}
+}
+
+func TestAppendPacket(t *testing.T) {
+	prefix := []byte{1, 2, 3}
+	dst := appendPacket(append(make([]byte, 0, 64), prefix...), FINGERPRINT_COMMANDPACKET, getPayloadForVerifyPassword(0))
+	if !bytes.Equal(dst[:3], prefix) || !bytes.Equal(dst[3:], packetTests[0].golden) {
+		t.Errorf("% X", dst)
+	}
+}
+
+func TestDecodeResponsePacket(t *testing.T) {
+	for _, tt := range packetTests {
+		tp, err := decodeResponsePacket(tt.golden)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		old, err := strucDecodeResponsePacket(tt.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tp.StartCode != old.StartCode || tp.Address != old.Address || tp.PacketType != old.PacketType ||
+			tp.PacketLength != old.PacketLength || string(tp.PayLoad) != old.PayLoad || tp.PacketChecksum != old.PacketChecksum {
+			t.Errorf("%s: %+v, struc decoded %+v", tt.name, tp, old)
+		}
+		if err = verifyChecksum(tp); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	damaged := append([]byte(nil), packetTests[2].golden...)
+	damaged[len(damaged)-1]++
+	if tp, err := decodeResponsePacket(damaged); err != nil || verifyChecksum(tp) != ErrChecksum {
+		t.Errorf("damaged packet decoded to %+v, %v", tp, err)
+	}
+	for _, short := range [][]byte{nil, packetTests[2].golden[:10], packetTests[2].golden[:15]} {
+		if _, err := decodeResponsePacket(short); err != errInvalidPacket {
+			t.Errorf("% X decoded, %v", short, err)
+		}
+	}
+}
+
+func TestNextPacket(t *testing.T) {
+	ackPacket := buildCommandPacket(FINGERPRINT_ACKPACKET, []byte{FINGERPRINT_OK})
+	dataPacket := buildCommandPacket(FINGERPRINT_DATAPACKET, []byte{1, 2, 3})
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	tests := []struct {
+		name   string
+		buf    []byte
+		packet []byte
+		rest   []byte
+	}{
+		{"Empty", nil, nil, nil},
+		{"Packet", ackPacket, ackPacket, []byte{}},
+		{"PacketBehind", concat(ackPacket, dataPacket[:4]), ackPacket, dataPacket[:4]},
+		{"Incomplete", ackPacket[:10], nil, ackPacket[:10]},
+		{"Noise", []byte{0x00, 0x3F, 0x01}, nil, nil},
+		{"NoiseBefore", concat([]byte{0x3F}, ackPacket), ackPacket, []byte{}},
+		{"NoiseBeforeIncomplete", concat([]byte{0x3F, 0x00}, ackPacket[:5]), nil, ackPacket[:5]},
+		{"SplitStartCode", []byte{0x3F, 0xEF}, nil, []byte{0xEF}},
+		{"BadLength", concat(ackPacket[:7], []byte{0x10, 0x00}, ackPacket), ackPacket, []byte{}},
+		{"ZeroLength", concat(ackPacket[:7], []byte{0x00, 0x00}, ackPacket), ackPacket, []byte{}},
+	}
+	for _, tt := range tests {
+		packet, rest := nextPacket(tt.buf)
+		if !bytes.Equal(packet, tt.packet) || !bytes.Equal(rest, tt.rest) {
+			t.Errorf("%s: packet % X rest % X, want % X and % X", tt.name, packet, rest, tt.packet, tt.rest)
+		}
+	}
+}
+
+//TestReceiveAfterNoise - A noise byte on the line does not cost the ack behind it
+func TestReceiveAfterNoise(t *testing.T) {
+	s, l := newScripted(nil)
+	ackPacket := buildCommandPacket(FINGERPRINT_ACKPACKET, []byte{FINGERPRINT_OK, 0x00, 0x05, 0x00, 0x78})
+	l.queue = [][]byte{{0x00}, {0xEF}, ackPacket[:3], ackPacket[3:]}
+	tp, err := s.readPacket()
+	if err != nil || !bytes.Equal(tp.PayLoad, []byte{FINGERPRINT_OK, 0x00, 0x05, 0x00, 0x78}) {
+		t.Fatalf("read %+v, %v", tp, err)
+	}
+	if len(s.rxBuffer) != 0 {
+		t.Errorf("kept % X", s.rxBuffer)
+	}
+}
+
+var benchmarkPacket []byte
+
+func benchmarkData() []byte {
+	return bytes.Repeat([]byte{0x5A}, 128)
+}
+
+func BenchmarkBuildCommandPacket(b *testing.B) {
+	data := benchmarkData()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkPacket = buildCommandPacket(FINGERPRINT_DATAPACKET, data)
+	}
+}
+
+//BenchmarkAppendPacketPooled - The way writePacket encodes
+func BenchmarkAppendPacketPooled(b *testing.B) {
+	data := benchmarkData()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bp := packetPool.Get().(*[]byte)
+		*bp = appendPacket((*bp)[:0], FINGERPRINT_DATAPACKET, data)
+		packetPool.Put(bp)
+	}
+}
+
+func BenchmarkStrucBuildCommandPacket(b *testing.B) {
+	data := benchmarkData()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkPacket = strucBuildCommandPacket(FINGERPRINT_DATAPACKET, data)
+	}
+}
+
+func BenchmarkDecodeResponsePacket(b *testing.B) {
+	packet := buildCommandPacket(FINGERPRINT_DATAPACKET, benchmarkData())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tp, _ := decodeResponsePacket(packet)
+		verifyChecksum(tp)
+	}
+}
+
+//BenchmarkDecodePacket - Decoding into a reused ThumbPacket
+func BenchmarkDecodePacket(b *testing.B) {
+	packet := buildCommandPacket(FINGERPRINT_DATAPACKET, benchmarkData())
+	var tp ThumbPacket
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		decodePacket(packet, &tp)
+		verifyChecksum(&tp)
+	}
+}
+
+func BenchmarkStrucDecodeResponsePacket(b *testing.B) {
+	packet := buildCommandPacket(FINGERPRINT_DATAPACKET, benchmarkData())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		strucDecodeResponsePacket(packet)
+	}
+}
+
+func BenchmarkPayloadSearch(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkPacket = getPayloadForSearchImage(1, 0, 1000)
+	}
+}
+
+func BenchmarkStrucPayloadSearch(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkPacket = strucBytes(&strucSearch{FINGERPRINT_SEARCHTEMPLATE, 1, 0, 1000})
+	}
+}
@@ -1,5 +1,7 @@
 package fingerprint
 
+import "fmt"
+
 const (
 
 	//FingerPrintStartCode - Baotou start byte
@@ -26,11 +28,15 @@ const (
 	//Note: The documentation mean upload to host computer.
 	FINGERPRINT_DOWNLOADIMAGE = 0x0A
 
+	//Note: The documentation mean download from host computer.
+	FINGERPRINT_UPLOADIMAGE = 0x0B
+
 	FINGERPRINT_CONVERTIMAGE = 0x02
 
 	FINGERPRINT_CREATETEMPLATE = 0x05
 	FINGERPRINT_STORETEMPLATE  = 0x06
 	FINGERPRINT_SEARCHTEMPLATE = 0x04
+	FINGERPRINT_FASTSEARCH     = 0x1B
 	FINGERPRINT_LOADTEMPLATE   = 0x07
 	FINGERPRINT_DELETETEMPLATE = 0x0C
 
@@ -44,6 +50,23 @@ const (
 	//Note: The documentation mean upload to host computer.
 	FINGERPRINT_DOWNLOADCHARACTERISTICS = 0x08
 
+	//Self tests and product info of newer modules
+	FINGERPRINT_CHECKSENSOR  = 0x36
+	FINGERPRINT_READPRODINFO = 0x3C
+	FINGERPRINT_HANDSHAKE    = 0x40
+
+	//Notepad, 16 pages of 32 bytes for user data
+	FINGERPRINT_WRITENOTEPAD = 0x18
+	FINGERPRINT_READNOTEPAD  = 0x19
+
+	//Aura LED ring of R502-A/R503 modules
+	FINGERPRINT_AURALEDCONFIG = 0x35
+
+	//On-device workflows of R503 class modules
+	FINGERPRINT_CANCEL       = 0x30
+	FINGERPRINT_AUTOENROLL   = 0x31
+	FINGERPRINT_AUTOIDENTIFY = 0x32
+
 	//Parameters of setSystemParameter()
 	FINGERPRINT_SETSYSTEMPARAMETER_BAUDRATE       = 4
 	FINGERPRINT_SETSYSTEMPARAMETER_SECURITY_LEVEL = 5
@@ -79,6 +102,11 @@ const (
 
 	FINGERPRINT_ERROR_NOTMATCHING = 0x08
 
+	FINGERPRINT_ERROR_LIBRARYFULL     = 0x1F
+	FINGERPRINT_ERROR_FINGERTIMEOUT   = 0x26
+	FINGERPRINT_ERROR_ALREADYENROLLED = 0x27
+	FINGERPRINT_ERROR_SENSORABNORMAL  = 0x29
+
 	FINGERPRINT_ERROR_DOWNLOADIMAGE           = 0x0F
 	FINGERPRINT_ERROR_DOWNLOADCHARACTERISTICS = 0x0D
 
@@ -96,3 +124,46 @@ const (
 	FINGERPRINT_CHARBUFFER2       = 0x02 //Char buffer 2
 	SMALLEST_RESPONSE_PACKET_SIZE = 12
 )
+
+//instructionNames - By instruction code, the constant names without the prefix
+var instructionNames = map[byte]string{
+	FINGERPRINT_READIMAGE:               "READIMAGE",
+	FINGERPRINT_CONVERTIMAGE:            "CONVERTIMAGE",
+	FINGERPRINT_COMPARECHARACTERISTICS:  "COMPARECHARACTERISTICS",
+	FINGERPRINT_SEARCHTEMPLATE:          "SEARCHTEMPLATE",
+	FINGERPRINT_FASTSEARCH:              "FASTSEARCH",
+	FINGERPRINT_CREATETEMPLATE:          "CREATETEMPLATE",
+	FINGERPRINT_STORETEMPLATE:           "STORETEMPLATE",
+	FINGERPRINT_LOADTEMPLATE:            "LOADTEMPLATE",
+	FINGERPRINT_DOWNLOADCHARACTERISTICS: "DOWNLOADCHARACTERISTICS",
+	FINGERPRINT_UPLOADCHARACTERISTICS:   "UPLOADCHARACTERISTICS",
+	FINGERPRINT_DOWNLOADIMAGE:           "DOWNLOADIMAGE",
+	FINGERPRINT_UPLOADIMAGE:             "UPLOADIMAGE",
+	FINGERPRINT_DELETETEMPLATE:          "DELETETEMPLATE",
+	FINGERPRINT_CLEARDATABASE:           "CLEARDATABASE",
+	FINGERPRINT_SETSYSTEMPARAMETER:      "SETSYSTEMPARAMETER",
+	FINGERPRINT_GETSYSTEMPARAMETERS:     "GETSYSTEMPARAMETERS",
+	FINGERPRINT_SETPASSWORD:             "SETPASSWORD",
+	FINGERPRINT_VERIFYPASSWORD:          "VERIFYPASSWORD",
+	FINGERPRINT_GENERATERANDOMNUMBER:    "GENERATERANDOMNUMBER",
+	FINGERPRINT_SETADDRESS:              "SETADDRESS",
+	FINGERPRINT_TEMPLATECOUNT:           "TEMPLATECOUNT",
+	FINGERPRINT_TEMPLATEINDEX:           "TEMPLATEINDEX",
+	FINGERPRINT_WRITENOTEPAD:            "WRITENOTEPAD",
+	FINGERPRINT_READNOTEPAD:             "READNOTEPAD",
+	FINGERPRINT_AURALEDCONFIG:           "AURALEDCONFIG",
+	FINGERPRINT_CANCEL:                  "CANCEL",
+	FINGERPRINT_AUTOENROLL:              "AUTOENROLL",
+	FINGERPRINT_AUTOIDENTIFY:            "AUTOIDENTIFY",
+	FINGERPRINT_CHECKSENSOR:             "CHECKSENSOR",
+	FINGERPRINT_READPRODINFO:            "READPRODINFO",
+	FINGERPRINT_HANDSHAKE:               "HANDSHAKE",
+}
+
+//InstructionName - Name of an instruction code, as in constant.go without the prefix
+func InstructionName(code byte) string {
+	if name, ok := instructionNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(0x%02X)", code)
+}
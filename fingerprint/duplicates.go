@@ -0,0 +1,98 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+)
+
+//DuplicatePair - Two positions holding the same finger, Score as reported by the search
+type DuplicatePair struct {
+	First  int `json:"first"`
+	Second int `json:"second"`
+	Score  int `json:"score"`
+}
+
+func (p DuplicatePair) String() string {
+	return fmt.Sprintf("%d and %d (score %d)", p.First, p.Second, p.Score)
+}
+
+//FindDuplicates - Loads every stored template in turn and searches the positions after it for the same finger.
+//A range with a match is split around it and both parts searched again, so a finger enrolled three times is
+//reported as three pairs. Takes one search per template and two more per pair found, progress is optional and
+//receives the number of templates checked so far and their total. With an audit log the scan is recorded as
+//one find_duplicates record instead of one per search.
+func FindDuplicates(ctx context.Context, s ScannerIO, progress func(done int, total int)) ([]DuplicatePair, error) {
+	b, ok := s.(auditBatcher)
+	if !ok {
+		return findDuplicates(ctx, s, progress)
+	}
+	var pairs []DuplicatePair
+	err := b.auditBatch("find_duplicates", func() (map[string]int, error) {
+		var err error
+		pairs, err = findDuplicates(ctx, s, progress)
+		return map[string]int{"pairs": len(pairs)}, err
+	})
+	return pairs, err
+}
+
+func findDuplicates(ctx context.Context, s ScannerIO, progress func(done int, total int)) ([]DuplicatePair, error) {
+	index, err := s.TemplateIndex()
+	if err != nil {
+		return nil, err
+	}
+	used := UsedPositions(index)
+
+	pairs := []DuplicatePair{}
+	for i, position := range used {
+		if err := ctx.Err(); err != nil {
+			return pairs, err
+		}
+		if err := s.LoadTemplate(position, FINGERPRINT_CHARBUFFER1); err != nil {
+			return pairs, fmt.Errorf("position %d: %v", position, err)
+		}
+		found, err := searchDuplicates(ctx, s, index, position+1, len(index)-1)
+		if err != nil {
+			return pairs, fmt.Errorf("position %d: %v", position, err)
+		}
+		for _, match := range found {
+			pairs = append(pairs, DuplicatePair{First: position, Second: match.PositionNumber, Score: match.AccuracyScore})
+		}
+		if progress != nil {
+			progress(i+1, len(used))
+		}
+	}
+	return pairs, nil
+}
+
+//searchDuplicates - Every match of char buffer 1 between first and last, ranges without templates are skipped
+func searchDuplicates(ctx context.Context, s ScannerIO, index []bool, first int, last int) ([]SearchResult, error) {
+	for first <= last && !index[first] {
+		first++
+	}
+	for last >= first && !index[last] {
+		last--
+	}
+	if first > last {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := s.SearchTemplate(FINGERPRINT_CHARBUFFER1, first, last-first+1)
+	if err != nil {
+		return nil, err
+	}
+	if result.PositionNumber < first || result.PositionNumber > last {
+		return nil, nil
+	}
+	before, err := searchDuplicates(ctx, s, index, first, result.PositionNumber-1)
+	if err != nil {
+		return nil, err
+	}
+	after, err := searchDuplicates(ctx, s, index, result.PositionNumber+1, last)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(before, *result), after...), nil
+}
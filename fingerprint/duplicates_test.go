@@ -0,0 +1,76 @@
+package fingerprint
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//libraryModule - Module with a finger per used position, equal fingers match each other in a search
+func libraryModule(fingers map[int]string) func(command []byte) [][]byte {
+	loaded := ""
+	return func(command []byte) [][]byte {
+		switch command[0] {
+		case FINGERPRINT_TEMPLATEINDEX:
+			page := make([]byte, 33)
+			for position := range fingers {
+				page[1+position/8] |= 1 << (position % 8)
+			}
+			return [][]byte{page}
+		case FINGERPRINT_LOADTEMPLATE:
+			loaded = fingers[int(command[2])<<8|int(command[3])]
+			return [][]byte{{FINGERPRINT_OK}}
+		case FINGERPRINT_SEARCHTEMPLATE:
+			first := int(command[2])<<8 | int(command[3])
+			last := first + (int(command[4])<<8 | int(command[5])) - 1
+			for position := first; position <= last; position++ {
+				if fingers[position] == loaded {
+					return [][]byte{{FINGERPRINT_OK, byte(position >> 8), byte(position), 0, 90}}
+				}
+			}
+			return [][]byte{{FINGERPRINT_ERROR_NOTEMPLATEFOUND}}
+		}
+		return [][]byte{{FINGERPRINT_OK}}
+	}
+}
+
+func TestFindDuplicatesAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	fingers := map[int]string{1: "thumb", 4: "index", 9: "thumb", 12: "thumb"}
+	s, l := newScripted(libraryModule(fingers), WithAudit(a, "door"))
+
+	pairs, err := FindDuplicates(context.Background(), s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DuplicatePair{{1, 9, 90}, {1, 12, 90}, {9, 12, 90}}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("pairs %v, want %v", pairs, want)
+	}
+	if countSent(l, FINGERPRINT_SEARCHTEMPLATE) < len(fingers) {
+		t.Errorf("%d searches", countSent(l, FINGERPRINT_SEARCHTEMPLATE))
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"op":"find_duplicates"`) || !strings.Contains(lines[0], `"pairs":3`) {
+		t.Errorf("audit log:\n%s", b)
+	}
+
+	//Searches outside the scan are recorded again
+	s.SearchTemplate(FINGERPRINT_CHARBUFFER1, 0, -1)
+	if head := a.Head(); head.Seq != 2 {
+		t.Errorf("head %s after a search", head)
+	}
+}
@@ -1,13 +1,14 @@
 package fingerprint
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/metrics"
 	"github.com/google/gousb"
-	"github.com/lunixbochs/struc"
 	"github.com/tarm/serial"
 )
 
@@ -27,25 +28,84 @@ type mySerial struct {
 	cfg  *serial.Config
 }
 
+//transport - Byte stream to the sensor, implemented for USB, serial and network bridges
+type transport interface {
+	open() error
+	close()
+	write(payLoad []byte) (int, error)
+	readFragement(readSize int) ([]byte, int, error)
+}
+
+//baudSetter - Transports which have to follow a baud rate change of the sensor
+type baudSetter interface {
+	//checkBaud - Fails if the transport cannot follow, asked before the sensor is told to change
+	checkBaud(baud int) error
+	setBaud(baud int) error
+}
+
 //SystemParameters -
 type SystemParameters struct {
-	StatusRegister  uint `struc:"uint16,big"`
-	SystemID        uint `struc:"uint16,big"`
-	StorageCapacity uint `struc:"uint16,big"`
-	SecurityLevel   uint `struc:"uint16,big"`
-	DeviceAddress   uint `struc:"uint32,big"`
-	PacketLength    uint `struc:"uint16,big"`
-	BaudRate        uint `struc:"uint16,big"`
+	StatusRegister  uint
+	SystemID        uint
+	StorageCapacity uint
+	SecurityLevel   uint
+	DeviceAddress   uint
+	PacketLength    uint
+	BaudRate        uint
+}
+
+func (p *SystemParameters) decode(b []byte) error {
+	if len(b) < 16 {
+		return errShortPayload
+	}
+	p.StatusRegister = uint(be16(b[0:]))
+	p.SystemID = uint(be16(b[2:]))
+	p.StorageCapacity = uint(be16(b[4:]))
+	p.SecurityLevel = uint(be16(b[6:]))
+	p.DeviceAddress = be32(b[8:])
+	p.PacketLength = uint(be16(b[12:]))
+	p.BaudRate = uint(be16(b[14:]))
+	//The size of every data packet is derived from the code, a faulty module must not make it 0 or negative
+	if p.PacketLength > 3 {
+		return fmt.Errorf("the received packet length code %d is invalid", p.PacketLength)
+	}
+	return nil
 }
 
 //Scanner - Scanner struct to hold various data members
 type scanner struct {
-	usb       myUSB
-	serial    mySerial
-	useSerial bool
-	password  uint
-	debug     bool
-	param     *SystemParameters
+	link     transport
+	password uint
+	debug    bool
+	param    *SystemParameters
+	rxBuffer []byte
+
+	//responseTimeout - Zero waits for the response as long as it takes
+	responseTimeout time.Duration
+	//unsupported, answered - Optional instructions the module rejected twice or answered, see optionalCommand
+	unsupported map[int]bool
+	answered    map[int]bool
+
+	//audit - Optional, see WithAudit
+	audit     *AuditLog
+	auditName string
+
+	//groups - Named ranges of the library, see WithSlotRanges. groupsErr is set when they failed validation.
+	groups    map[string]SlotRange
+	groupsErr error
+
+	//allocator - Free positions and reservations, see WithAllocator
+	allocator *Allocator
+
+	//retryPolicy - Optional, see WithRetry
+	retryPolicy *RetryPolicy
+
+	//metrics - Optional, see WithMetrics. The command in flight is timed until its ack arrives.
+	metrics     metrics.Recorder
+	metricsName string
+	inFlight    bool
+	instruction byte
+	sentAt      time.Time
 }
 
 //ScannerIO - Interface for Scanner
@@ -58,10 +118,32 @@ type ScannerIO interface {
 	DeleteFingerprint(position int, count int) (bool, error)
 	ConvertImage(charBufferNo int) bool
 	SearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error)
+	FastSearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error)
+	SearchGroup(charBufferNo int, name string) (*SearchResult, error)
+	SlotRange(name string) (SlotRange, error)
+	ReserveSlot(group string) (int, error)
+	ReserveSlotAt(position int) error
+	ReleaseSlot(position int)
 	CreateTemplate() error
 	StoreTemplate(Position int, CharBufferNo int) (int, error)
 	ClearDatabase() error
 	CompareCharacteristics() (int, error)
+	LoadTemplate(Position int, CharBufferNo int) error
+	TemplateIndex() ([]bool, error)
+	DownloadCharacteristics(charBufferNo int) ([]byte, error)
+	UploadCharacteristics(charBufferNo int, data []byte) error
+	DownloadImage() ([]byte, error)
+	UploadImage(data []byte) error
+	SetSystemParameter(parameterNo int, content int) error
+	SetLED(color LEDColor, mode LEDMode, speed int, count int) error
+	ReadNotepad(page int) ([]byte, error)
+	WriteNotepad(page int, data []byte) error
+	Handshake() error
+	CheckSensor() error
+	GenerateRandomNumber() (uint32, error)
+	ProductInfo() (*ProductInfo, error)
+	SetPassword(password uint) error
+	ChangePassword(oldPassword uint, newPassword uint) error
 }
 
 // func getDefaultSerialCfg() *serial.Config {
@@ -69,32 +151,31 @@ type ScannerIO interface {
 // }
 
 //NewSerial - Create Scanner with serial connection
-func NewSerial(serialCfg *serial.Config, password uint) ScannerIO {
+func NewSerial(serialCfg *serial.Config, password uint, opts ...Option) ScannerIO {
 	if serialCfg == nil {
 		log.Fatal("Unable to open serial port due to invalid params")
 	}
 
 	s := &scanner{}
-	s.serial.cfg = serialCfg
-	s.useSerial = true
+	s.link = &mySerial{cfg: serialCfg}
 	s.password = password
+	s.applyOptions(opts)
 	return s
 }
 
 //NewUSB - Create Scanner with usb connection
-func NewUSB(vid uint16, pid uint16, password uint) ScannerIO {
+func NewUSB(vid uint16, pid uint16, password uint, opts ...Option) ScannerIO {
 	s := &scanner{}
 	// Open any device with a given VID/PID using a convenience function.
-	s.usb.vid = gousb.ID(vid)
-	s.usb.pid = gousb.ID(pid)
-	s.useSerial = false
+	s.link = &myUSB{vid: gousb.ID(vid), pid: gousb.ID(pid)}
 	s.password = password
+	s.applyOptions(opts)
 	return s
 }
 
-func (s *scanner) captureSerial() error {
+func (p *mySerial) open() error {
 	var err error
-	s.serial.port, err = serial.OpenPort(s.serial.cfg)
+	p.port, err = serial.OpenPort(p.cfg)
 	if err != nil {
 		log.Printf(err.Error())
 		return err
@@ -102,131 +183,156 @@ func (s *scanner) captureSerial() error {
 	return nil
 }
 
-func (s *scanner) captureUSB() error {
+func (u *myUSB) open() error {
 	var err error
-	s.usb.ctxt = gousb.NewContext()
-	s.usb.device, err = s.usb.ctxt.OpenDeviceWithVIDPID(s.usb.vid, s.usb.pid)
+	u.ctxt = gousb.NewContext()
+	u.device, err = u.ctxt.OpenDeviceWithVIDPID(u.vid, u.pid)
 	if err != nil {
-		s.usb.ctxt.Close()
+		u.ctxt.Close()
 		log.Printf("Could not open a device: %v\n", err)
 		return err
 	}
 
 	// Switch the configuration to #1.
-	s.usb.config, err = s.usb.device.Config(1)
+	u.config, err = u.device.Config(1)
 	if err != nil {
-		log.Printf("%s.Config(2): %v", s.usb.device, err)
-		s.usb.device.Close()
-		s.usb.ctxt.Close()
+		log.Printf("%s.Config(2): %v", u.device, err)
+		u.device.Close()
+		u.ctxt.Close()
 		return err
 	}
 	// In the config #2, claim interface #3 with alt setting #0.
-	s.usb.intf, err = s.usb.config.Interface(0, 0)
+	u.intf, err = u.config.Interface(0, 0)
 	if err != nil {
-		log.Fatalf("%s.Interface(0, 0): %v", s.usb.device, err)
-		s.usb.device.Close()
-		s.usb.device.Close()
-		s.usb.ctxt.Close()
+		log.Fatalf("%s.Interface(0, 0): %v", u.device, err)
+		u.device.Close()
+		u.device.Close()
+		u.ctxt.Close()
 		return err
 	}
 
-	s.usb.epIn, err = s.usb.intf.InEndpoint(2)
+	u.epIn, err = u.intf.InEndpoint(2)
 	if err != nil {
-		log.Fatalf("%s.InEndpoint(2): %v", s.usb.intf, err)
-		s.usb.intf.Close()
-		s.usb.device.Close()
-		s.usb.device.Close()
-		s.usb.ctxt.Close()
+		log.Fatalf("%s.InEndpoint(2): %v", u.intf, err)
+		u.intf.Close()
+		u.device.Close()
+		u.device.Close()
+		u.ctxt.Close()
 		return err
 	}
 
 	// And in the same interface open endpoint #5 for writing.
-	s.usb.epOut, err = s.usb.intf.OutEndpoint(2)
+	u.epOut, err = u.intf.OutEndpoint(2)
 	if err != nil {
-		log.Fatalf("%s.InEndpoint(2): %v", s.usb.intf, err)
-		s.usb.intf.Close()
-		s.usb.device.Close()
-		s.usb.device.Close()
-		s.usb.ctxt.Close()
+		log.Fatalf("%s.InEndpoint(2): %v", u.intf, err)
+		u.intf.Close()
+		u.device.Close()
+		u.device.Close()
+		u.ctxt.Close()
 		return nil
 	}
 
 	return nil
 }
 
+//Capture - Opens the connection and verifies the password, ErrWrongPassword when the module rejects it
 func (s *scanner) Capture() (err error) {
 
-	if s.useSerial == true {
-		err = s.captureSerial()
-	} else {
-		err = s.captureUSB()
+	if s.groupsErr != nil {
+		return s.groupsErr
 	}
-	if err == nil {
-		s.param, err = s.GetSystemParameters()
+	s.rxBuffer = nil
+	err = s.link.open()
+	if err != nil {
+		return err
 	}
+	if err = s.verifyPassword(s.password); err != nil {
+		s.link.close()
+		return err
+	}
+	s.param, err = s.GetSystemParameters()
 	return err
 }
 
-func (s *scanner) releaseSerial() {
+func (p *mySerial) close() {
+	if p.port != nil {
+		p.port.Close()
+		p.port = nil
+	}
+}
+
+func (p *mySerial) checkBaud(baud int) error {
+	return nil
+}
 
+//setBaud - Reopens the port at the new speed
+func (p *mySerial) setBaud(baud int) error {
+	cfg := *p.cfg
+	cfg.Baud = baud
+	p.close()
+	p.cfg = &cfg
+	return p.open()
 }
 
-func (s *scanner) releaseUSB() {
+func (u *myUSB) close() {
 
-	s.usb.intf.Close()
-	s.usb.device.Close()
-	s.usb.device.Close()
-	s.usb.ctxt.Close()
+	u.intf.Close()
+	u.device.Close()
+	u.device.Close()
+	u.ctxt.Close()
 }
 
 func (s *scanner) Release() {
-	if s.useSerial == true {
-		s.releaseSerial()
-	} else {
-		s.releaseUSB()
-	}
+	s.link.close()
 }
 
 func (s *scanner) getStorageCapacity() int {
 	return int(s.param.StorageCapacity)
 }
 
-func (s *scanner) writeSerial(payLoad []byte) (int, error) {
-	numBytes, err := s.serial.port.Write(payLoad)
+//getPacketSize - Data packet payload size in bytes
+func (s *scanner) getPacketSize() int {
+	return s.param.PacketSize()
+}
+
+func (p *mySerial) write(payLoad []byte) (int, error) {
+	numBytes, err := p.port.Write(payLoad)
 	if numBytes == 0 {
-		// log.Printf("%d.Write(): only %d bytes written, returned error is %v\n", s.serial.port, numBytes, err)
+		// log.Printf("%d.Write(): only %d bytes written, returned error is %v\n", p.port, numBytes, err)
 		return -1, err
 	}
 	return numBytes, err
 }
 
-func (s *scanner) writeUSB(payLoad []byte) (int, error) {
+func (u *myUSB) write(payLoad []byte) (int, error) {
 	// Write data to the USB device.
-	numBytes, err := s.usb.epOut.Write(payLoad)
+	numBytes, err := u.epOut.Write(payLoad)
 	if numBytes == 0 {
-		log.Printf("%s.Write([2]): only %d bytes written, returned error is %v\n", s.usb.epOut, numBytes, err)
+		log.Printf("%s.Write([2]): only %d bytes written, returned error is %v\n", u.epOut, numBytes, err)
 		numBytes = -1
 	}
 	return numBytes, err
 }
 
 func (s *scanner) writePacket(packetType int, payLoad []byte) (numBytes int, err error) {
-	packet := buildCommandPacket(packetType, payLoad)
+	bp := packetPool.Get().(*[]byte)
+	packet := appendPacket((*bp)[:0], packetType, payLoad)
 	if s.debug == true {
 		fmt.Println("Final Packet: ", packet)
 	}
-	if s.useSerial == true {
-		numBytes, err = s.writeSerial(packet)
-	} else {
-		numBytes, err = s.writeUSB(packet)
+	numBytes, err = s.link.write(packet)
+	*bp = packet
+	packetPool.Put(bp)
+	if s.metrics != nil && packetType == FINGERPRINT_COMMANDPACKET && len(payLoad) > 0 {
+		s.inFlight, s.instruction, s.sentAt = true, payLoad[0], time.Now()
 	}
 	return numBytes, err
 }
 
-func (s *scanner) readFragementOnSerial(readSize int) ([]byte, int, error) {
+func (p *mySerial) readFragement(readSize int) ([]byte, int, error) {
 
 	buf := make([]byte, readSize)
-	readBytes, err := s.serial.port.Read(buf)
+	readBytes, err := p.port.Read(buf)
 	if err != nil {
 		log.Printf("Error reading Serial Port =>%s\n", err.Error())
 		readBytes = -1
@@ -235,9 +341,9 @@ func (s *scanner) readFragementOnSerial(readSize int) ([]byte, int, error) {
 	return buf, readBytes, err
 }
 
-func (s *scanner) readFragementOnUSB(readSize int) ([]byte, int, error) {
+func (u *myUSB) readFragement(readSize int) ([]byte, int, error) {
 	buf := make([]byte, readSize)
-	readBytes, err := s.usb.epIn.Read(buf)
+	readBytes, err := u.epIn.Read(buf)
 	if err != nil {
 		fmt.Println("Read returned an error:", err)
 	} else if readBytes == 0 {
@@ -247,6 +353,19 @@ func (s *scanner) readFragementOnUSB(readSize int) ([]byte, int, error) {
 }
 
 func (s *scanner) readPacket() (*ThumbPacket, error) {
+	return s.readPacketContext(context.Background())
+}
+
+//readPacketContext - Like readPacket, gives up when ctx is done while the sensor is silent
+func (s *scanner) readPacketContext(ctx context.Context) (*ThumbPacket, error) {
+	tp, err := s.receivePacket(ctx)
+	if s.metrics != nil {
+		s.observeResponse(tp, err)
+	}
+	return tp, err
+}
+
+func (s *scanner) receivePacket(ctx context.Context) (*ThumbPacket, error) {
 	var maxReadSize, readBytes int
 	var frag, buf []byte
 	var err error
@@ -254,56 +373,112 @@ func (s *scanner) readPacket() (*ThumbPacket, error) {
 
 	maxReadSize = 1024
 	continueRead := true
+	started := time.Now()
 
-	for continueRead == true {
-
-		if s.useSerial == true {
-			frag, readBytes, err = s.readFragementOnSerial(maxReadSize)
-		} else {
-			frag, readBytes, err = s.readFragementOnUSB(maxReadSize)
-		}
+	//Bytes left over from the previous read belong to the next packet
+	buf = s.rxBuffer
+	s.rxBuffer = nil
 
-		if readBytes > 0 {
-			buf = append(buf, frag[:readBytes]...)
-		}
+	for continueRead == true {
 
-		if len(buf) < SMALLEST_RESPONSE_PACKET_SIZE {
+		packet, rest := nextPacket(buf)
+		if packet != nil {
+			//Keep anything after this packet, e.g. data packets following an ack
+			if len(rest) > 0 {
+				s.rxBuffer = append([]byte(nil), rest...)
+			}
+			buf = packet
+			continueRead = false
 			continue
 		}
+		//Noise in front of the packet is gone, the ack behind it is kept
+		buf = rest
 
-		if tp, err = decodeResponsePacket(buf); err != nil {
-			//Handle error
+		frag, readBytes, err = s.link.readFragement(maxReadSize)
+		if errors.Is(err, ErrLinkDown) {
+			//The response is lost with the connection
+			return nil, err
 		}
-		if uint(len(buf)) < tp.PacketLength+9 {
-			//Data receiving is still pending
-			continue
+
+		if readBytes > 0 {
+			buf = append(buf, frag[:readBytes]...)
+		} else if s.responseTimeout > 0 && time.Since(started) > s.responseTimeout {
+			return nil, ErrTimeout
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-		continueRead = false
 	}
 	if s.debug == true {
 		fmt.Println("Final Received Packet: ", buf)
 	}
+	if tp, err = decodeResponsePacket(buf); err != nil {
+		return nil, err
+	}
 	err = verifyChecksum(tp)
 	return tp, err
 }
 
+//readDataPackets - Collects the payload of data packets until the end data packet is received
+func (s *scanner) readDataPackets() ([]byte, error) {
+	var data []byte
+
+	for {
+		tp, err := s.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if tp.PacketType != FINGERPRINT_DATAPACKET && tp.PacketType != FINGERPRINT_ENDDATAPACKET {
+			return nil, errors.New("the received packet is no data packet")
+		}
+		data = append(data, tp.PayLoad...)
+		if tp.PacketType == FINGERPRINT_ENDDATAPACKET {
+			break
+		}
+	}
+	return data, nil
+}
+
+//writeDataPackets - Splits data into packets of the configured packet size, the last one is sent as end data packet
+func (s *scanner) writeDataPackets(data []byte) error {
+	packetSize := s.getPacketSize()
+
+	for len(data) > 0 {
+		n := packetSize
+		packetType := FINGERPRINT_DATAPACKET
+		if len(data) <= packetSize {
+			n = len(data)
+			packetType = FINGERPRINT_ENDDATAPACKET
+		}
+		if _, err := s.writePacket(packetType, data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
 func anyCommonErrors(tp *ThumbPacket) (errorFound bool, errorCode int, errDesc error) {
 
 	errorFound = true //Yes there is error
 	errDesc = nil
 
+	if tp == nil || len(tp.PayLoad) == 0 {
+		return errorFound, -1, errors.New("the received packet has no confirmation code")
+	}
 	if tp.PacketType != FINGERPRINT_ACKPACKET {
 		errDesc = errors.New("the received packet is no ack packet")
 	}
 
-	receivedPacketPayload := []byte(tp.PayLoad)
+	receivedPacketPayload := tp.PayLoad
 	errorCode = int(receivedPacketPayload[0])
 
 	if errorCode == FINGERPRINT_OK && errDesc == nil {
 		errorFound = false
 		errDesc = nil
+	} else if errorCode == FINGERPRINT_ERROR_WRONGPASSWORD {
+		errDesc = ErrWrongPassword
 	} else if errorCode == FINGERPRINT_ERROR_COMMUNICATION {
-		errDesc = errors.New("Communication error")
+		errDesc = ErrCommunication
 	} else if errorCode == FINGERPRINT_ERROR_INVALIDREGISTER {
 		errDesc = errors.New("Invalid register number")
 	} else if errorCode == FINGERPRINT_ERROR_MESSYIMAGE {
@@ -315,7 +490,7 @@ func anyCommonErrors(tp *ThumbPacket) (errorFound bool, errorCode int, errDesc e
 	} else if errorCode == FINGERPRINT_ERROR_CHARACTERISTICSMISMATCH {
 		errDesc = errors.New("characteristics mismatch")
 	} else if errorCode == FINGERPRINT_ERROR_NOTMATCHING {
-		errDesc = errors.New("Fingerprint do not mismatch")
+		errDesc = ErrNoMatch
 	} else if errorCode == FINGERPRINT_ERROR_CLEARDATABASE {
 		errDesc = errors.New("Unable to clear database")
 	} else if errorCode == FINGERPRINT_ERROR_INVALIDPOSITION {
@@ -334,77 +509,144 @@ func anyCommonErrors(tp *ThumbPacket) (errorFound bool, errorCode int, errDesc e
 	return errorFound, errorCode, errDesc
 }
 
-func (s *scanner) VerifyPassword() (ret bool) {
-	ret = true
-	payLoad := getPayloadForVerifyPassword(s.password)
+func (s *scanner) VerifyPassword() bool {
+	return s.verifyPassword(s.password) == nil
+}
+
+//verifyPassword - ErrWrongPassword when the module rejects the password
+func (s *scanner) verifyPassword(password uint) error {
+	payLoad := getPayloadForVerifyPassword(password)
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
-		ret = false
+		return errWrite
 	}
 	tp, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
+		//Without a response there is nothing to check
+		return errRead
 	}
-	if errorFound, _, errDesc := anyCommonErrors(tp); errDesc != nil {
-		log.Printf(errDesc.Error())
-		ret = !errorFound
+	if _, _, errDesc := anyCommonErrors(tp); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
 	}
-	return ret
+	return nil
 }
 
-func (s *scanner) SetPassword(password uint) (ret bool) {
-
-	ret = true
-	s.password = password
-
-	payLoad := getPayloadForSetPassword(s.password)
+//setPassword - The password used by the host changes once the module confirmed it
+func (s *scanner) setPassword(password uint) error {
+	payLoad := getPayloadForSetPassword(password)
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
-		ret = false
+		return errWrite
 	}
 	tp, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
+		//Without a response there is nothing to check
+		return errRead
 	}
-	if errorFound, _, errDesc := anyCommonErrors(tp); errDesc != nil {
-		log.Printf(errDesc.Error())
-		ret = !errorFound
+	if _, _, errDesc := anyCommonErrors(tp); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
 	}
-	return ret
+	s.password = password
+	return nil
 }
 
-func decodePayload(op interface{}, opBuf []byte) error {
-	var err error
-	buff := bytes.NewBuffer(opBuf[1:])
-	err = struc.Unpack(buff, op)
-	if err != nil {
-		//Struc decode error
-		op = nil
+//payloadDecoder - Response types read their fields from the payload after the confirmation code
+type payloadDecoder interface {
+	decode(b []byte) error
+}
+
+func decodePayload(op payloadDecoder, opBuf []byte) error {
+	if len(opBuf) == 0 {
+		return errShortPayload
 	}
-	return err
+	return op.decode(opBuf[1:])
 }
 
 func (s *scanner) GetSystemParameters() (*SystemParameters, error) {
-	var err error
+	var tp *ThumbPacket
 
-	payLoad := getPayloadForSystemParams()
+	err := s.retry("get_system_parameters", false, func() (err error) {
+		payLoad := getPayloadForSystemParams()
+		if _, err = s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad); err != nil {
+			return err
+		}
+		if tp, err = s.readPacket(); err != nil {
+			return err
+		}
+		if _, _, errDesc := anyCommonErrors(tp); errDesc != nil {
+			log.Println(errDesc.Error())
+			return errDesc
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &SystemParameters{}
+	if err = decodePayload(result, tp.PayLoad); err != nil {
+		result = nil
+	}
+	return result, err
+}
+
+func (s *scanner) setSystemParameter(parameterNo int, content int) error {
+
+	switch parameterNo {
+	case FINGERPRINT_SETSYSTEMPARAMETER_BAUDRATE:
+		if content < 1 || content > 12 {
+			return errors.New("the given baud rate multiplier is invalid")
+		}
+	case FINGERPRINT_SETSYSTEMPARAMETER_SECURITY_LEVEL:
+		if content < 1 || content > 5 {
+			return errors.New("the given security level is invalid")
+		}
+	case FINGERPRINT_SETSYSTEMPARAMETER_PACKAGE_SIZE:
+		if content < 0 || content > 3 {
+			return errors.New("the given package size is invalid")
+		}
+	default:
+		return errors.New("the given parameter number is invalid")
+	}
+
+	bs, followsBaud := s.link.(baudSetter)
+	if parameterNo == FINGERPRINT_SETSYSTEMPARAMETER_BAUDRATE && followsBaud {
+		//The change cannot be taken back once the sensor made it
+		if err := bs.checkBaud(BaudRateUnit * content); err != nil {
+			return err
+		}
+	}
+
+	payLoad := getPayloadForSetSystemParameter(parameterNo, content)
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
-		return nil, errWrite
+		return errWrite
 	}
-	tp, errRead := s.readPacket()
+
+	responsePacket, errRead := s.readPacket()
 	if errRead != nil {
-		return nil, errRead
+		return errRead
 	}
-	if _, _, errDesc := anyCommonErrors(tp); errDesc != nil {
-		log.Printf(err.Error())
-		return nil, errDesc
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
 	}
-	result := &SystemParameters{}
-	if err = decodePayload(result, []byte(tp.PayLoad)); err != nil {
-		result = nil
+
+	switch parameterNo {
+	case FINGERPRINT_SETSYSTEMPARAMETER_BAUDRATE:
+		s.param.BaudRate = uint(content)
+		//The sensor answers at the old speed and switches afterwards
+		if followsBaud {
+			return bs.setBaud(BaudRateUnit * content)
+		}
+	case FINGERPRINT_SETSYSTEMPARAMETER_SECURITY_LEVEL:
+		s.param.SecurityLevel = uint(content)
+	case FINGERPRINT_SETSYSTEMPARAMETER_PACKAGE_SIZE:
+		s.param.PacketLength = uint(content)
 	}
-	return result, err
+	return nil
 }
 
 func (s *scanner) ReadImage() (ret bool) {
@@ -417,7 +659,8 @@ func (s *scanner) ReadImage() (ret bool) {
 
 	tp, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
+		//Without a response there is nothing to check
+		return false
 	}
 
 	var errorFound bool
@@ -445,7 +688,8 @@ func (s *scanner) ConvertImage(charBufferNo int) bool {
 
 	tp, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
+		//Without a response there is nothing to check
+		return false
 	}
 
 	if errorFound, _, errDesc := anyCommonErrors(tp); errDesc != nil {
@@ -457,11 +701,20 @@ func (s *scanner) ConvertImage(charBufferNo int) bool {
 
 //SearchResult -
 type SearchResult struct {
-	PositionNumber int `struc:"uint16,big"`
-	AccuracyScore  int `struc:"uint16,big"`
+	PositionNumber int
+	AccuracyScore  int
+}
+
+func (r *SearchResult) decode(b []byte) error {
+	if len(b) < 4 {
+		return errShortPayload
+	}
+	r.PositionNumber = be16(b[0:])
+	r.AccuracyScore = be16(b[2:])
+	return nil
 }
 
-func (s *scanner) SearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error) {
+func (s *scanner) searchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error) {
 	var err error
 	var errorFound bool
 	var errorCode int
@@ -476,22 +729,25 @@ func (s *scanner) SearchTemplate(charBufferNo int, startPos int, count int) (*Se
 	if count > 0 {
 		templatesCount = count
 	} else {
-		templatesCount = s.getStorageCapacity()
-	}
-
-	payLoad := getPayloadForSearchImage(charBufferNo, startPos, templatesCount)
-	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
-	if errWrite != nil {
-		return nil, errWrite
-	}
-
-	responsePacket, errRead := s.readPacket()
-	if errRead != nil {
-		return nil, errRead
+		templatesCount = s.getStorageCapacity() - startPos
 	}
 
-	if errorFound, errorCode, errDesc = anyCommonErrors(responsePacket); errDesc != nil {
-		log.Printf(errDesc.Error())
+	var responsePacket *ThumbPacket
+	err = s.retry("search", false, func() (err error) {
+		payLoad := getPayloadForSearchImage(charBufferNo, startPos, templatesCount)
+		if _, err = s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad); err != nil {
+			return err
+		}
+		if responsePacket, err = s.readPacket(); err != nil {
+			return err
+		}
+		if errorFound, errorCode, errDesc = anyCommonErrors(responsePacket); errDesc != nil {
+			log.Printf(errDesc.Error())
+			return errDesc
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -501,7 +757,7 @@ func (s *scanner) SearchTemplate(charBufferNo int, startPos int, count int) (*Se
 		return result, errDesc
 	}
 
-	if err = decodePayload(result, []byte(responsePacket.PayLoad)); err != nil {
+	if err = decodePayload(result, responsePacket.PayLoad); err != nil {
 		result = nil
 	}
 	return result, err
@@ -509,30 +765,39 @@ func (s *scanner) SearchTemplate(charBufferNo int, startPos int, count int) (*Se
 
 //Accuracy -
 type Accuracy struct {
-	Score int `struc:"uint16,big"`
+	Score int
 }
 
-func (s *scanner) CompareCharacteristics() (int, error) {
-	var errDesc error
-
-	payLoad := getPayloadForCompareCharacteristics()
-	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
-	if errWrite != nil {
-		return 0, errWrite
+func (a *Accuracy) decode(b []byte) error {
+	if len(b) < 2 {
+		return errShortPayload
 	}
+	a.Score = be16(b)
+	return nil
+}
 
-	responsePacket, errRead := s.readPacket()
-	if errRead != nil {
-		//Handle packet read error
-		return 0, errRead
-	}
+func (s *scanner) compareCharacteristics() (int, error) {
+	var errDesc error
 
-	if _, _, errDesc = anyCommonErrors(responsePacket); errDesc != nil {
+	var responsePacket *ThumbPacket
+	errDesc = s.retry("compare", false, func() (err error) {
+		payLoad := getPayloadForCompareCharacteristics()
+		if _, err = s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad); err != nil {
+			return err
+		}
+		if responsePacket, err = s.readPacket(); err != nil {
+			//Handle packet read error
+			return err
+		}
+		_, _, err = anyCommonErrors(responsePacket)
+		return err
+	})
+	if errDesc != nil {
 		return 0, errDesc
 	}
 
 	result := &Accuracy{}
-	if errDesc = decodePayload(result, []byte(responsePacket.PayLoad)); errDesc != nil {
+	if errDesc = decodePayload(result, responsePacket.PayLoad); errDesc != nil {
 		result.Score = 0
 	}
 	return result.Score, errDesc
@@ -560,67 +825,231 @@ func (s *scanner) CreateTemplate() error {
 	return nil
 }
 
-func (s *scanner) getFreePosition() int {
-	freePosition := -1
+func (s *scanner) storeTemplate(Position int, CharBufferNo int) (int, error) {
 
-	for page := 0; page <= 3; page++ {
-		if freePosition >= 0 {
-			break
+	if CharBufferNo != FINGERPRINT_CHARBUFFER1 && CharBufferNo != FINGERPRINT_CHARBUFFER2 {
+		return -1, errors.New("the given char buffer number is invalid")
+	}
+
+	if Position == -1 {
+		var err error
+		if Position, err = s.allocatePosition(); err != nil {
+			return -1, err
 		}
-		templateIndex, err := s.getTemplateIndex(page)
-		if err != nil {
-			return freePosition
+	} else if Position < 0x0000 || Position >= s.getStorageCapacity() {
+		return -1, errors.New("The given position number is invalid")
+	} else if err := s.checkStorePosition(Position); err != nil {
+		return -1, err
+	}
+
+	err := s.retry("store_template", true, func() error {
+		payLoad := getPayloadForStoreTemplate(Position, CharBufferNo)
+		_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
+		if errWrite != nil {
+			return errWrite
 		}
-		for index := range templateIndex {
-			if templateIndex[index] == false {
-				freePosition = (len(templateIndex) * page) + index
-				break
-			}
+
+		responsePacket, errRead := s.readPacket()
+		if errRead != nil {
+			//Handle packet read error
+			return errRead
+		}
+
+		if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+			log.Printf(errDesc.Error())
+			return errDesc
 		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
 	}
-	return freePosition
+
+	return Position, nil
 }
 
-//StoreTemplate -
-func (s *scanner) StoreTemplate(Position int, CharBufferNo int) (int, error) {
+func (s *scanner) getTemplateIndex(page int) ([]bool, error) {
 
-	if Position == -1 {
-		Position = s.getFreePosition()
+	var responsePacket *ThumbPacket
+	err := s.retry("template_index", false, func() error {
+		payLoad := getPayloadForTemplateIndex(page)
+		_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
+		if errWrite != nil {
+			return errWrite
+		}
+
+		var errRead error
+		if responsePacket, errRead = s.readPacket(); errRead != nil {
+			//Handle packet read error
+			return errRead
+		}
+
+		if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+			log.Printf(errDesc.Error())
+			return errDesc
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTemplateIndex(responsePacket.PayLoad)
+}
+
+//decodeTemplateIndex - One bit per position after the confirmation code, lowest bit first
+func decodeTemplateIndex(opBuf []byte) ([]bool, error) {
+	if len(opBuf) == 0 {
+		return nil, errShortPayload
+	}
+	pageElements := opBuf[1:]
+	templateIndex := make([]bool, 0, len(pageElements)*8)
+
+	for _, pageElement := range pageElements {
+		for b := 0; b <= 7; b++ {
+			positionIsUsed := (pageElement & (0x01 << b)) != 0
+			templateIndex = append(templateIndex, positionIsUsed)
+		}
+	}
+
+	return templateIndex, nil
+}
+
+func (s *scanner) clearDatabase() error {
+
+	return s.retry("clear_database", true, func() error {
+		payLoad := getPayloadForClearDatabase()
+		_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
+		if errWrite != nil {
+			return errWrite
+		}
+
+		responsePacket, errRead := s.readPacket()
+		if errRead != nil {
+			//Handle packet read error
+			return errRead
+		}
+
+		if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+			log.Printf(errDesc.Error())
+			return errDesc
+		}
+
+		return nil
+	})
+}
+
+func (s *scanner) deleteFingerprint(position int, count int) (bool, error) {
+	var ret bool
+	ret = false
+
+	if count < 1 {
+		return ret, errors.New("minimum count val should be 1")
 	}
 
+	var errDevice error
+	err := s.retry("delete_template", true, func() error {
+		errDevice = nil
+		payLoad := getPayloadForDeleteTemplate(position, count)
+		_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
+		if errWrite != nil {
+			errDevice = errors.New("command write failed")
+			return errDevice
+		}
+
+		tp, errRead := s.readPacket()
+		if errRead != nil {
+			//A damaged response is repeated, the caller only learns the read failed
+			errDevice = errors.New("device read failed")
+			return errRead
+		}
+
+		if errorFound, _, errDesc := anyCommonErrors(tp); errDesc != nil {
+			log.Printf(errDesc.Error())
+			ret = !errorFound
+			return errDesc
+		}
+		ret = true
+		return nil
+	})
+	if errDevice != nil {
+		return false, errDevice
+	}
+	return ret, err
+}
+
+//LoadTemplate - Loads the template stored at the given position into the char buffer
+func (s *scanner) LoadTemplate(Position int, CharBufferNo int) error {
+
 	if Position < 0x0000 || Position >= s.getStorageCapacity() {
-		return -1, errors.New("The given position number is invalid")
+		return errors.New("The given position number is invalid")
 	}
 
 	if CharBufferNo != FINGERPRINT_CHARBUFFER1 && CharBufferNo != FINGERPRINT_CHARBUFFER2 {
-		return -1, errors.New("the given char buffer number is invalid")
+		return errors.New("the given char buffer number is invalid")
 	}
 
-	payLoad := getPayloadForStoreTemplate(Position, CharBufferNo)
+	payLoad := getPayloadForLoadTemplate(Position, CharBufferNo)
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
-		return -1, errWrite
+		return errWrite
 	}
 
 	responsePacket, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
-		return -1, errRead
+		return errRead
 	}
 
 	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
-		log.Printf(errDesc.Error())
-		return -1, errDesc
+		log.Println(errDesc.Error())
+		return errDesc
 	}
 
-	return Position, nil
+	return nil
 }
 
-func (s *scanner) getTemplateIndex(page int) ([]bool, error) {
+//TemplateIndex - Occupancy of every position up to the storage capacity, true means used
+func (s *scanner) TemplateIndex() ([]bool, error) {
+	capacity := s.getStorageCapacity()
+	templateIndex := make([]bool, 0, capacity)
+
+	for page := 0; len(templateIndex) < capacity; page++ {
+		pageIndex, err := s.getTemplateIndex(page)
+		if err != nil {
+			return nil, err
+		}
+		if len(pageIndex) == 0 {
+			break
+		}
+		templateIndex = append(templateIndex, pageIndex...)
+	}
+
+	if len(templateIndex) > capacity {
+		templateIndex = templateIndex[:capacity]
+	}
+	s.observeLibrary(templateIndex)
+	return templateIndex, nil
+}
+
+//UsedPositions - Positions marked used in a template index, empty but not nil for an empty library
+func UsedPositions(index []bool) []int {
+	used := []int{}
+	for position, isUsed := range index {
+		if isUsed {
+			used = append(used, position)
+		}
+	}
+	return used
+}
+
+//DownloadCharacteristics - Reads the content of the char buffer to the host
+func (s *scanner) DownloadCharacteristics(charBufferNo int) ([]byte, error) {
 
-	templateIndex := make([]bool, 0)
+	if charBufferNo != FINGERPRINT_CHARBUFFER1 && charBufferNo != FINGERPRINT_CHARBUFFER2 {
+		return nil, errors.New("the given char buffer number is invalid")
+	}
 
-	payLoad := getPayloadForTemplateIndex(page)
+	payLoad := getPayloadForDownloadCharacteristics(charBufferNo)
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
 		return nil, errWrite
@@ -628,30 +1057,29 @@ func (s *scanner) getTemplateIndex(page int) ([]bool, error) {
 
 	responsePacket, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
 		return nil, errRead
 	}
 
 	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
-		log.Printf(errDesc.Error())
+		log.Println(errDesc.Error())
 		return nil, errDesc
 	}
 
-	pageElements := []byte(responsePacket.PayLoad)[1:]
+	return s.readDataPackets()
+}
 
-	for _, pageElement := range pageElements {
-		for b := 0; b <= 7; b++ {
-			positionIsUsed := (pageElement & (0x01 << b)) != 0
-			templateIndex = append(templateIndex, positionIsUsed)
-		}
-	}
+//UploadCharacteristics - Writes the given characteristics from the host into the char buffer
+func (s *scanner) UploadCharacteristics(charBufferNo int, data []byte) error {
 
-	return templateIndex, nil
-}
+	if charBufferNo != FINGERPRINT_CHARBUFFER1 && charBufferNo != FINGERPRINT_CHARBUFFER2 {
+		return errors.New("the given char buffer number is invalid")
+	}
 
-func (s *scanner) ClearDatabase() error {
+	if len(data) == 0 {
+		return errors.New("the given characteristics are empty")
+	}
 
-	payLoad := getPayloadForClearDatabase()
+	payLoad := getPayloadForUploadCharacteristics(charBufferNo)
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
 		return errWrite
@@ -659,44 +1087,61 @@ func (s *scanner) ClearDatabase() error {
 
 	responsePacket, errRead := s.readPacket()
 	if errRead != nil {
-		//Handle packet read error
 		return errRead
 	}
 
 	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
-		log.Printf(errDesc.Error())
+		log.Println(errDesc.Error())
 		return errDesc
 	}
 
-	return nil
+	return s.writeDataPackets(data)
 }
 
-//DeleteFingerprint
-func (s *scanner) DeleteFingerprint(position int, count int) (bool, error) {
-	var ret bool
-	ret = false
+//DownloadImage - Reads the image buffer to the host, each byte holds two 4 bit pixels
+func (s *scanner) DownloadImage() ([]byte, error) {
 
-	if count < 1 {
-		return ret, errors.New("minimum count val should be 1")
+	payLoad := getPayloadForDownloadImage()
+	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
+	if errWrite != nil {
+		return nil, errWrite
+	}
+
+	responsePacket, errRead := s.readPacket()
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return nil, errDesc
+	}
+
+	return s.readDataPackets()
+}
+
+//UploadImage - Writes an image in the format of DownloadImage to the image buffer, split at the packet length
+func (s *scanner) UploadImage(data []byte) error {
+
+	if len(data) == 0 {
+		return errors.New("the given image is empty")
 	}
 
-	payLoad := getPayloadForDeleteTemplate(position, count)
+	payLoad := getPayloadForUploadImage()
 	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, payLoad)
 	if errWrite != nil {
-		return ret, errors.New("command write failed")
+		return errWrite
 	}
 
-	tp, errRead := s.readPacket()
+	responsePacket, errRead := s.readPacket()
 	if errRead != nil {
-		return ret, errors.New("device read failed")
+		return errRead
 	}
 
-	if errorFound, _, errDesc := anyCommonErrors(tp); errDesc != nil {
-		log.Printf(errDesc.Error())
-		ret = !errorFound
-		return ret, errDesc
-	} else {
-		ret = true
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
 	}
-	return ret, nil
+
+	return s.writeDataPackets(data)
 }
@@ -0,0 +1,136 @@
+package fingerprint
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzNextPacket(f *testing.F) {
+	f.Add(buildCommandPacket(FINGERPRINT_ACKPACKET, []byte{FINGERPRINT_OK}))
+	f.Add(append([]byte{0x00, 0xEF}, buildCommandPacket(FINGERPRINT_ACKPACKET, []byte{FINGERPRINT_OK})...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet, rest := nextPacket(data)
+		if !bytes.HasSuffix(data, rest) || len(packet)+len(rest) > len(data) {
+			t.Fatalf("packet % X and rest % X are not taken from % X", packet, rest, data)
+		}
+		if packet == nil {
+			//Only a possible start of a packet is kept
+			if len(rest) > 0 && rest[0] != startCode[0] {
+				t.Fatalf("kept % X", rest)
+			}
+			return
+		}
+		if !bytes.HasPrefix(packet, startCode) || !bytes.HasSuffix(data, append(packet, rest...)) {
+			t.Fatalf("packet % X rest % X of % X", packet, rest, data)
+		}
+		var tp ThumbPacket
+		if err := decodePacket(packet, &tp); err != nil {
+			t.Fatalf("framed % X does not decode: %v", packet, err)
+		}
+	})
+}
+
+//FuzzNextPacketNoise - Noise without a start code in front of a packet does not hide it
+func FuzzNextPacketNoise(f *testing.F) {
+	f.Add([]byte{0x00}, []byte{FINGERPRINT_OK})
+	f.Add([]byte{0x01, 0xEF, 0xEF}, []byte{FINGERPRINT_OK, 0x00, 0x05, 0x00, 0x78})
+	f.Fuzz(func(t *testing.T, noise []byte, payload []byte) {
+		if len(payload) > 256 {
+			return
+		}
+		if bytes.Contains(noise, startCode) {
+			return
+		}
+		packet := buildCommandPacket(FINGERPRINT_ACKPACKET, payload)
+		data := append(append([]byte(nil), noise...), packet...)
+		if got, rest := nextPacket(data); !bytes.Equal(got, packet) || len(rest) != 0 {
+			t.Fatalf("% X framed as % X, rest % X", data, got, rest)
+		}
+	})
+}
+
+func FuzzDecodePacket(f *testing.F) {
+	f.Add(buildCommandPacket(FINGERPRINT_ACKPACKET, []byte{FINGERPRINT_OK}))
+	f.Add(buildCommandPacket(FINGERPRINT_COMMANDPACKET, getPayloadForSearchImage(1, 0, 1000)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tp, err := decodeResponsePacket(data)
+		if err != nil {
+			if tp != nil {
+				t.Fatal("packet returned with an error")
+			}
+			return
+		}
+		if verifyChecksum(tp) != nil {
+			return
+		}
+		//A packet to the default address with a valid checksum is exactly what the encoder builds
+		if tp.StartCode != FINGERPRINT_STARTCODE || tp.Address != 0xFFFFFFFF {
+			return
+		}
+		if built := buildCommandPacket(int(tp.PacketType), tp.PayLoad); !bytes.Equal(built, data) {
+			t.Fatalf("% X encodes as % X", data, built)
+		}
+	})
+}
+
+func FuzzSystemParameters(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0xC8, 0, 3, 0xFF, 0xFF, 0xFF, 0xFF, 0, 2, 0, 6})
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		var p SystemParameters
+		err := decodePayload(&p, payload)
+		if (err == nil) != (len(payload) >= 17) {
+			t.Fatalf("% X: %v", payload, err)
+		}
+		if err != nil {
+			return
+		}
+		fields := []uint{p.StatusRegister, p.SystemID, p.StorageCapacity, p.SecurityLevel}
+		for i, field := range fields {
+			if field != uint(be16(payload[1+2*i:])) {
+				t.Fatalf("field %d of % X decoded as %d", i, payload, field)
+			}
+		}
+		if p.DeviceAddress != be32(payload[9:]) || p.PacketLength != uint(be16(payload[13:])) || p.BaudRate != uint(be16(payload[15:])) {
+			t.Fatalf("% X decoded as %+v", payload, p)
+		}
+	})
+}
+
+func FuzzSearchResult(f *testing.F) {
+	f.Add([]byte{FINGERPRINT_OK, 0x00, 0x05, 0x00, 0x78})
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		var r SearchResult
+		err := decodePayload(&r, payload)
+		if (err == nil) != (len(payload) >= 5) {
+			t.Fatalf("% X: %v", payload, err)
+		}
+		if err == nil && (r.PositionNumber != be16(payload[1:]) || r.AccuracyScore != be16(payload[3:])) {
+			t.Fatalf("% X decoded as %+v", payload, r)
+		}
+		var a Accuracy
+		if err = decodePayload(&a, payload); (err == nil) != (len(payload) >= 3) {
+			t.Fatalf("accuracy of % X: %v", payload, err)
+		}
+	})
+}
+
+func FuzzTemplateIndex(f *testing.F) {
+	f.Add(append([]byte{FINGERPRINT_OK, 0x07}, make([]byte, 31)...))
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		index, err := decodeTemplateIndex(payload)
+		if (err == nil) != (len(payload) > 0) {
+			t.Fatalf("% X: %v", payload, err)
+		}
+		if err != nil {
+			return
+		}
+		if len(index) != 8*(len(payload)-1) {
+			t.Fatalf("%d positions from % X", len(index), payload)
+		}
+		for position, used := range index {
+			if used != (payload[1+position/8]&(1<<(position%8)) != 0) {
+				t.Fatalf("position %d of % X", position, payload)
+			}
+		}
+	})
+}
@@ -1,6 +1,6 @@
 module github.com/SachinPuranik/verizy-go-fingerprint/fingerprint
 
-go 1.15
+go 1.18
 
 require (
 	github.com/google/gousb v1.1.1
@@ -0,0 +1,191 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//ErrUnknownGroup - No slot range of that name is configured on the scanner
+var ErrUnknownGroup = errors.New("unknown slot group")
+
+//SlotRange - Named range of library positions, First and Last included
+type SlotRange struct {
+	Name  string `json:"name"`
+	First int    `json:"first"`
+	Last  int    `json:"last"`
+}
+
+//Count - Number of positions in the range
+func (r SlotRange) Count() int {
+	return r.Last - r.First + 1
+}
+
+//Contains - Whether the position lies in the range
+func (r SlotRange) Contains(position int) bool {
+	return position >= r.First && position <= r.Last
+}
+
+func (r SlotRange) String() string {
+	return fmt.Sprintf("%s: %d-%d", r.Name, r.First, r.Last)
+}
+
+//ParseSlotRange - Reads a range written as "staff: 0-299", a single position needs no dash
+func ParseSlotRange(text string) (SlotRange, error) {
+	parts := strings.SplitN(text, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return SlotRange{}, fmt.Errorf("slot range %q is not of the form name: first-last", text)
+	}
+	r := SlotRange{Name: strings.TrimSpace(parts[0])}
+	bounds := strings.SplitN(strings.TrimSpace(parts[1]), "-", 2)
+	var err error
+	if r.First, err = strconv.Atoi(strings.TrimSpace(bounds[0])); err != nil {
+		return SlotRange{}, fmt.Errorf("slot range %q: %v", text, err)
+	}
+	r.Last = r.First
+	if len(bounds) == 2 {
+		if r.Last, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+			return SlotRange{}, fmt.Errorf("slot range %q: %v", text, err)
+		}
+	}
+	if r.First < 0 || r.Last < r.First {
+		return SlotRange{}, fmt.Errorf("slot range %q is empty", text)
+	}
+	return r, nil
+}
+
+//ValidateSlotRanges - Ranges must not be empty, names must be unique and ranges must not overlap, a position
+//belongs to one group only
+func ValidateSlotRanges(ranges []SlotRange) error {
+	sorted := append([]SlotRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].First < sorted[j].First })
+	names := map[string]bool{}
+	for i, r := range sorted {
+		if r.First < 0 || r.Last < r.First {
+			return fmt.Errorf("slot group %v is empty", r)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("slot group %q is defined twice", r.Name)
+		}
+		names[r.Name] = true
+		if i > 0 && r.First <= sorted[i-1].Last {
+			return fmt.Errorf("slot groups %v and %v overlap", sorted[i-1], r)
+		}
+	}
+	return nil
+}
+
+//WithSlotRanges - Names ranges of the library for SearchGroup. Ranges failing ValidateSlotRanges make Capture
+//and every group lookup return the validation error.
+func WithSlotRanges(ranges ...SlotRange) Option {
+	return func(s *scanner) {
+		if s.groupsErr = ValidateSlotRanges(ranges); s.groupsErr != nil {
+			s.groups = nil
+			return
+		}
+		s.groups = make(map[string]SlotRange, len(ranges))
+		for _, r := range ranges {
+			s.groups[r.Name] = r
+		}
+	}
+}
+
+//SlotRange - The configured range of the group
+func (s *scanner) SlotRange(name string) (SlotRange, error) {
+	if s.groupsErr != nil {
+		return SlotRange{}, s.groupsErr
+	}
+	r, ok := s.groups[name]
+	if !ok {
+		return SlotRange{}, fmt.Errorf("%w: %s", ErrUnknownGroup, name)
+	}
+	return r, nil
+}
+
+//SearchGroup - Searches the char buffer in the positions of the named group only. The high speed search is used
+//on modules that have it, others run the normal search. Positions past the storage capacity are not searched.
+func (s *scanner) SearchGroup(charBufferNo int, name string) (*SearchResult, error) {
+	r, err := s.SlotRange(name)
+	if err != nil {
+		return nil, err
+	}
+	if capacity := s.getStorageCapacity(); r.Last >= capacity {
+		if r.First >= capacity {
+			return &SearchResult{-1, -1}, nil
+		}
+		r.Last = capacity - 1
+	}
+	result, err := s.FastSearchTemplate(charBufferNo, r.First, r.Count())
+	if err == ErrNotSupported {
+		result, err = s.SearchTemplate(charBufferNo, r.First, r.Count())
+	}
+	return result, err
+}
+
+//FastSearchTemplate - SearchTemplate with the high speed search of the module, ErrNotSupported if it has none
+func (s *scanner) FastSearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error) {
+	result, err := s.fastSearchTemplate(charBufferNo, startPos, count)
+	if err != ErrNotSupported {
+		s.record("fast_search", searchFields(result), err)
+	}
+	return result, err
+}
+
+func (s *scanner) fastSearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error) {
+	if charBufferNo != FINGERPRINT_CHARBUFFER1 && charBufferNo != FINGERPRINT_CHARBUFFER2 {
+		return nil, errors.New("the given charbuffer number is invalid")
+	}
+	if count <= 0 {
+		count = s.getStorageCapacity() - startPos
+	}
+
+	var responsePacket *ThumbPacket
+	var errorFound bool
+	var errorCode int
+	err := s.retry("fast_search", false, func() (err error) {
+		responsePacket, err = s.optionalCommand(FINGERPRINT_FASTSEARCH, getPayloadForFastSearch(charBufferNo, startPos, count))
+		if err != nil {
+			return err
+		}
+		errorFound, errorCode, err = anyCommonErrors(responsePacket)
+		return err
+	})
+	if errorFound == false && errorCode == FINGERPRINT_ERROR_NOTEMPLATEFOUND {
+		return &SearchResult{-1, -1}, nil
+	}
+	if err != nil {
+		if err != ErrNotSupported {
+			log.Println(err.Error())
+		}
+		return nil, err
+	}
+
+	result := &SearchResult{}
+	if err = decodePayload(result, responsePacket.PayLoad); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//IdentifyGroup - Captures a finger and searches the positions of the named group
+func IdentifyGroup(ctx context.Context, s ScannerIO, name string) (*SearchResult, error) {
+	if _, err := s.SlotRange(name); err != nil {
+		return nil, err
+	}
+	if err := waitForFinger(ctx, s, FINGERPRINT_CHARBUFFER1); err != nil {
+		return nil, err
+	}
+	result, err := s.SearchGroup(FINGERPRINT_CHARBUFFER1, name)
+	observeIdentification(s, result, err)
+	if err != nil {
+		return nil, err
+	}
+	if result.PositionNumber < 0 {
+		return result, ErrNoMatch
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseSlotRange(t *testing.T) {
+	r, err := ParseSlotRange(" staff : 0 - 299")
+	if err != nil || r != (SlotRange{"staff", 0, 299}) || r.Count() != 300 {
+		t.Errorf("staff = %v, %v", r, err)
+	}
+	if r, err := ParseSlotRange("vip: 7"); err != nil || r.Count() != 1 || !r.Contains(7) {
+		t.Errorf("vip = %v, %v", r, err)
+	}
+	for _, bad := range []string{"staff", ": 1-2", "x: 5-1", "x: a-3", "x: -1-3"} {
+		if r, err := ParseSlotRange(bad); err == nil {
+			t.Errorf("%q = %v", bad, r)
+		}
+	}
+}
+
+func TestValidateSlotRanges(t *testing.T) {
+	staff, visitors := SlotRange{"staff", 0, 299}, SlotRange{"visitors", 300, 999}
+	if err := ValidateSlotRanges([]SlotRange{visitors, staff}); err != nil {
+		t.Error(err)
+	}
+	for name, ranges := range map[string][]SlotRange{
+		"overlap":   {visitors, staff, {"overlap", 299, 310}},
+		"duplicate": {staff, {"staff", 400, 401}},
+		"empty":     {{"empty", 5, 4}},
+		"negative":  {{"negative", -3, 4}},
+	} {
+		if err := ValidateSlotRanges(ranges); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	//The option refuses them as well
+	s, l := newScripted(ack, WithSlotRanges(staff, SlotRange{"overlap", 299, 310}))
+	if _, err := s.SlotRange("staff"); err == nil {
+		t.Error("group of invalid ranges found")
+	}
+	if err := s.Capture(); err == nil || len(l.sent) != 0 {
+		t.Errorf("Capture = %v, sent %x", err, l.sent)
+	}
+}
+
+//searchModule - Finds the finger at the first searched position, fast tells if the module has the high speed search
+func searchModule(fast *bool) func(command []byte) [][]byte {
+	return func(command []byte) [][]byte {
+		switch command[0] {
+		case FINGERPRINT_FASTSEARCH:
+			if !*fast {
+				return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION}}
+			}
+			return [][]byte{{FINGERPRINT_OK, command[2], command[3], 0, 77}}
+		case FINGERPRINT_SEARCHTEMPLATE:
+			return [][]byte{{FINGERPRINT_ERROR_NOTEMPLATEFOUND, 0, 0, 0, 0}}
+		}
+		return ack(command)
+	}
+}
+
+func TestSearchGroup(t *testing.T) {
+	fast := true
+	s, l := newScripted(searchModule(&fast), WithSlotRanges(SlotRange{"staff", 0, 99}, SlotRange{"visitors", 100, 149}))
+	result, err := s.SearchGroup(FINGERPRINT_CHARBUFFER1, "visitors")
+	if err != nil || result.PositionNumber != 100 || result.AccuracyScore != 77 {
+		t.Fatalf("SearchGroup = %+v, %v", result, err)
+	}
+	if last := l.sent[len(l.sent)-1]; last[0] != FINGERPRINT_FASTSEARCH || be16(last[2:]) != 100 || be16(last[4:]) != 50 {
+		t.Errorf("sent %x", last)
+	}
+
+	//Modules without the high speed search run the normal one, the missing instruction is remembered
+	fast = false
+	s.unsupported, s.answered = nil, nil
+	if result, err = s.SearchGroup(FINGERPRINT_CHARBUFFER1, "staff"); err != nil || result.PositionNumber != -1 {
+		t.Fatalf("SearchGroup = %+v, %v", result, err)
+	}
+	if last := l.sent[len(l.sent)-1]; last[0] != FINGERPRINT_SEARCHTEMPLATE || be16(last[2:]) != 0 || be16(last[4:]) != 100 {
+		t.Errorf("sent %x", last)
+	}
+	l.sent = nil
+	s.SearchGroup(FINGERPRINT_CHARBUFFER1, "staff")
+	if len(l.sent) != 1 {
+		t.Errorf("sent %x", l.sent)
+	}
+
+	if _, err = s.SearchGroup(FINGERPRINT_CHARBUFFER1, "nobody"); !errors.Is(err, ErrUnknownGroup) {
+		t.Errorf("unknown group: %v", err)
+	}
+	l.sent = nil
+	if _, err = IdentifyGroup(context.Background(), s, "nobody"); !errors.Is(err, ErrUnknownGroup) || len(l.sent) != 0 {
+		t.Errorf("IdentifyGroup = %v, sent %x", err, l.sent)
+	}
+}
+
+func TestSearchGroupCapacity(t *testing.T) {
+	fast := true
+	//The library has 200 positions
+	s, l := newScripted(searchModule(&fast), WithSlotRanges(SlotRange{"staff", 150, 299}, SlotRange{"visitors", 300, 999}))
+	if _, err := s.SearchGroup(FINGERPRINT_CHARBUFFER1, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if last := l.sent[len(l.sent)-1]; be16(last[2:]) != 150 || be16(last[4:]) != 50 {
+		t.Errorf("sent %x", last)
+	}
+	l.sent = nil
+	if result, err := s.SearchGroup(FINGERPRINT_CHARBUFFER1, "visitors"); err != nil || result.PositionNumber != -1 || len(l.sent) != 0 {
+		t.Errorf("group past the end = %+v, %v, sent %x", result, err, l.sent)
+	}
+}
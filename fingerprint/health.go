@@ -0,0 +1,138 @@
+package fingerprint
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+//ErrSensorAbnormal - CheckSensor found the sensor hardware faulty
+var ErrSensorAbnormal = errors.New("the sensor is abnormal")
+
+//Sensor states reported by HealthCheck
+const (
+	SensorOK       = "ok"
+	SensorAbnormal = "abnormal"
+	SensorUnknown  = "unknown"
+)
+
+//Health - Result of HealthCheck
+type Health struct {
+	Latency          time.Duration `json:"latency_ns"`
+	Sensor           string        `json:"sensor"`
+	Busy             bool          `json:"busy"`
+	Pass             bool          `json:"pass"`
+	PasswordVerified bool          `json:"password_verified"`
+	ImageBufferValid bool          `json:"image_buffer_valid"`
+	Templates        int           `json:"templates"`
+	Capacity         int           `json:"capacity"`
+	Product          *ProductInfo  `json:"product,omitempty"`
+}
+
+//Handshake - Checks the module is up and answering, older modules return ErrNotSupported
+func (s *scanner) Handshake() error {
+	responsePacket, err := s.optionalCommand(FINGERPRINT_HANDSHAKE, getPayloadForHandshake())
+	if err != nil {
+		return err
+	}
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
+	}
+	return nil
+}
+
+//CheckSensor - Runs the self test of the sensor, ErrSensorAbnormal when it fails. Older modules return ErrNotSupported
+func (s *scanner) CheckSensor() error {
+	responsePacket, err := s.optionalCommand(FINGERPRINT_CHECKSENSOR, getPayloadForCheckSensor())
+	if err != nil {
+		return err
+	}
+
+	if len(responsePacket.PayLoad) > 0 && responsePacket.PayLoad[0] == FINGERPRINT_ERROR_SENSORABNORMAL {
+		return ErrSensorAbnormal
+	}
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
+	}
+	return nil
+}
+
+//GenerateRandomNumber - Returns a 32 bit random number from the module, the GetRandomCode instruction
+func (s *scanner) GenerateRandomNumber() (uint32, error) {
+	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, getPayloadForGenerateRandomNumber())
+	if errWrite != nil {
+		return 0, errWrite
+	}
+
+	responsePacket, errRead := s.readPacket()
+	if errRead != nil {
+		return 0, errRead
+	}
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return 0, errDesc
+	}
+	if len(responsePacket.PayLoad) < 5 {
+		return 0, errShortPayload
+	}
+	return uint32(be32(responsePacket.PayLoad[1:])), nil
+}
+
+//HealthCheck - Cheap liveness probe: round trip time of a handshake, sensor self test, product info, status flags
+//and library occupancy. Modules without Handshake are timed on reading the system parameters instead.
+func HealthCheck(s ScannerIO) (*Health, error) {
+	h := &Health{Sensor: SensorOK}
+
+	start := time.Now()
+	err := s.Handshake()
+	if err != nil && err != ErrNotSupported {
+		return nil, err
+	}
+	handshake := err == nil
+	if handshake {
+		h.Latency = time.Since(start)
+	}
+
+	switch err = s.CheckSensor(); err {
+	case nil:
+	case ErrSensorAbnormal:
+		h.Sensor = SensorAbnormal
+	case ErrNotSupported:
+		h.Sensor = SensorUnknown
+	default:
+		return nil, err
+	}
+
+	if h.Product, err = s.ProductInfo(); err != nil && err != ErrNotSupported {
+		return nil, err
+	}
+
+	start = time.Now()
+	param, err := s.GetSystemParameters()
+	if err != nil {
+		return nil, err
+	}
+	if !handshake {
+		h.Latency = time.Since(start)
+	}
+	h.Busy = param.Busy()
+	h.Pass = param.Pass()
+	h.PasswordVerified = param.PasswordVerified()
+	h.ImageBufferValid = param.ImageBufferValid()
+	h.Capacity = int(param.StorageCapacity)
+
+	index, err := s.TemplateIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, used := range index {
+		if used {
+			h.Templates++
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,135 @@
+package fingerprint
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//productInfoR503 - ReadProdInfo answer of an R503 with 200 positions
+func productInfoR503() []byte {
+	info := make([]byte, 1+productInfoSize)
+	copy(info[1:], "R503")
+	copy(info[17:], "B001")
+	copy(info[21:], "12345678")
+	info[29], info[30] = 1, 3
+	copy(info[31:], "FPC1021 ")
+	info[40], info[42] = 192, 192
+	info[43] = 0x06
+	info[46] = 200
+	return info
+}
+
+//healthModule - Busy module with the password verified and templates at 0 and 7. Instructions in missing are
+//rejected as by modules that do not know them, CheckSensor answers sensor.
+func healthModule(sensor byte, missing ...byte) func([]byte) [][]byte {
+	return func(command []byte) [][]byte {
+		for _, instruction := range missing {
+			if command[0] == instruction {
+				return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION}}
+			}
+		}
+		switch command[0] {
+		case FINGERPRINT_CHECKSENSOR:
+			return [][]byte{{sensor}}
+		case FINGERPRINT_READPRODINFO:
+			return [][]byte{productInfoR503()}
+		case FINGERPRINT_GETSYSTEMPARAMETERS:
+			return [][]byte{{FINGERPRINT_OK, 0, StatusBusy | StatusPasswordVerified, 0, 9, 0, 200, 0, 3, 0xFF, 0xFF, 0xFF, 0xFF, 0, 2, 0, 6}}
+		case FINGERPRINT_TEMPLATEINDEX:
+			page := make([]byte, 33)
+			if command[1] == 0 {
+				page[1] = 0x81
+			}
+			return [][]byte{page}
+		case FINGERPRINT_GENERATERANDOMNUMBER:
+			return [][]byte{{FINGERPRINT_OK, 0xDE, 0xAD, 0xBE, 0xEF}}
+		}
+		return ack(command)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		sensor  byte
+		missing []byte
+		want    string
+		product bool
+	}{
+		{"All", FINGERPRINT_OK, nil, SensorOK, true},
+		{"Abnormal", FINGERPRINT_ERROR_SENSORABNORMAL, nil, SensorAbnormal, true},
+		{"NoHandshake", FINGERPRINT_OK, []byte{FINGERPRINT_HANDSHAKE}, SensorOK, true},
+		{"NoCheckSensor", FINGERPRINT_OK, []byte{FINGERPRINT_CHECKSENSOR}, SensorUnknown, true},
+		{"NoProductInfo", FINGERPRINT_OK, []byte{FINGERPRINT_READPRODINFO}, SensorOK, false},
+		{"None", FINGERPRINT_OK, []byte{FINGERPRINT_HANDSHAKE, FINGERPRINT_CHECKSENSOR, FINGERPRINT_READPRODINFO}, SensorUnknown, false},
+	} {
+		s, l := newScripted(healthModule(c.sensor, c.missing...))
+		//Without Handshake the latency is that of reading the system parameters
+		l.hold = 5 * time.Millisecond
+		h, err := HealthCheck(s)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if h.Sensor != c.want || (h.Product != nil) != c.product || h.Latency < l.hold {
+			t.Errorf("%s: sensor %s, product %v, latency %v", c.name, h.Sensor, h.Product, h.Latency)
+		}
+		if !h.Busy || h.Pass || !h.PasswordVerified || h.ImageBufferValid || h.Templates != 2 || h.Capacity != 200 {
+			t.Errorf("%s: %+v", c.name, h)
+		}
+
+		//Missing instructions are sent twice and then never again
+		for _, instruction := range c.missing {
+			if n := countSent(l, instruction); n != 2 {
+				t.Errorf("%s: %#x sent %d times", c.name, instruction, n)
+			}
+		}
+		if _, err = HealthCheck(s); err != nil {
+			t.Errorf("%s: second check: %v", c.name, err)
+		}
+		for _, instruction := range c.missing {
+			if n := countSent(l, instruction); n != 2 {
+				t.Errorf("%s: %#x sent %d times after the second check", c.name, instruction, n)
+			}
+		}
+	}
+}
+
+func TestHealthCheckErrors(t *testing.T) {
+	//Once answered, a rejected handshake is a failure and not a missing instruction
+	rejected := false
+	s, _ := newScripted(func(command []byte) [][]byte {
+		if rejected && command[0] == FINGERPRINT_HANDSHAKE {
+			return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION}}
+		}
+		return healthModule(FINGERPRINT_OK)(command)
+	})
+	if _, err := HealthCheck(s); err != nil {
+		t.Fatal(err)
+	}
+	rejected = true
+	if h, err := HealthCheck(s); err != ErrCommunication {
+		t.Errorf("rejected handshake = %+v, %v", h, err)
+	}
+
+	s, _ = newScripted(func(command []byte) [][]byte {
+		if command[0] == FINGERPRINT_GETSYSTEMPARAMETERS {
+			return [][]byte{{FINGERPRINT_OK}}
+		}
+		return healthModule(FINGERPRINT_OK)(command)
+	})
+	if h, err := HealthCheck(s); err == nil {
+		t.Errorf("short system parameters = %+v", h)
+	}
+}
+
+func TestCheckSensor(t *testing.T) {
+	s, _ := newScripted(healthModule(FINGERPRINT_ERROR_SENSORABNORMAL))
+	if err := s.CheckSensor(); !errors.Is(err, ErrSensorAbnormal) {
+		t.Errorf("CheckSensor = %v", err)
+	}
+	if v, err := s.GenerateRandomNumber(); v != 0xDEADBEEF || err != nil {
+		t.Errorf("GenerateRandomNumber = %#x, %v", v, err)
+	}
+}
@@ -0,0 +1,162 @@
+package fingerprint
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"time"
+)
+
+//Image formats of WriteImage
+const (
+	ImagePNG = "png"
+	ImageBMP = "bmp"
+	ImagePGM = "pgm"
+	ImageRaw = "raw"
+)
+
+//imageSizes - Geometry of the modules by the size of the 4 bit image they send
+var imageSizes = map[int]image.Point{
+	256 * 288 / 2: {256, 288},
+	192 * 192 / 2: {192, 192},
+}
+
+//DecodeImage - Converts an image read with DownloadImage into 8 bit grey levels. The sensor sends two pixels
+//per byte, high nibble first, levels 0-15 are scaled to 0-255. Width and height may be 0 for the known sizes.
+func DecodeImage(raw []byte, width int, height int) (*image.Gray, error) {
+	if width <= 0 || height <= 0 {
+		size, ok := imageSizes[len(raw)]
+		if !ok {
+			return nil, fmt.Errorf("unknown image size of %d bytes, width and height are needed", len(raw))
+		}
+		width, height = size.X, size.Y
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	switch len(raw) {
+	case width * height / 2:
+		for i, b := range raw {
+			img.Pix[2*i] = (b >> 4) * 17
+			img.Pix[2*i+1] = (b & 0x0F) * 17
+		}
+	case width * height:
+		//Some modules send a full byte per pixel
+		copy(img.Pix, raw)
+	default:
+		return nil, fmt.Errorf("image of %d bytes does not fit %dx%d", len(raw), width, height)
+	}
+	return img, nil
+}
+
+//PackImage - Reverse of DecodeImage, 4 bit levels two pixels per byte as the sensor expects them
+func PackImage(img *image.Gray) []byte {
+	bounds := img.Bounds()
+	raw := make([]byte, 0, (bounds.Dx()*bounds.Dy()+1)/2)
+	var pending byte
+	odd := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			//Rounded to the nearest of the 16 levels
+			level := byte((int(img.GrayAt(x, y).Y) + 8) / 17)
+			if odd {
+				raw = append(raw, pending|level)
+			} else {
+				pending = level << 4
+			}
+			odd = !odd
+		}
+	}
+	if odd {
+		raw = append(raw, pending)
+	}
+	return raw
+}
+
+//WriteImage - Encodes the image as PNG, 8 bit BMP, binary PGM or the raw 4 bit sensor format
+func WriteImage(w io.Writer, img *image.Gray, format string) error {
+	switch format {
+	case ImagePNG:
+		return png.Encode(w, img)
+	case ImageBMP:
+		return writeBMP(w, img)
+	case ImagePGM:
+		return writePGM(w, img)
+	case ImageRaw:
+		_, err := w.Write(PackImage(img))
+		return err
+	}
+	return fmt.Errorf("unknown image format %q", format)
+}
+
+//writePGM - Netpbm P5 with 8 bit levels
+func writePGM(w io.Writer, img *image.Gray) error {
+	bounds := img.Bounds()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P5\n%d %d\n255\n", bounds.Dx(), bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		start := img.PixOffset(bounds.Min.X, y)
+		bw.Write(img.Pix[start : start+bounds.Dx()])
+	}
+	return bw.Flush()
+}
+
+//writeBMP - 8 bit palette of grey levels, rows bottom-up and padded to 4 bytes
+func writeBMP(w io.Writer, img *image.Gray) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	stride := (width + 3) &^ 3
+	const headerSize = 14 + 40 + 256*4
+	imageSize := stride * height
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	header := make([]byte, headerSize)
+	copy(header, "BM")
+	le.PutUint32(header[2:], uint32(headerSize+imageSize))
+	le.PutUint32(header[10:], headerSize)
+	le.PutUint32(header[14:], 40)
+	le.PutUint32(header[18:], uint32(width))
+	le.PutUint32(header[22:], uint32(height))
+	le.PutUint16(header[26:], 1)
+	le.PutUint16(header[28:], 8)
+	le.PutUint32(header[34:], uint32(imageSize))
+	le.PutUint32(header[38:], 2835) //72 dpi
+	le.PutUint32(header[42:], 2835)
+	le.PutUint32(header[46:], 256)
+	for i := 0; i < 256; i++ {
+		palette := header[54+4*i:]
+		palette[0], palette[1], palette[2] = byte(i), byte(i), byte(i)
+	}
+	bw.Write(header)
+
+	row := make([]byte, stride)
+	for y := bounds.Max.Y - 1; y >= bounds.Min.Y; y-- {
+		start := img.PixOffset(bounds.Min.X, y)
+		copy(row, img.Pix[start:start+width])
+		bw.Write(row)
+	}
+	return bw.Flush()
+}
+
+//ImageMetadata - Sidecar stored next to an exported image for review
+type ImageMetadata struct {
+	Scanner string        `json:"scanner,omitempty"`
+	Model   string        `json:"model,omitempty"`
+	Sensor  string        `json:"sensor,omitempty"`
+	Time    time.Time     `json:"time"`
+	Width   int           `json:"width"`
+	Height  int           `json:"height"`
+	Format  string        `json:"format"`
+	Quality *ImageQuality `json:"quality,omitempty"`
+}
+
+//WriteImageMetadata - Writes the sidecar as indented JSON
+func WriteImageMetadata(w io.Writer, m *ImageMetadata) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
@@ -0,0 +1,166 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+//gradient - 6x5 image of all 16 levels, odd in both directions so BMP rows need padding
+func gradient() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 6, 5))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i%16) * 17
+	}
+	return img
+}
+
+//golden - Compares got with the file in testdata, -update rewrites it
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := "testdata/" + name
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs\ngot  %x\nwant %x", path, got, want)
+	}
+}
+
+func TestWriteImage(t *testing.T) {
+	img := gradient()
+	for _, format := range []string{ImagePNG, ImagePGM, ImageBMP, ImageRaw} {
+		var buf bytes.Buffer
+		if err := WriteImage(&buf, img, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		golden(t, "gradient."+format, buf.Bytes())
+	}
+	if err := WriteImage(ioutil.Discard, img, "jpeg"); err == nil {
+		t.Error("jpeg accepted")
+	}
+}
+
+func TestWriteImageLayout(t *testing.T) {
+	img := gradient()
+	var buf bytes.Buffer
+	WriteImage(&buf, img, ImagePGM)
+	if pgm := buf.Bytes(); !bytes.Equal(pgm[:11], []byte("P5\n6 5\n255\n")) || !bytes.Equal(pgm[11:], img.Pix) {
+		t.Errorf("PGM %q", pgm)
+	}
+
+	buf.Reset()
+	WriteImage(&buf, img, ImagePNG)
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gray, ok := decoded.(*image.Gray); !ok || !bytes.Equal(gray.Pix, img.Pix) {
+		t.Errorf("PNG decoded to %T", decoded)
+	}
+
+	//Rows bottom-up, padded from 6 to 8 bytes
+	buf.Reset()
+	WriteImage(&buf, img, ImageBMP)
+	bmp := buf.Bytes()
+	if len(bmp) != 1078+8*5 || binary.LittleEndian.Uint32(bmp[2:]) != uint32(len(bmp)) {
+		t.Fatalf("BMP of %d bytes", len(bmp))
+	}
+	if !bytes.Equal(bmp[1078:1084], img.Pix[24:30]) || bmp[1084] != 0 || bmp[1085] != 0 {
+		t.Errorf("first BMP row %x", bmp[1078:1086])
+	}
+
+	//A sub image starts at its own bounds
+	buf.Reset()
+	WriteImage(&buf, img.SubImage(image.Rect(2, 1, 4, 3)).(*image.Gray), ImagePGM)
+	if got := buf.Bytes()[11:]; !bytes.Equal(got, []byte{img.Pix[8], img.Pix[9], img.Pix[14], img.Pix[15]}) {
+		t.Errorf("sub image %x", got)
+	}
+}
+
+func TestDecodeImage(t *testing.T) {
+	raw := make([]byte, 256*288/2)
+	for i := range raw {
+		raw[i] = byte(i*7) ^ byte(i>>8)
+	}
+	img, err := DecodeImage(raw, 0, 0)
+	if err != nil || img.Bounds() != image.Rect(0, 0, 256, 288) {
+		t.Fatal(img.Bounds(), err)
+	}
+	if img.Pix[0] != (raw[0]>>4)*17 || img.Pix[1] != (raw[0]&15)*17 || !bytes.Equal(PackImage(img), raw) {
+		t.Error("pixels are not two 4 bit levels a byte, high nibble first")
+	}
+	if img, err := DecodeImage(make([]byte, 192*192/2), 0, 0); err != nil || img.Bounds().Dx() != 192 {
+		t.Errorf("192x192: %v", err)
+	}
+	if img, err := DecodeImage(bytes.Repeat([]byte{9}, 12), 4, 3); err != nil || img.Pix[11] != 9 {
+		t.Errorf("8 bit: %v", err)
+	}
+	if _, err := DecodeImage(raw[:100], 0, 0); err == nil {
+		t.Error("unknown size accepted")
+	}
+	if _, err := DecodeImage(raw[:100], 16, 16); err == nil {
+		t.Error("100 bytes accepted as 16x16")
+	}
+
+	//An odd pixel count leaves the low nibble of the last byte empty
+	odd := image.NewGray(image.Rect(0, 0, 3, 1))
+	odd.Pix = []byte{255, 8, 26}
+	if got := PackImage(odd); !bytes.Equal(got, []byte{0xF0, 0x20}) {
+		t.Errorf("odd image packed to %x", got)
+	}
+}
+
+func TestUploadImage(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	s, l := newScripted(ack)
+	if err := s.UploadImage(data); err != nil {
+		t.Fatal(err)
+	}
+	//128 bytes a packet at packet length code 2
+	if len(l.sent) != 4 || !bytes.Equal(l.sent[0], []byte{FINGERPRINT_UPLOADIMAGE}) {
+		t.Fatalf("sent %x", l.sent)
+	}
+	if !bytes.Equal(l.types, []byte{FINGERPRINT_COMMANDPACKET, FINGERPRINT_DATAPACKET, FINGERPRINT_DATAPACKET, FINGERPRINT_ENDDATAPACKET}) {
+		t.Errorf("packet types %x", l.types)
+	}
+	if len(l.sent[1]) != 128 || len(l.sent[2]) != 128 || !bytes.Equal(bytes.Join(l.sent[1:], nil), data) {
+		t.Errorf("split into %d, %d and %d bytes", len(l.sent[1]), len(l.sent[2]), len(l.sent[3]))
+	}
+
+	//An image of whole packets ends with a full END packet
+	s, l = newScripted(ack)
+	s.param.PacketLength = 0
+	if err := s.UploadImage(data[:64]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(l.types, []byte{FINGERPRINT_COMMANDPACKET, FINGERPRINT_DATAPACKET, FINGERPRINT_ENDDATAPACKET}) || len(l.sent[2]) != 32 {
+		t.Errorf("packet types %x, sent %x", l.types, l.sent)
+	}
+
+	//Nothing is sent when the module refuses the upload
+	s, l = newScripted(func(command []byte) [][]byte {
+		return [][]byte{{FINGERPRINT_PACKETRESPONSEFAIL}}
+	})
+	if err := s.UploadImage(data); err == nil || len(l.sent) != 1 {
+		t.Errorf("refused upload = %v, sent %x", err, l.sent)
+	}
+	if err := s.UploadImage(nil); err == nil {
+		t.Error("empty image accepted")
+	}
+}
@@ -0,0 +1,61 @@
+package fingerprint
+
+import (
+	"fmt"
+	"log"
+)
+
+//LEDColor - Colour of the Aura LED ring, three colour rings only know red, blue and purple
+type LEDColor int
+
+//LED colours
+const (
+	LEDRed LEDColor = iota + 1
+	LEDBlue
+	LEDPurple
+	LEDGreen
+	LEDYellow
+	LEDCyan
+	LEDWhite
+)
+
+//LEDMode - How the Aura LED ring lights up
+type LEDMode int
+
+//LED modes
+const (
+	LEDBreathing LEDMode = iota + 1
+	LEDFlashing
+	LEDOn
+	LEDOff
+	LEDFadeIn
+	LEDFadeOut
+)
+
+func getPayloadForAuraLed(color LEDColor, mode LEDMode, speed int, count int) []byte {
+	return []byte{FINGERPRINT_AURALEDCONFIG, byte(mode), byte(speed), byte(color), byte(count)}
+}
+
+//SetLED - Controls the LED ring of R502-A/R503 modules, speed (0-255) sets the breathing and flashing period,
+//count (0-255) the number of cycles with 0 repeating forever. Other modules return ErrNotSupported.
+func (s *scanner) SetLED(color LEDColor, mode LEDMode, speed int, count int) error {
+	if color < LEDRed || color > LEDWhite {
+		return fmt.Errorf("invalid LED colour %d", color)
+	}
+	if mode < LEDBreathing || mode > LEDFadeOut {
+		return fmt.Errorf("invalid LED mode %d", mode)
+	}
+	if speed < 0 || speed > 0xFF || count < 0 || count > 0xFF {
+		return fmt.Errorf("LED speed and count have to be 0-255")
+	}
+
+	tp, err := s.optionalCommand(FINGERPRINT_AURALEDCONFIG, getPayloadForAuraLed(color, mode, speed, count))
+	if err != nil {
+		return err
+	}
+	if _, _, errDesc := anyCommonErrors(tp); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package fingerprint
+
+import "time"
+
+//scriptLink - Transport answering every command with the ack payloads returned by reply, a packet per read
+type scriptLink struct {
+	reply func(command []byte) [][]byte
+	//sent - Payloads of the commands and data packets written so far, types their packet types
+	sent  [][]byte
+	types []byte
+	//queue - Chunks not read yet
+	queue [][]byte
+	//delay - Before every chunk is read
+	delay time.Duration
+	//hold - Responses arrive this long after the command, readyAt
+	hold    time.Duration
+	readyAt time.Time
+}
+
+func (l *scriptLink) open() error {
+	return nil
+}
+
+func (l *scriptLink) close() {
+}
+
+func (l *scriptLink) write(packet []byte) (int, error) {
+	command := append([]byte(nil), packet[packetHeaderSize:len(packet)-2]...)
+	l.sent = append(l.sent, command)
+	l.types = append(l.types, packet[6])
+	//Data packets are not acknowledged
+	if packet[6] != FINGERPRINT_COMMANDPACKET {
+		return len(packet), nil
+	}
+	l.readyAt = time.Now().Add(l.hold)
+	for _, payload := range l.reply(command) {
+		l.queue = append(l.queue, buildCommandPacket(FINGERPRINT_ACKPACKET, payload))
+	}
+	return len(packet), nil
+}
+
+func (l *scriptLink) readFragement(readSize int) ([]byte, int, error) {
+	buf := make([]byte, readSize)
+	if len(l.queue) == 0 || time.Now().Before(l.readyAt) {
+		time.Sleep(time.Millisecond)
+		return buf, 0, nil
+	}
+	time.Sleep(l.delay)
+	n := copy(buf, l.queue[0])
+	if l.queue[0] = l.queue[0][n:]; len(l.queue[0]) == 0 {
+		l.queue = l.queue[1:]
+	}
+	return buf, n, nil
+}
+
+//newScripted - Scanner of 200 positions on a scriptLink, as if Capture had run
+func newScripted(reply func(command []byte) [][]byte, opts ...Option) (*scanner, *scriptLink) {
+	l := &scriptLink{reply: reply}
+	s := &scanner{link: l, param: &SystemParameters{StorageCapacity: 200, SecurityLevel: 3, PacketLength: 2}}
+	s.applyOptions(opts)
+	return s, l
+}
+
+//ack - reply answering every command with FINGERPRINT_OK
+func ack(command []byte) [][]byte {
+	return [][]byte{{FINGERPRINT_OK}}
+}
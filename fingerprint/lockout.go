@@ -0,0 +1,224 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//ErrLockedOut - Identification is blocked after too many failed attempts
+var ErrLockedOut = errors.New("locked out after too many failed attempts")
+
+//LockoutError - ErrLockedOut together with the end of the lockout window
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrLockedOut.Error(), e.Until.Format(time.RFC3339))
+}
+
+//Is - Matches ErrLockedOut
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrLockedOut
+}
+
+//Kinds of security events
+const (
+	//EventFailure - A presented finger did not match
+	EventFailure = "failure"
+	//EventLockout - Too many failures, attempts are refused until the window ends
+	EventLockout = "lockout"
+	//EventRejected - An attempt was refused during a lockout window
+	EventRejected = "rejected"
+	//EventReset - A finger matched after failures, the count starts over
+	EventReset = "reset"
+)
+
+//SecurityEvent - Reported by Guard for monitoring and alerting. Position is the claimed one for verify and -1 for
+//identify, Until is only set on lockout and rejected events.
+type SecurityEvent struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Scanner   string    `json:"scanner,omitempty"`
+	Operation string    `json:"operation"`
+	Position  int       `json:"position"`
+	Failures  int       `json:"failures"`
+	Until     time.Time `json:"until"`
+}
+
+//Defaults of LockoutPolicy
+const (
+	DefaultMaxFailures = 5
+	DefaultLockout     = 30 * time.Second
+	DefaultMaxLockout  = time.Hour
+)
+
+//LockoutPolicy - After MaxFailures consecutive failures attempts are refused for Lockout, every further failure
+//doubles the window up to MaxLockout. Zero values take the defaults.
+type LockoutPolicy struct {
+	MaxFailures int
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+	//OnEvent - Optional, called for every security event
+	OnEvent func(SecurityEvent)
+}
+
+//failureCount - Consecutive failures of the sensor or of one claimed position
+type failureCount struct {
+	failures int
+	until    time.Time
+}
+
+//Guard - Runs Identify and Verify under a LockoutPolicy. Failures are counted for the sensor, and for Verify
+//also for the claimed position, so guessing one user and sweeping the library are both slowed down.
+type Guard struct {
+	scanner ScannerIO
+	policy  LockoutPolicy
+	now     func() time.Time
+
+	mu     sync.Mutex
+	sensor failureCount
+	claims map[int]*failureCount
+}
+
+//NewGuard - Create Guard for the scanner, one per sensor
+func NewGuard(s ScannerIO, policy LockoutPolicy) *Guard {
+	if policy.MaxFailures <= 0 {
+		policy.MaxFailures = DefaultMaxFailures
+	}
+	if policy.Lockout <= 0 {
+		policy.Lockout = DefaultLockout
+	}
+	if policy.MaxLockout < policy.Lockout {
+		policy.MaxLockout = DefaultMaxLockout
+		if policy.MaxLockout < policy.Lockout {
+			policy.MaxLockout = policy.Lockout
+		}
+	}
+	return &Guard{scanner: s, policy: policy, now: time.Now, claims: make(map[int]*failureCount)}
+}
+
+//Identify - Identify unless the sensor is locked out, a finger matching no template counts as failure
+func (g *Guard) Identify(ctx context.Context) (*SearchResult, error) {
+	if err := g.admit("identify", -1); err != nil {
+		return nil, err
+	}
+	result, err := Identify(ctx, g.scanner)
+	g.record("identify", -1, err)
+	return result, err
+}
+
+//IdentifyGroup - IdentifyGroup unless the sensor is locked out, failures count for the whole sensor
+func (g *Guard) IdentifyGroup(ctx context.Context, name string) (*SearchResult, error) {
+	if err := g.admit("identify", -1); err != nil {
+		return nil, err
+	}
+	result, err := IdentifyGroup(ctx, g.scanner, name)
+	g.record("identify", -1, err)
+	return result, err
+}
+
+//Verify - Verify unless the sensor or the claimed position is locked out
+func (g *Guard) Verify(ctx context.Context, position int) (int, error) {
+	if err := g.admit("verify", position); err != nil {
+		return 0, err
+	}
+	score, err := Verify(ctx, g.scanner, position)
+	g.record("verify", position, err)
+	return score, err
+}
+
+//admit - LockoutError while the sensor or the claimed position, -1 for none, is inside its lockout window
+func (g *Guard) admit(operation string, position int) error {
+	g.mu.Lock()
+	now := g.now()
+	until := g.sensor.until
+	failures := g.sensor.failures
+	if claim := g.claims[position]; claim != nil && claim.until.After(until) {
+		until, failures = claim.until, claim.failures
+	}
+	g.mu.Unlock()
+
+	if !now.Before(until) {
+		return nil
+	}
+	g.emit(SecurityEvent{Time: now, Kind: EventRejected, Operation: operation, Position: position, Failures: failures, Until: until})
+	return &LockoutError{Until: until}
+}
+
+//record - Counts a mismatch as failure and resets the counts on a match, other errors such as no finger
+//presented in time are not counted. A claimed position is only tracked while it has failures.
+func (g *Guard) record(operation string, position int, err error) {
+	var events []SecurityEvent
+	g.mu.Lock()
+	now := g.now()
+	counts := []*failureCount{&g.sensor}
+	claim := g.claims[position]
+	if claim == nil && position >= 0 && err == ErrNoMatch {
+		claim = &failureCount{}
+		g.claims[position] = claim
+	}
+	if claim != nil {
+		counts = append(counts, claim)
+	}
+	failures, until := 0, time.Time{}
+	for _, c := range counts {
+		switch err {
+		case nil:
+			if c.failures > failures {
+				failures = c.failures
+			}
+			*c = failureCount{}
+		case ErrNoMatch:
+			c.failures++
+			if c.failures >= g.policy.MaxFailures {
+				c.until = now.Add(g.window(c.failures))
+			}
+			if c.failures > failures {
+				failures = c.failures
+			}
+			if c.until.After(until) {
+				until = c.until
+			}
+		}
+	}
+	if claim != nil && claim.failures == 0 {
+		delete(g.claims, position)
+	}
+
+	switch {
+	case err == nil && failures > 0:
+		events = append(events, SecurityEvent{Time: now, Kind: EventReset, Operation: operation, Position: position, Failures: failures})
+	case err == ErrNoMatch:
+		events = append(events, SecurityEvent{Time: now, Kind: EventFailure, Operation: operation, Position: position, Failures: failures})
+		if until.After(now) {
+			events = append(events, SecurityEvent{Time: now, Kind: EventLockout, Operation: operation, Position: position, Failures: failures, Until: until})
+		}
+	}
+	g.mu.Unlock()
+
+	for _, ev := range events {
+		g.emit(ev)
+	}
+}
+
+//window - Lockout after the given number of failures, doubling from Lockout up to MaxLockout
+func (g *Guard) window(failures int) time.Duration {
+	d := g.policy.Lockout
+	for i := g.policy.MaxFailures; i < failures && d < g.policy.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > g.policy.MaxLockout {
+		d = g.policy.MaxLockout
+	}
+	return d
+}
+
+func (g *Guard) emit(ev SecurityEvent) {
+	if g.policy.OnEvent != nil {
+		g.policy.OnEvent(ev)
+	}
+}
@@ -0,0 +1,143 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//guardModule - Module without the on-device workflows, a finger matches position 4 while *match is set
+func guardModule(match *bool) func(command []byte) [][]byte {
+	return func(command []byte) [][]byte {
+		switch command[0] {
+		case FINGERPRINT_AUTOENROLL, FINGERPRINT_AUTOIDENTIFY:
+			return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION}}
+		case FINGERPRINT_SEARCHTEMPLATE:
+			if *match {
+				return [][]byte{{FINGERPRINT_OK, 0, 4, 0, 90}}
+			}
+			return [][]byte{{FINGERPRINT_ERROR_NOTEMPLATEFOUND, 0, 0, 0, 0}}
+		case FINGERPRINT_COMPARECHARACTERISTICS:
+			if *match {
+				return [][]byte{{FINGERPRINT_OK, 0, 80}}
+			}
+			return [][]byte{{FINGERPRINT_ERROR_NOTMATCHING, 0, 0}}
+		}
+		return ack(command)
+	}
+}
+
+//newGuard - Guard on a guardModule with a clock set by the test
+func newGuard(match *bool, policy LockoutPolicy) (*Guard, *time.Time) {
+	s, _ := newScripted(guardModule(match))
+	g := NewGuard(s, policy)
+	now := time.Unix(1000, 0)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestGuardWindow(t *testing.T) {
+	match := false
+	var kinds []string
+	g, now := newGuard(&match, LockoutPolicy{MaxFailures: 3, Lockout: time.Minute, MaxLockout: 5 * time.Minute,
+		OnEvent: func(ev SecurityEvent) { kinds = append(kinds, ev.Kind) }})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Identify(ctx); err != ErrNoMatch {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	var lockout *LockoutError
+	if _, err := g.Identify(ctx); !errors.As(err, &lockout) || !errors.Is(err, ErrLockedOut) || !lockout.Until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("inside the window: %v", err)
+	}
+	want := []string{EventFailure, EventFailure, EventFailure, EventLockout, EventRejected}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("events %v, want %v", kinds, want)
+	}
+
+	//Every further failure doubles the window, up to MaxLockout
+	for _, window := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		*now = now.Add(time.Hour)
+		g.Identify(ctx)
+		if _, err := g.Identify(ctx); !errors.As(err, &lockout) || lockout.Until.Sub(*now) != window {
+			t.Fatalf("window %v: %v", window, err)
+		}
+	}
+
+	//A match after the window starts the count over
+	*now = now.Add(time.Hour)
+	match = true
+	kinds = nil
+	if result, err := g.Identify(ctx); err != nil || result.PositionNumber != 4 {
+		t.Fatalf("Identify = %+v, %v", result, err)
+	}
+	match = false
+	if _, err := g.Identify(ctx); err != ErrNoMatch {
+		t.Errorf("first failure after the reset: %v", err)
+	}
+	if want := []string{EventReset, EventFailure}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("events %v, want %v", kinds, want)
+	}
+}
+
+func TestGuardClaims(t *testing.T) {
+	match := false
+	g, now := newGuard(&match, LockoutPolicy{MaxFailures: 3, Lockout: time.Minute})
+	ctx := context.Background()
+	identified := func() {
+		match = true
+		if _, err := g.Identify(ctx); err != nil {
+			t.Fatal(err)
+		}
+		match = false
+	}
+
+	//Other users matching reset the sensor count, the failures of the claimed position add up
+	for i := 0; i < 2; i++ {
+		if _, err := g.Verify(ctx, 7); err != ErrNoMatch {
+			t.Fatal(err)
+		}
+		identified()
+	}
+	if _, err := g.Verify(ctx, 7); err != ErrNoMatch {
+		t.Fatal(err)
+	}
+	if _, err := g.Verify(ctx, 7); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("claimed position: %v", err)
+	}
+	if _, err := g.Verify(ctx, 8); err != ErrNoMatch {
+		t.Errorf("other position: %v", err)
+	}
+
+	//A match of the claimed position forgets it
+	*now = now.Add(time.Minute)
+	match = true
+	if _, err := g.Verify(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, tracked := g.claims[7]; tracked || len(g.claims) != 1 {
+		t.Errorf("claims %v", g.claims)
+	}
+}
+
+func TestGuardRejectedClaims(t *testing.T) {
+	match := false
+	g, _ := newGuard(&match, LockoutPolicy{MaxFailures: 1})
+	ctx := context.Background()
+	if _, err := g.Identify(ctx); err != ErrNoMatch {
+		t.Fatal(err)
+	}
+	//Attempts refused during the sensor lockout leave nothing behind
+	for position := 0; position < 100; position++ {
+		if _, err := g.Verify(ctx, position); !errors.Is(err, ErrLockedOut) {
+			t.Fatalf("position %d: %v", position, err)
+		}
+	}
+	if len(g.claims) != 0 {
+		t.Errorf("%d claims tracked", len(g.claims))
+	}
+}
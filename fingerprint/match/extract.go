@@ -0,0 +1,300 @@
+package match
+
+import (
+	"image"
+	"math"
+)
+
+//Half the window of the local mean the ridges are binarized against
+const thresholdRadius = 7
+
+//Minutiae closer than this are broken ridges, bridges or pores and dropped in pairs
+const minDistance = 10
+
+//Skeleton pixels followed from a minutia to measure its direction
+const traceLength = 10
+
+//Extract - Finds the minutiae of a finger image with dark ridges on a light background, as the sensors send it.
+//The image is segmented into finger and background, binarized against the local mean, thinned to one pixel
+//wide ridges, and ridge endings and bifurcations are taken from the crossing number of the skeleton.
+func Extract(img *image.Gray) *Template {
+	bounds := img.Bounds()
+	g := &grid{width: bounds.Dx(), height: bounds.Dy()}
+	g.pix = make([]float64, g.width*g.height)
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			g.pix[y*g.width+x] = float64(img.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y)
+		}
+	}
+
+	mask := segment(img)
+	skeleton := thin(g.binarize(mask), g.width, g.height)
+	minutiae := g.findMinutiae(skeleton, mask)
+	return &Template{Width: g.width, Height: g.height, Minutiae: prune(minutiae)}
+}
+
+type grid struct {
+	width, height int
+	pix           []float64
+}
+
+//segment - Foreground blocks shrunk by one block, minutiae at the edge of the finger are mostly artefacts
+func segment(img *image.Gray) *blockMask {
+	blocks, cols, rows := Segment(img)
+	m := &blockMask{cols: cols, rows: rows}
+	fg := make([]bool, len(blocks))
+	for i := range blocks {
+		fg[i] = blocks[i].Foreground()
+	}
+
+	m.inner = make([]bool, len(fg))
+	m.any = fg
+	for by := 0; by < m.rows; by++ {
+		for bx := 0; bx < m.cols; bx++ {
+			inner := true
+			for dy := -1; dy <= 1 && inner; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					x, y := bx+dx, by+dy
+					if x < 0 || y < 0 || x >= m.cols || y >= m.rows || !fg[y*m.cols+x] {
+						inner = false
+						break
+					}
+				}
+			}
+			m.inner[by*m.cols+bx] = inner
+		}
+	}
+	return m
+}
+
+type blockMask struct {
+	cols, rows int
+	any        []bool
+	inner      []bool
+}
+
+func (m *blockMask) at(blocks []bool, x, y int) bool {
+	bx, by := x/BlockSize, y/BlockSize
+	return bx < m.cols && by < m.rows && blocks[by*m.cols+bx]
+}
+
+//binarize - Ridge pixels are darker than the mean of their neighbourhood after a light blur
+func (g *grid) binarize(mask *blockMask) []bool {
+	w, h := g.width, g.height
+	blurred := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float64
+			var n int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if x+dx >= 0 && y+dy >= 0 && x+dx < w && y+dy < h {
+						sum += g.pix[(y+dy)*w+x+dx]
+						n++
+					}
+				}
+			}
+			blurred[y*w+x] = sum / float64(n)
+		}
+	}
+
+	//Summed area table for the local means
+	integral := make([]float64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row float64
+		for x := 0; x < w; x++ {
+			row += blurred[y*w+x]
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+
+	ridges := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !mask.at(mask.any, x, y) {
+				continue
+			}
+			x0, y0 := maxInt(x-thresholdRadius, 0), maxInt(y-thresholdRadius, 0)
+			x1, y1 := minInt(x+thresholdRadius+1, w), minInt(y+thresholdRadius+1, h)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			ridges[y*w+x] = blurred[y*w+x] < sum/float64((x1-x0)*(y1-y0))
+		}
+	}
+	return ridges
+}
+
+//Neighbours in circular order starting north, as the crossing number and thinning need them
+var neighbours = [8]image.Point{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+
+//thin - Zhang-Suen thinning to a one pixel wide skeleton
+func thin(pix []bool, w, h int) []bool {
+	at := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && pix[y*w+x]
+	}
+	var remove []int
+	for changed := true; changed; {
+		changed = false
+		for pass := 0; pass < 2; pass++ {
+			remove = remove[:0]
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					if !pix[y*w+x] {
+						continue
+					}
+					var p [8]bool
+					count, transitions := 0, 0
+					for i, n := range neighbours {
+						p[i] = at(x+n.X, y+n.Y)
+						if p[i] {
+							count++
+						}
+					}
+					for i := range p {
+						if !p[i] && p[(i+1)%8] {
+							transitions++
+						}
+					}
+					if count < 2 || count > 6 || transitions != 1 {
+						continue
+					}
+					//p[0] north, p[2] east, p[4] south, p[6] west
+					if pass == 0 && (p[0] && p[2] && p[4] || p[2] && p[4] && p[6]) {
+						continue
+					}
+					if pass == 1 && (p[0] && p[2] && p[6] || p[0] && p[4] && p[6]) {
+						continue
+					}
+					remove = append(remove, y*w+x)
+				}
+			}
+			for _, i := range remove {
+				pix[i] = false
+			}
+			if len(remove) > 0 {
+				changed = true
+			}
+		}
+	}
+	return pix
+}
+
+//findMinutiae - Crossing number 1 is a ridge ending, 3 a bifurcation
+func (g *grid) findMinutiae(skeleton []bool, mask *blockMask) []Minutia {
+	w, h := g.width, g.height
+	at := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && skeleton[y*w+x]
+	}
+
+	var minutiae []Minutia
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			if !skeleton[y*w+x] || !mask.at(mask.inner, x, y) {
+				continue
+			}
+			var branches []image.Point
+			for i, n := range neighbours {
+				prev := neighbours[(i+7)%8]
+				if at(x+n.X, y+n.Y) && !at(x+prev.X, y+prev.Y) {
+					branches = append(branches, image.Pt(x+n.X, y+n.Y))
+				}
+			}
+			switch len(branches) {
+			case 1:
+				end := trace(at, image.Pt(x, y), branches[0])
+				minutiae = append(minutiae, Minutia{X: x, Y: y, Angle: direction(end, image.Pt(x, y)), Kind: Ending})
+			case 3:
+				minutiae = append(minutiae, Minutia{X: x, Y: y, Angle: bifurcationAngle(at, image.Pt(x, y), branches), Kind: Bifurcation})
+			}
+		}
+	}
+	return minutiae
+}
+
+//bifurcationAngle - Points from the stem into the fork, the stem is the branch furthest in angle from the other two
+func bifurcationAngle(at func(x, y int) bool, p image.Point, branches []image.Point) float64 {
+	var angles [3]float64
+	for i, b := range branches {
+		angles[i] = direction(p, trace(at, p, b))
+	}
+	stem, widest := 0, -1.0
+	for i := range angles {
+		spread := angleDiff(angles[i], angles[(i+1)%3]) + angleDiff(angles[i], angles[(i+2)%3])
+		if spread > widest {
+			stem, widest = i, spread
+		}
+	}
+	return normalize(angles[stem] + math.Pi)
+}
+
+//trace - Follows the skeleton from p through first for traceLength pixels or until the ridge forks or ends
+func trace(at func(x, y int) bool, p image.Point, first image.Point) image.Point {
+	visited := map[image.Point]bool{p: true}
+	for _, n := range neighbours {
+		visited[p.Add(n)] = true
+	}
+	current := first
+	for step := 0; step < traceLength; step++ {
+		var next []image.Point
+		for _, n := range neighbours {
+			q := current.Add(n)
+			if at(q.X, q.Y) && !visited[q] {
+				next = append(next, q)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		for _, q := range next {
+			visited[q] = true
+		}
+		//Prefer the 4-connected neighbour, the diagonal one is usually a corner of the same ridge
+		previous := current
+		current = next[0]
+		for _, q := range next {
+			if d := q.Sub(previous); d.X == 0 || d.Y == 0 {
+				current = q
+				break
+			}
+		}
+	}
+	return current
+}
+
+//prune - Drops pairs of minutiae closer than minDistance, they come from noise rather than the finger
+func prune(minutiae []Minutia) []Minutia {
+	drop := make([]bool, len(minutiae))
+	for i := range minutiae {
+		for j := i + 1; j < len(minutiae); j++ {
+			dx, dy := minutiae[i].X-minutiae[j].X, minutiae[i].Y-minutiae[j].Y
+			if dx*dx+dy*dy < minDistance*minDistance {
+				drop[i], drop[j] = true, true
+			}
+		}
+	}
+	kept := minutiae[:0]
+	for i, m := range minutiae {
+		if !drop[i] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+//direction - Angle of the vector from a to b, y grows downwards as in the image
+func direction(from image.Point, to image.Point) float64 {
+	return normalize(math.Atan2(float64(to.Y-from.Y), float64(to.X-from.X)))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,145 @@
+//Package match extracts minutiae from finger images and scores them against each other on the CPU, independent of
+//the sensor. It cross-checks CompareCharacteristics of a module and matches images taken by different sensor models,
+//as long as they have about the same resolution; the ZFM family and the R30x/R50x modules all use 500 dpi.
+package match
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+//Kind - Type of a minutia
+type Kind int
+
+const (
+	//Ending - A ridge ends
+	Ending Kind = iota
+	//Bifurcation - A ridge forks into two
+	Bifurcation
+)
+
+func (k Kind) String() string {
+	if k == Bifurcation {
+		return "bifurcation"
+	}
+	return "ending"
+}
+
+//Minutia - Position in pixels and direction in radians, 0 pointing right and growing clockwise as y points down
+type Minutia struct {
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+	Angle float64 `json:"angle"`
+	Kind  Kind    `json:"kind"`
+}
+
+//Template - Minutiae of one image
+type Template struct {
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+	Minutiae []Minutia `json:"minutiae"`
+}
+
+//Result - Outcome of Compare. Score is 0-100, the transform maps the first template onto the second.
+type Result struct {
+	Score    int     `json:"score"`
+	Matched  int     `json:"matched"`
+	Rotation float64 `json:"rotation"`
+	DX       float64 `json:"dx"`
+	DY       float64 `json:"dy"`
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("score %d, %d minutiae matched, rotated %.0f° shifted %.0f/%.0f px",
+		r.Score, r.Matched, r.Rotation*180/math.Pi, r.DX, r.DY)
+}
+
+//Tolerances of Compare, a finger is never placed twice with the same pressure
+const (
+	distanceTolerance = 12
+	angleTolerance    = math.Pi / 6
+)
+
+//MinMatched - Fewer paired minutiae than this are scored 0, any two fingers share a few by chance
+const MinMatched = 4
+
+//Compare - Tries every pair of minutiae as the anchor of a rotation and shift of a onto b and keeps the one that
+//pairs most minutiae within the tolerances. The score is the square of the pairs over the product of both counts,
+//so 100 means every minutia of both templates found a partner. Endings and bifurcations are not told apart,
+//pressure turns one into the other.
+func Compare(a *Template, b *Template) Result {
+	best := Result{}
+	if len(a.Minutiae) == 0 || len(b.Minutiae) == 0 {
+		return best
+	}
+	used := make([]bool, len(b.Minutiae))
+	for _, anchorA := range a.Minutiae {
+		for _, anchorB := range b.Minutiae {
+			rotation := normalize(anchorB.Angle - anchorA.Angle)
+			sin, cos := math.Sincos(rotation)
+			for i := range used {
+				used[i] = false
+			}
+
+			matched := 0
+			for _, m := range a.Minutiae {
+				x0, y0 := float64(m.X-anchorA.X), float64(m.Y-anchorA.Y)
+				x := x0*cos - y0*sin + float64(anchorB.X)
+				y := x0*sin + y0*cos + float64(anchorB.Y)
+				angle := normalize(m.Angle + rotation)
+
+				partner, nearest := -1, float64(distanceTolerance*distanceTolerance)
+				for j, n := range b.Minutiae {
+					if used[j] || angleDiff(angle, n.Angle) > angleTolerance {
+						continue
+					}
+					dx, dy := x-float64(n.X), y-float64(n.Y)
+					if d := dx*dx + dy*dy; d <= nearest {
+						partner, nearest = j, d
+					}
+				}
+				if partner >= 0 {
+					used[partner] = true
+					matched++
+				}
+			}
+
+			if matched > best.Matched {
+				best = Result{
+					Matched:  matched,
+					Rotation: rotation,
+					DX:       float64(anchorB.X) - (float64(anchorA.X)*cos - float64(anchorA.Y)*sin),
+					DY:       float64(anchorB.Y) - (float64(anchorA.X)*sin + float64(anchorA.Y)*cos),
+				}
+			}
+		}
+	}
+	if best.Matched >= MinMatched {
+		best.Score = int(math.Round(100 * float64(best.Matched*best.Matched) / float64(len(a.Minutiae)*len(b.Minutiae))))
+	}
+	return best
+}
+
+//CompareImages - Extracts both images and compares them
+func CompareImages(a *image.Gray, b *image.Gray) Result {
+	return Compare(Extract(a), Extract(b))
+}
+
+//normalize - Angle in [0, 2π)
+func normalize(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+//angleDiff - Smallest difference of two angles, 0 to π
+func angleDiff(a, b float64) float64 {
+	d := math.Abs(normalize(a) - normalize(b))
+	if d > math.Pi {
+		d = 2*math.Pi - d
+	}
+	return d
+}
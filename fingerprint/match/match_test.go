@@ -0,0 +1,196 @@
+package match
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+//singularity - Phase singularity of a synthetic ridge pattern, each one makes a minutia
+type singularity struct {
+	x, y float64
+	turn float64
+}
+
+//singularities - n of them at least 30 pixels apart inside the finger
+func singularities(seed int64, n int) []singularity {
+	r := rand.New(rand.NewSource(seed))
+	var s []singularity
+	for len(s) < n {
+		c := singularity{60 + r.Float64()*136, 60 + r.Float64()*168, 1}
+		if r.Intn(2) == 0 {
+			c.turn = -1
+		}
+		apart := true
+		for _, o := range s {
+			if math.Hypot(o.x-c.x, o.y-c.y) < 30 {
+				apart = false
+			}
+		}
+		if apart {
+			s = append(s, c)
+		}
+	}
+	return s
+}
+
+//finger - 256x288 image as the sensors send it, an ellipse of ridges with a period of 9 pixels on white,
+//rotated by rotation around the centre and shifted by dx/dy
+func finger(s []singularity, rotation float64, dx float64, dy float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 256, 288))
+	sin, cos := math.Sincos(-rotation)
+	for y := 0; y < 288; y++ {
+		for x := 0; x < 256; x++ {
+			px, py := float64(x)-128-dx, float64(y)-144-dy
+			u, v := px*cos-py*sin+128, px*sin+py*cos+144
+			grey := 255.0
+			if eu, ev := (u-128)/115, (v-144)/135; eu*eu+ev*ev < 1 {
+				phase := (u*0.8 + v*0.6) * 2 * math.Pi / 9
+				for _, c := range s {
+					phase += c.turn * math.Atan2(v-c.y, u-c.x)
+				}
+				grey = 127 + 120*math.Cos(phase)
+			}
+			//The sensors send 4 bits per pixel
+			img.Pix[y*256+x] = byte(math.Round(grey/17)) * 17
+		}
+	}
+	return img
+}
+
+//transform - Rotates the minutiae around the origin and shifts them
+func transform(t *Template, rotation float64, dx float64, dy float64) *Template {
+	sin, cos := math.Sincos(rotation)
+	moved := &Template{Width: t.Width, Height: t.Height}
+	for _, m := range t.Minutiae {
+		x, y := float64(m.X), float64(m.Y)
+		moved.Minutiae = append(moved.Minutiae, Minutia{
+			X:     int(math.Round(x*cos - y*sin + dx)),
+			Y:     int(math.Round(x*sin + y*cos + dy)),
+			Angle: normalize(m.Angle + rotation),
+			Kind:  m.Kind,
+		})
+	}
+	return moved
+}
+
+//lattice - n minutiae 50 pixels apart, four to a row
+func lattice(n int) *Template {
+	t := &Template{Width: 256, Height: 288}
+	for i := 0; i < n; i++ {
+		t.Minutiae = append(t.Minutiae, Minutia{X: 40 + i%4*50, Y: 40 + i/4*50, Angle: float64(i) * 0.7, Kind: Kind(i % 2)})
+	}
+	return t
+}
+
+func TestCompare(t *testing.T) {
+	a := lattice(12)
+	if r := Compare(a, a); r.Score != 100 || r.Matched != 12 || r.Rotation != 0 || r.DX != 0 || r.DY != 0 {
+		t.Errorf("same template: %v", r)
+	}
+
+	rotation := 20 * math.Pi / 180
+	r := Compare(a, transform(a, rotation, 30, -10))
+	if r.Score != 100 || angleDiff(r.Rotation, rotation) > 1e-9 || math.Abs(r.DX-30) > 1 || math.Abs(r.DY+10) > 1 {
+		t.Errorf("moved template: %v", r)
+	}
+
+	//Half the minutiae of a missing, 6*6/(12*6)
+	partial := &Template{Minutiae: a.Minutiae[:6]}
+	if r := Compare(a, partial); r.Score != 50 || r.Matched != 6 {
+		t.Errorf("partial template: %v", r)
+	}
+
+	//Endings turn into bifurcations under pressure
+	flipped := transform(a, 0, 0, 0)
+	for i := range flipped.Minutiae {
+		flipped.Minutiae[i].Kind = 1 - flipped.Minutiae[i].Kind
+	}
+	if r := Compare(a, flipped); r.Score != 100 {
+		t.Errorf("other kinds: %v", r)
+	}
+
+	//Directions off by more than the tolerance do not pair
+	turned := transform(a, 0, 0, 0)
+	for i := range turned.Minutiae {
+		turned.Minutiae[i].Angle = normalize(turned.Minutiae[i].Angle + float64(i%2)*math.Pi/2)
+	}
+	if r := Compare(a, turned); r.Matched != 6 {
+		t.Errorf("turned minutiae: %v", r)
+	}
+}
+
+func TestCompareMinMatched(t *testing.T) {
+	few := lattice(MinMatched - 1)
+	if r := Compare(few, few); r.Score != 0 || r.Matched != MinMatched-1 {
+		t.Errorf("%d minutiae: %v", MinMatched-1, r)
+	}
+	enough := lattice(MinMatched)
+	if r := Compare(enough, enough); r.Score != 100 {
+		t.Errorf("%d minutiae: %v", MinMatched, r)
+	}
+	if r := Compare(enough, &Template{}); r != (Result{}) {
+		t.Errorf("empty template: %v", r)
+	}
+}
+
+func TestSegment(t *testing.T) {
+	img := finger(nil, 0, 0, 0)
+	blocks, cols, rows := Segment(img.SubImage(image.Rect(0, 0, 250, 40)).(*image.Gray))
+	if cols != 15 || rows != 2 || len(blocks) != 30 {
+		t.Fatalf("%d blocks of %dx%d", len(blocks), cols, rows)
+	}
+	//The corner is outside the finger, the centre of the top edge inside it
+	if blocks[0].Foreground() || blocks[0].Mean != 255 || !blocks[cols+7].Foreground() {
+		t.Errorf("corner %+v, centre %+v", blocks[0], blocks[cols+7])
+	}
+	if b := blocks[cols+7]; b.Col != 7 || b.Row != 1 || b.Rect != image.Rect(112, 16, 128, 32) {
+		t.Errorf("block %+v", b)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	s := singularities(1, 14)
+	a := Extract(finger(s, 0, 0, 0))
+	if a.Width != 256 || a.Height != 288 || len(a.Minutiae) < 8 {
+		t.Fatalf("%dx%d, %d minutiae", a.Width, a.Height, len(a.Minutiae))
+	}
+	found := 0
+	for _, c := range s {
+		for _, m := range a.Minutiae {
+			if math.Hypot(float64(m.X)-c.x, float64(m.Y)-c.y) < 8 {
+				found++
+				break
+			}
+		}
+	}
+	if found < len(s)/2 {
+		t.Errorf("%d of %d singularities found", found, len(s))
+	}
+
+	if blank := Extract(image.NewGray(image.Rect(0, 0, 256, 288))); len(blank.Minutiae) != 0 {
+		t.Errorf("blank image: %v", blank.Minutiae)
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	s := singularities(1, 14)
+	rotation := 10 * math.Pi / 180
+	a := finger(s, 0, 0, 0)
+	same := CompareImages(a, finger(s, rotation, 8, -6))
+	if same.Score < 40 || angleDiff(same.Rotation, rotation) > 0.15 {
+		t.Errorf("same finger: %v", same)
+	}
+	if other := CompareImages(a, finger(singularities(2, 14), 0, 0, 0)); other.Score >= same.Score/2 {
+		t.Errorf("other finger %v, same finger %v", other, same)
+	}
+}
+
+func BenchmarkCompareImages(b *testing.B) {
+	s := singularities(1, 14)
+	x, y := finger(s, 0, 0, 0), finger(s, 0.1, 5, 5)
+	for i := 0; i < b.N; i++ {
+		CompareImages(x, y)
+	}
+}
@@ -0,0 +1,53 @@
+package match
+
+import (
+	"image"
+	"math"
+)
+
+//BlockSize - Block size of the segmentation, about two ridge periods at 500 dpi
+const BlockSize = 16
+
+//ForegroundDeviation - Grey level deviation of a block that holds ridges, the empty sensor stays well below it
+const ForegroundDeviation = 20
+
+//Block - Grey level statistics of one block of an image
+type Block struct {
+	//Col, Row - Position in blocks
+	Col, Row  int
+	Rect      image.Rectangle
+	Mean      float64
+	Deviation float64
+}
+
+//Foreground - The block holds ridges
+func (b *Block) Foreground() bool {
+	return b.Deviation >= ForegroundDeviation
+}
+
+//Segment - Splits the image into blocks of BlockSize pixels, row by row. Pixels right and below the last
+//whole block are left out.
+func Segment(img *image.Gray) (blocks []Block, cols int, rows int) {
+	bounds := img.Bounds()
+	cols, rows = bounds.Dx()/BlockSize, bounds.Dy()/BlockSize
+	blocks = make([]Block, 0, cols*rows)
+	for by := 0; by < rows; by++ {
+		for bx := 0; bx < cols; bx++ {
+			b := Block{Col: bx, Row: by}
+			b.Rect = image.Rect(bx*BlockSize, by*BlockSize, (bx+1)*BlockSize, (by+1)*BlockSize).Add(bounds.Min)
+			var sum, squares float64
+			for y := b.Rect.Min.Y; y < b.Rect.Max.Y; y++ {
+				for x := b.Rect.Min.X; x < b.Rect.Max.X; x++ {
+					v := float64(img.GrayAt(x, y).Y)
+					sum += v
+					squares += v * v
+				}
+			}
+			n := float64(BlockSize * BlockSize)
+			b.Mean = sum / n
+			b.Deviation = math.Sqrt(math.Max(squares/n-b.Mean*b.Mean, 0))
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, cols, rows
+}
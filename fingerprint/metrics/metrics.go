@@ -0,0 +1,36 @@
+//Package metrics collects measurements of fingerprint scanners and exports them in the Prometheus text format,
+//without depending on the Prometheus client
+package metrics
+
+import "time"
+
+//Outcomes of identifications
+const (
+	OutcomeMatch   = "match"
+	OutcomeNoMatch = "no_match"
+	OutcomeError   = "error"
+)
+
+//Results of commands that got no confirmation code, others are reported as the code like "0x00"
+const (
+	ResultChecksum = "checksum_error"
+	ResultTimeout  = "timeout"
+	ResultLinkDown = "link_down"
+	ResultCanceled = "canceled"
+	ResultError    = "error"
+)
+
+//Recorder - Receives the measurements of scanners, told apart by the name given to WithMetrics.
+//Implementations must be safe for concurrent use, Registry exports them to Prometheus.
+type Recorder interface {
+	//Command - An instruction was answered with result after latency
+	Command(scanner string, instruction string, result string, latency time.Duration)
+	//ChecksumError - A packet arrived damaged
+	ChecksumError(scanner string)
+	//Reconnect - The connection to the sensor was re-established
+	Reconnect(scanner string)
+	//Identification - Identify, IdentifyGroup or Verify ended with outcome, score is that of the matched template
+	Identification(scanner string, outcome string, score int)
+	//Library - Occupancy of the template library
+	Library(scanner string, used int, capacity int)
+}
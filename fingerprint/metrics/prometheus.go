@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//latencyBuckets - Upper bounds in seconds of the command latency histogram, instructions waiting for a finger
+//take seconds
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//scoreBuckets - Upper bounds of the identification score histogram
+var scoreBuckets = []float64{25, 50, 75, 100, 150, 200, 300, 500}
+
+//histogram - Cumulative counts are computed on export
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(bounds))
+	}
+	for i, bound := range bounds {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+//Registry - Recorder keeping counters, histograms and gauges in memory, served in the Prometheus text
+//format by ServeHTTP. The zero value is not usable, use NewRegistry.
+type Registry struct {
+	mu              sync.Mutex
+	commands        map[string]float64
+	latency         map[string]*histogram
+	checksumErrors  map[string]float64
+	reconnects      map[string]float64
+	identifications map[string]float64
+	scores          map[string]*histogram
+	templates       map[string]float64
+	capacity        map[string]float64
+}
+
+//NewRegistry - Create an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		commands:        make(map[string]float64),
+		latency:         make(map[string]*histogram),
+		checksumErrors:  make(map[string]float64),
+		reconnects:      make(map[string]float64),
+		identifications: make(map[string]float64),
+		scores:          make(map[string]*histogram),
+		templates:       make(map[string]float64),
+		capacity:        make(map[string]float64),
+	}
+}
+
+//labels - Formats name="value" pairs, the result is the key of a series
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(pairs[i+1]))
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func observe(series map[string]*histogram, key string, bounds []float64, v float64) {
+	h, ok := series[key]
+	if !ok {
+		h = &histogram{}
+		series[key] = h
+	}
+	h.observe(bounds, v)
+}
+
+//Command - See Recorder
+func (r *Registry) Command(scanner string, instruction string, result string, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[labels("scanner", scanner, "instruction", instruction, "result", result)]++
+	observe(r.latency, labels("scanner", scanner, "instruction", instruction), latencyBuckets, latency.Seconds())
+}
+
+//ChecksumError - See Recorder
+func (r *Registry) ChecksumError(scanner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checksumErrors[labels("scanner", scanner)]++
+}
+
+//Reconnect - See Recorder
+func (r *Registry) Reconnect(scanner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reconnects[labels("scanner", scanner)]++
+}
+
+//Identification - See Recorder, only scores of matches go into the histogram
+func (r *Registry) Identification(scanner string, outcome string, score int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identifications[labels("scanner", scanner, "outcome", outcome)]++
+	if outcome == OutcomeMatch {
+		observe(r.scores, labels("scanner", scanner), scoreBuckets, float64(score))
+	}
+}
+
+//Library - See Recorder
+func (r *Registry) Library(scanner string, used int, capacity int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := labels("scanner", scanner)
+	r.templates[key] = float64(used)
+	r.capacity[key] = float64(capacity)
+}
+
+//WriteTo - Writes every series in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	writeValues(cw, "fingerprint_commands_total", "counter", "Commands sent to the sensor by instruction and result", r.commands)
+	writeHistograms(cw, "fingerprint_command_duration_seconds", "Time from sending a command to its response", latencyBuckets, r.latency)
+	writeValues(cw, "fingerprint_checksum_errors_total", "counter", "Packets received with a wrong checksum", r.checksumErrors)
+	writeValues(cw, "fingerprint_reconnects_total", "counter", "Connections to the sensor re-established", r.reconnects)
+	writeValues(cw, "fingerprint_identifications_total", "counter", "Identifications and verifications by outcome", r.identifications)
+	writeHistograms(cw, "fingerprint_identification_score", "Scores of matched templates", scoreBuckets, r.scores)
+	writeValues(cw, "fingerprint_library_templates", "gauge", "Stored templates as of the last read of the template index", r.templates)
+	writeValues(cw, "fingerprint_library_capacity", "gauge", "Positions of the template library", r.capacity)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+//ServeHTTP - Serves the metrics to a Prometheus scrape
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+//countingWriter - Keeps the first error, later writes are dropped
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeValues(cw *countingWriter, name string, kind string, help string, series map[string]float64) {
+	cw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cw.printf("%s{%s} %s\n", name, key, formatFloat(series[key]))
+	}
+}
+
+func writeHistograms(cw *countingWriter, name string, help string, bounds []float64, series map[string]*histogram) {
+	cw.printf("# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h := series[key]
+		var cumulative uint64
+		for i, bound := range bounds {
+			cumulative += h.counts[i]
+			cw.printf("%s_bucket{%s,le=\"%s\"} %d\n", name, key, formatFloat(bound), cumulative)
+		}
+		cw.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, key, h.count)
+		cw.printf("%s_sum{%s} %s\n", name, key, formatFloat(h.sum))
+		cw.printf("%s_count{%s} %d\n", name, key, h.count)
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/exposition.txt")
+
+//sample - Two scanners, one with a name that needs escaping
+func sample() *Registry {
+	r := NewRegistry()
+	r.Command("door", "SEARCHTEMPLATE", "0x00", 30*time.Millisecond)
+	r.Command("door", "SEARCHTEMPLATE", "0x09", 8*time.Millisecond)
+	r.Command("door", "READIMAGE", "0x02", 20*time.Second)
+	r.Command("door", "TEMPLATEINDEX", ResultChecksum, time.Millisecond)
+	r.ChecksumError("door")
+	r.Command(`gate "2"`, "VERIFYPASSWORD", ResultTimeout, 2*time.Second)
+	r.Reconnect(`gate "2"`)
+	r.Reconnect(`gate "2"`)
+	r.Identification("door", OutcomeMatch, 120)
+	r.Identification("door", OutcomeMatch, 40)
+	r.Identification("door", OutcomeNoMatch, 0)
+	r.Identification(`gate "2"`, OutcomeError, 0)
+	r.Library("door", 17, 200)
+	r.Library("door", 18, 200)
+	r.Library(`gate "2"`, 0, 1000)
+	return r
+}
+
+func TestExposition(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := sample().WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, %v, wrote %d", n, err, buf.Len())
+	}
+	if *update {
+		if err := ioutil.WriteFile("testdata/exposition.txt", buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile("testdata/exposition.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("exposition differs from testdata/exposition.txt, run with -update to see\n%s", buf.String())
+	}
+}
+
+func TestExpositionEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	NewRegistry().WriteTo(&buf)
+	want := "# HELP fingerprint_commands_total Commands sent to the sensor by instruction and result\n" +
+		"# TYPE fingerprint_commands_total counter\n"
+	if !bytes.HasPrefix(buf.Bytes(), []byte(want)) || bytes.Count(buf.Bytes(), []byte("\n")) != 16 {
+		t.Errorf("empty registry\n%s", buf.String())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := sample()
+	var buf bytes.Buffer
+	r.WriteTo(&buf)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" ||
+		w.Body.String() != buf.String() {
+		t.Errorf("GET = %d %v\n%s", w.Code, w.Header(), w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodGet {
+		t.Errorf("POST = %d %v", w.Code, w.Header())
+	}
+}
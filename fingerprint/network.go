@@ -0,0 +1,327 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+//ErrLinkDown - The connection to the sensor was lost, the pending command has to be sent again
+var ErrLinkDown = errors.New("connection to the sensor lost")
+
+//NetworkMode - How the serial-to-Ethernet bridge exposes the serial line
+type NetworkMode int
+
+const (
+	//NetworkRaw - Bytes are passed through unchanged, the bridge owns the line settings (ser2net raw)
+	NetworkRaw NetworkMode = iota
+	//NetworkRFC2217 - Telnet with the com port control option, line settings follow the sensor (ser2net telnet)
+	NetworkRFC2217
+)
+
+//NetworkConfig - Serial-to-Ethernet bridge settings, zero values pick the defaults
+type NetworkConfig struct {
+	Address           string
+	Mode              NetworkMode
+	Baud              int
+	DialTimeout       time.Duration
+	ReadTimeout       time.Duration
+	ReconnectAttempts int
+	ReconnectDelay    time.Duration
+}
+
+//Telnet and RFC 2217 codes
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetOptionBinary  = 0
+	telnetOptionSGA     = 3
+	telnetOptionComPort = 44
+
+	comPortSetBaudRate = 1
+	comPortSetDataSize = 2
+	comPortSetParity   = 3
+	comPortSetStopSize = 4
+	comPortParityNone  = 1
+	comPortStopSizeOne = 1
+)
+
+//Telnet decoder states
+const (
+	telnetStateData = iota
+	telnetStateIAC
+	telnetStateOption
+	telnetStateSB
+	telnetStateSBIAC
+)
+
+const (
+	defaultNetworkBaud    = DefaultBaud
+	defaultDialTimeout    = 5 * time.Second
+	defaultReadTimeout    = 500 * time.Millisecond
+	defaultReconnectDelay = time.Second
+)
+
+type myNetwork struct {
+	cfg  NetworkConfig
+	conn net.Conn
+
+	//Telnet decoder state, sequences may be split over several reads
+	state   int
+	command byte
+
+	//reconnected - Optional, called after every re-established connection
+	reconnected func()
+}
+
+//reconnectObserver - Transports which re-establish lost connections
+type reconnectObserver interface {
+	observeReconnects(fn func())
+}
+
+func (n *myNetwork) observeReconnects(fn func()) {
+	n.reconnected = fn
+}
+
+//NewNetwork - Create Scanner behind a serial-to-Ethernet bridge such as ser2net
+func NewNetwork(networkCfg *NetworkConfig, password uint, opts ...Option) ScannerIO {
+	if networkCfg == nil || networkCfg.Address == "" {
+		log.Fatal("Unable to open network connection due to invalid params")
+	}
+
+	cfg := *networkCfg
+	if cfg.Baud == 0 {
+		cfg.Baud = defaultNetworkBaud
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = defaultReadTimeout
+	}
+	if cfg.ReconnectAttempts == 0 {
+		cfg.ReconnectAttempts = 3
+	}
+	if cfg.ReconnectDelay == 0 {
+		cfg.ReconnectDelay = defaultReconnectDelay
+	}
+
+	s := &scanner{}
+	s.link = &myNetwork{cfg: cfg}
+	s.password = password
+	s.applyOptions(opts)
+	return s
+}
+
+func (n *myNetwork) open() error {
+	var err error
+	n.conn, err = net.DialTimeout("tcp", n.cfg.Address, n.cfg.DialTimeout)
+	if err != nil {
+		log.Printf("Could not connect to %s: %v\n", n.cfg.Address, err)
+		return err
+	}
+	n.state = telnetStateData
+
+	if n.cfg.Mode == NetworkRFC2217 {
+		negotiation := []byte{
+			telnetIAC, telnetWILL, telnetOptionComPort,
+			telnetIAC, telnetWILL, telnetOptionBinary,
+			telnetIAC, telnetDO, telnetOptionBinary,
+			telnetIAC, telnetDO, telnetOptionSGA,
+		}
+		negotiation = append(negotiation, comPortCommand(comPortSetBaudRate, baudRateValue(n.cfg.Baud))...)
+		negotiation = append(negotiation, comPortCommand(comPortSetDataSize, []byte{8})...)
+		negotiation = append(negotiation, comPortCommand(comPortSetParity, []byte{comPortParityNone})...)
+		negotiation = append(negotiation, comPortCommand(comPortSetStopSize, []byte{comPortStopSizeOne})...)
+		if _, err = n.conn.Write(negotiation); err != nil {
+			n.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *myNetwork) close() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+}
+
+//reconnect - Drops the connection and dials again, the line settings are sent again by open
+func (n *myNetwork) reconnect() error {
+	var err error
+	n.close()
+	for attempt := 1; attempt <= n.cfg.ReconnectAttempts; attempt++ {
+		time.Sleep(n.cfg.ReconnectDelay)
+		if err = n.open(); err == nil {
+			log.Printf("Reconnected to %s after %d attempt(s)\n", n.cfg.Address, attempt)
+			if n.reconnected != nil {
+				n.reconnected()
+			}
+			return nil
+		}
+	}
+	return err
+}
+
+func (n *myNetwork) write(payLoad []byte) (int, error) {
+	if n.conn == nil {
+		if err := n.reconnect(); err != nil {
+			return -1, err
+		}
+	}
+	data := payLoad
+	if n.cfg.Mode == NetworkRFC2217 {
+		data = escapeIAC(payLoad)
+	}
+	if _, err := n.conn.Write(data); err != nil {
+		log.Printf("Error writing to %s: %v\n", n.cfg.Address, err)
+		//Part of the packet may have reached the sensor, sending it again could complete a corrupted frame.
+		//The connection is re-established for the next command, this one is left to the caller.
+		if errReconnect := n.reconnect(); errReconnect != nil {
+			log.Printf("Unable to reconnect to %s: %v\n", n.cfg.Address, errReconnect)
+		}
+		return -1, ErrLinkDown
+	}
+	return len(payLoad), nil
+}
+
+func (n *myNetwork) readFragement(readSize int) ([]byte, int, error) {
+	if n.conn == nil {
+		return nil, -1, ErrLinkDown
+	}
+	buf := make([]byte, readSize)
+	n.conn.SetReadDeadline(time.Now().Add(n.cfg.ReadTimeout))
+	readBytes, err := n.conn.Read(buf)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return buf, 0, nil
+		}
+		log.Printf("Error reading from %s: %v\n", n.cfg.Address, err)
+		if errReconnect := n.reconnect(); errReconnect != nil {
+			log.Printf("Unable to reconnect to %s: %v\n", n.cfg.Address, errReconnect)
+		}
+		return nil, -1, ErrLinkDown
+	}
+	if n.cfg.Mode == NetworkRFC2217 {
+		readBytes = n.decodeTelnet(buf[:readBytes])
+	}
+	return buf, readBytes, nil
+}
+
+//checkBaud - Only an RFC 2217 bridge can follow the sensor, a raw bridge has to be reconfigured by hand
+func (n *myNetwork) checkBaud(baud int) error {
+	if n.cfg.Mode != NetworkRFC2217 {
+		return fmt.Errorf("raw bridge %s cannot follow the baud rate change to %d", n.cfg.Address, baud)
+	}
+	return nil
+}
+
+func (n *myNetwork) setBaud(baud int) error {
+	if err := n.checkBaud(baud); err != nil {
+		return err
+	}
+	n.cfg.Baud = baud
+	if n.conn == nil {
+		return ErrLinkDown
+	}
+	_, err := n.conn.Write(comPortCommand(comPortSetBaudRate, baudRateValue(baud)))
+	return err
+}
+
+//decodeTelnet - Strips telnet commands from buf in place, answers option requests and returns the data length
+func (n *myNetwork) decodeTelnet(buf []byte) int {
+	length := 0
+	var replies []byte
+
+	for _, b := range buf {
+		switch n.state {
+		case telnetStateData:
+			if b == telnetIAC {
+				n.state = telnetStateIAC
+			} else {
+				buf[length] = b
+				length++
+			}
+		case telnetStateIAC:
+			switch b {
+			case telnetIAC:
+				buf[length] = b
+				length++
+				n.state = telnetStateData
+			case telnetSB:
+				n.state = telnetStateSB
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				n.command = b
+				n.state = telnetStateOption
+			default:
+				n.state = telnetStateData
+			}
+		case telnetStateOption:
+			replies = append(replies, telnetReply(n.command, b)...)
+			n.state = telnetStateData
+		case telnetStateSB:
+			//Server acknowledgements and line state notifications are not needed
+			if b == telnetIAC {
+				n.state = telnetStateSBIAC
+			}
+		case telnetStateSBIAC:
+			if b == telnetSE {
+				n.state = telnetStateData
+			} else {
+				n.state = telnetStateSB
+			}
+		}
+	}
+
+	if len(replies) > 0 {
+		n.conn.Write(replies)
+	}
+	return length
+}
+
+//telnetReply - Options we asked for are accepted silently, anything else is refused
+func telnetReply(command byte, option byte) []byte {
+	known := option == telnetOptionBinary || option == telnetOptionSGA || option == telnetOptionComPort
+	switch {
+	case command == telnetWILL && !known:
+		return []byte{telnetIAC, telnetDONT, option}
+	case command == telnetDO && !known:
+		return []byte{telnetIAC, telnetWONT, option}
+	}
+	return nil
+}
+
+func comPortCommand(command byte, value []byte) []byte {
+	cmd := []byte{telnetIAC, telnetSB, telnetOptionComPort, command}
+	cmd = append(cmd, escapeIAC(value)...)
+	return append(cmd, telnetIAC, telnetSE)
+}
+
+func baudRateValue(baud int) []byte {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(baud))
+	return value
+}
+
+//escapeIAC - Doubles every 0xFF so data is not taken for a telnet command
+func escapeIAC(data []byte) []byte {
+	escaped := make([]byte, 0, len(data)+8)
+	for _, b := range data {
+		escaped = append(escaped, b)
+		if b == telnetIAC {
+			escaped = append(escaped, telnetIAC)
+		}
+	}
+	return escaped
+}
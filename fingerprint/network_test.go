@@ -0,0 +1,287 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+//sysParams - Ack of GetSystemParameters, the device address is all 0xFF like the packet header
+var sysParams = []byte{0, 0, 0, 0, 0, 0, 0xFF, 0, 3, 0xFF, 0xFF, 0xFF, 0xFF, 0, 2, 0, 6}
+
+//bridge - Stand-in for ser2net with a sensor behind it which acknowledges every command
+type bridge struct {
+	ln     net.Listener
+	telnet bool
+	//dropAt - The connection is closed instead of answering this command, counted from 1 over all connections
+	dropAt int
+
+	mu       sync.Mutex
+	conns    int
+	commands [][]byte
+	bauds    []int
+	refused  []byte
+}
+
+func startBridge(t *testing.T, telnet bool) *bridge {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bridge{ln: ln, telnet: telnet}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns++
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *bridge) connect(t *testing.T, opts ...Option) ScannerIO {
+	cfg := &NetworkConfig{Address: b.ln.Addr().String(), ReconnectDelay: 10 * time.Millisecond}
+	if b.telnet {
+		cfg.Mode = NetworkRFC2217
+	}
+	s := NewNetwork(cfg, 0, opts...)
+	if err := s.Capture(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Release)
+	return s
+}
+
+func (b *bridge) serve(conn net.Conn) {
+	defer conn.Close()
+	if b.telnet {
+		//ser2net asks for options the client does not know
+		conn.Write([]byte{telnetIAC, telnetDO, 24, telnetIAC, telnetWILL, 5})
+	}
+
+	var data, sub []byte
+	state := telnetStateData
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, c := range buf[:n] {
+			if !b.telnet {
+				data = append(data, c)
+				continue
+			}
+			switch state {
+			case telnetStateData:
+				if c == telnetIAC {
+					state = telnetStateIAC
+				} else {
+					data = append(data, c)
+				}
+			case telnetStateIAC:
+				state = telnetStateData
+				switch c {
+				case telnetIAC:
+					data = append(data, c)
+				case telnetSB:
+					sub, state = sub[:0], telnetStateSB
+				case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+					state = telnetStateOption
+					if c == telnetWONT || c == telnetDONT {
+						b.mu.Lock()
+						b.refused = append(b.refused, c)
+						b.mu.Unlock()
+					}
+				}
+			case telnetStateOption:
+				state = telnetStateData
+			case telnetStateSB:
+				if c == telnetIAC {
+					state = telnetStateSBIAC
+				} else {
+					sub = append(sub, c)
+				}
+			case telnetStateSBIAC:
+				if c != telnetSE {
+					sub, state = append(sub, c), telnetStateSB
+					continue
+				}
+				state = telnetStateData
+				if len(sub) == 6 && sub[0] == telnetOptionComPort && sub[1] == comPortSetBaudRate {
+					b.mu.Lock()
+					b.bauds = append(b.bauds, int(binary.BigEndian.Uint32(sub[2:])))
+					b.mu.Unlock()
+				}
+				//Acknowledged like ser2net does, with the command plus 100
+				conn.Write(append([]byte{telnetIAC, telnetSB, telnetOptionComPort, sub[1] + 100}, telnetIAC, telnetSE))
+			}
+		}
+
+		for len(data) >= packetHeaderSize {
+			size := packetHeaderSize + (int(data[7])<<8 | int(data[8]))
+			if len(data) < size {
+				break
+			}
+			command := append([]byte(nil), data[:size]...)
+			data = data[size:]
+			b.mu.Lock()
+			b.commands = append(b.commands, command)
+			drop := len(b.commands) == b.dropAt
+			b.mu.Unlock()
+			if drop {
+				return
+			}
+
+			reply := buildCommandPacket(FINGERPRINT_ACKPACKET, []byte{FINGERPRINT_OK})
+			if command[9] == FINGERPRINT_GETSYSTEMPARAMETERS {
+				reply = buildCommandPacket(FINGERPRINT_ACKPACKET, sysParams)
+			}
+			if b.telnet {
+				reply = escapeIAC(reply)
+			}
+			//Split between the two bytes of an escaped 0xFF
+			conn.Write(reply[:3])
+			time.Sleep(5 * time.Millisecond)
+			conn.Write(reply[3:])
+		}
+	}
+}
+
+//instructions - Instructions the sensor received so far
+func (b *bridge) instructions() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var instructions []byte
+	for _, command := range b.commands {
+		instructions = append(instructions, command[9])
+	}
+	return instructions
+}
+
+func testNetworkExchange(t *testing.T, telnet bool) *bridge {
+	b := startBridge(t, telnet)
+	s := b.connect(t)
+
+	p, err := s.GetSystemParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.DeviceAddress != 0xFFFFFFFF || p.StorageCapacity != 0xFF || p.SecurityLevel != 3 {
+		t.Errorf("parameters %+v", p)
+	}
+
+	b.mu.Lock()
+	first := b.commands[0]
+	b.mu.Unlock()
+	if want := buildCommandPacket(FINGERPRINT_COMMANDPACKET, getPayloadForVerifyPassword(0)); !bytes.Equal(first, want) {
+		t.Errorf("sensor received % X, want % X", first, want)
+	}
+	want := []byte{FINGERPRINT_VERIFYPASSWORD, FINGERPRINT_GETSYSTEMPARAMETERS, FINGERPRINT_GETSYSTEMPARAMETERS}
+	if got := b.instructions(); !bytes.Equal(got, want) {
+		t.Errorf("instructions % X, want % X", got, want)
+	}
+	return b
+}
+
+func TestNetworkRaw(t *testing.T) {
+	b := testNetworkExchange(t, false)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.bauds) != 0 || len(b.refused) != 0 {
+		t.Errorf("telnet on a raw bridge: bauds %v, refused %v", b.bauds, b.refused)
+	}
+}
+
+func TestNetworkRFC2217(t *testing.T) {
+	b := testNetworkExchange(t, true)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.bauds) != 1 || b.bauds[0] != DefaultBaud {
+		t.Errorf("bauds %v", b.bauds)
+	}
+	if !bytes.Equal(b.refused, []byte{telnetWONT, telnetDONT}) {
+		t.Errorf("unknown options answered with % X", b.refused)
+	}
+}
+
+func TestNetworkBaudChange(t *testing.T) {
+	b := startBridge(t, true)
+	s := b.connect(t)
+	if err := s.SetSystemParameter(FINGERPRINT_SETSYSTEMPARAMETER_BAUDRATE, 12); err != nil {
+		t.Fatal(err)
+	}
+	//The bridge has seen everything sent before once the next command is answered
+	if _, err := s.GetSystemParameters(); err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.bauds) != 2 || b.bauds[1] != 12*BaudRateUnit {
+		t.Errorf("bauds %v", b.bauds)
+	}
+}
+
+func TestNetworkRawBaudChange(t *testing.T) {
+	b := startBridge(t, false)
+	s := b.connect(t)
+	if err := s.SetSystemParameter(FINGERPRINT_SETSYSTEMPARAMETER_BAUDRATE, 12); err == nil {
+		t.Fatal("raw bridge followed a baud rate change")
+	}
+	if bytes.IndexByte(b.instructions(), FINGERPRINT_SETSYSTEMPARAMETER) >= 0 {
+		t.Error("the sensor was told to change its baud rate")
+	}
+}
+
+func TestNetworkReconnect(t *testing.T) {
+	b := startBridge(t, false)
+	b.dropAt = 3
+	reconnects := 0
+	s := b.connect(t)
+	s.(*scanner).link.(reconnectObserver).observeReconnects(func() { reconnects++ })
+
+	if _, err := s.GetSystemParameters(); err != ErrLinkDown {
+		t.Fatalf("dropped command returned %v", err)
+	}
+	if _, err := s.GetSystemParameters(); err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conns != 2 || reconnects != 1 {
+		t.Errorf("%d connections, %d reconnects", b.conns, reconnects)
+	}
+}
+
+func TestNetworkWriteFailure(t *testing.T) {
+	b := startBridge(t, true)
+	s := b.connect(t)
+	n := s.(*scanner).link.(*myNetwork)
+	n.conn.Close()
+
+	//The command is not sent again, part of it could be on the line already
+	if _, err := s.GetSystemParameters(); err != ErrLinkDown {
+		t.Fatalf("failed write returned %v", err)
+	}
+	if _, err := s.GetSystemParameters(); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{FINGERPRINT_VERIFYPASSWORD, FINGERPRINT_GETSYSTEMPARAMETERS, FINGERPRINT_GETSYSTEMPARAMETERS}
+	if got := b.instructions(); !bytes.Equal(got, want) {
+		t.Errorf("instructions % X, want % X", got, want)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.bauds) != 2 {
+		t.Errorf("line settings not sent again on the new connection: %v", b.bauds)
+	}
+}
@@ -0,0 +1,177 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+//Notepad layout
+const (
+	NotepadPages    = 16
+	NotepadPageSize = 32
+)
+
+//ErrNoMetadata - The notepad does not hold metadata written by WriteMetadata
+var ErrNoMetadata = errors.New("the notepad holds no metadata")
+
+//ErrMetadataTooLarge - The encoded metadata does not fit into the notepad
+var ErrMetadataTooLarge = errors.New("metadata does not fit into the notepad")
+
+//metadataMagic - Marks the notepad as holding metadata, followed by the 2 byte length of the JSON document
+var metadataMagic = []byte("FPMD")
+
+const metadataHeaderSize = 6
+
+//Metadata - Deployment details kept on the module itself, encoded as JSON in the notepad starting at page 0
+type Metadata struct {
+	AssetTag      string            `json:"asset_tag,omitempty"`
+	SiteID        string            `json:"site_id,omitempty"`
+	SchemaVersion int               `json:"schema_version,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+func getPayloadForReadNotepad(page int) []byte {
+	return []byte{FINGERPRINT_READNOTEPAD, byte(page)}
+}
+
+func getPayloadForWriteNotepad(page int, data []byte) []byte {
+	payLoad := make([]byte, 2+NotepadPageSize)
+	payLoad[0] = FINGERPRINT_WRITENOTEPAD
+	payLoad[1] = byte(page)
+	copy(payLoad[2:], data)
+	return payLoad
+}
+
+//ReadNotepad - Reads the 32 bytes of a notepad page (0-15)
+func (s *scanner) ReadNotepad(page int) ([]byte, error) {
+	if page < 0 || page >= NotepadPages {
+		return nil, fmt.Errorf("notepad page %d is out of range", page)
+	}
+
+	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, getPayloadForReadNotepad(page))
+	if errWrite != nil {
+		return nil, errWrite
+	}
+
+	responsePacket, errRead := s.readPacket()
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return nil, errDesc
+	}
+	if len(responsePacket.PayLoad) < 1+NotepadPageSize {
+		return nil, errShortPayload
+	}
+	return append([]byte(nil), responsePacket.PayLoad[1:1+NotepadPageSize]...), nil
+}
+
+//WriteNotepad - Writes a notepad page (0-15), data of less than 32 bytes is padded with zeros
+func (s *scanner) WriteNotepad(page int, data []byte) error {
+	if page < 0 || page >= NotepadPages {
+		return fmt.Errorf("notepad page %d is out of range", page)
+	}
+	if len(data) > NotepadPageSize {
+		return fmt.Errorf("notepad pages hold %d bytes, got %d", NotepadPageSize, len(data))
+	}
+
+	_, errWrite := s.writePacket(FINGERPRINT_COMMANDPACKET, getPayloadForWriteNotepad(page, data))
+	if errWrite != nil {
+		return errWrite
+	}
+
+	responsePacket, errRead := s.readPacket()
+	if errRead != nil {
+		return errRead
+	}
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return errDesc
+	}
+	return nil
+}
+
+//readNotepadPages - Reads pages from 0 until size bytes are available
+func readNotepadPages(s ScannerIO, size int) ([]byte, error) {
+	var data []byte
+	for page := 0; len(data) < size && page < NotepadPages; page++ {
+		b, err := s.ReadNotepad(page)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
+
+//ReadMetadata - Reads the metadata stored by WriteMetadata, ErrNoMetadata when there is none
+func ReadMetadata(s ScannerIO) (*Metadata, error) {
+	header, err := readNotepadPages(s, metadataHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, metadataMagic) {
+		return nil, ErrNoMetadata
+	}
+	length := int(header[4])<<8 | int(header[5])
+	if metadataHeaderSize+length > NotepadPages*NotepadPageSize {
+		return nil, ErrNoMetadata
+	}
+
+	data, err := readNotepadPages(s, metadataHeaderSize+length)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{}
+	if err = json.Unmarshal(data[metadataHeaderSize:metadataHeaderSize+length], m); err != nil {
+		return nil, fmt.Errorf("the notepad metadata is damaged: %v", err)
+	}
+	return m, nil
+}
+
+//WriteMetadata - Stores m in the notepad, only pages whose content changes are written to save flash cycles
+func WriteMetadata(s ScannerIO, m *Metadata) error {
+	doc, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	size := metadataHeaderSize + len(doc)
+	if size > NotepadPages*NotepadPageSize {
+		return fmt.Errorf("%w: %d bytes needed, %d available", ErrMetadataTooLarge, size, NotepadPages*NotepadPageSize)
+	}
+
+	data := append([]byte(nil), metadataMagic...)
+	data = append(data, byte(len(doc)>>8), byte(len(doc)))
+	data = append(data, doc...)
+
+	//Pages used by longer metadata before are cleared as well
+	pages := (size + NotepadPageSize - 1) / NotepadPageSize
+	if previous, err := ReadMetadata(s); err == nil {
+		if old, _ := json.Marshal(previous); metadataHeaderSize+len(old) > size {
+			pages = (metadataHeaderSize + len(old) + NotepadPageSize - 1) / NotepadPageSize
+		}
+	}
+
+	padded := make([]byte, pages*NotepadPageSize)
+	copy(padded, data)
+	for page := 0; page < pages; page++ {
+		want := padded[page*NotepadPageSize : (page+1)*NotepadPageSize]
+		have, err := s.ReadNotepad(page)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(have, want) {
+			continue
+		}
+		if err = s.WriteNotepad(page, want); err != nil {
+			return fmt.Errorf("notepad page %d: %v", page, err)
+		}
+	}
+	return nil
+}
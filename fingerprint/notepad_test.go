@@ -0,0 +1,117 @@
+package fingerprint
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+//notepadModule - Keeps the pages in pad and counts the writes
+func notepadModule(pad *[NotepadPages][NotepadPageSize]byte, writes *int) func([]byte) [][]byte {
+	return func(command []byte) [][]byte {
+		switch command[0] {
+		case FINGERPRINT_READNOTEPAD:
+			return [][]byte{append([]byte{FINGERPRINT_OK}, pad[command[1]][:]...)}
+		case FINGERPRINT_WRITENOTEPAD:
+			*writes++
+			copy(pad[command[1]][:], command[2:])
+		}
+		return ack(command)
+	}
+}
+
+func TestNotepadPages(t *testing.T) {
+	var pad [NotepadPages][NotepadPageSize]byte
+	writes := 0
+	s, l := newScripted(notepadModule(&pad, &writes))
+
+	for _, page := range []int{-1, NotepadPages} {
+		if _, err := s.ReadNotepad(page); err == nil {
+			t.Errorf("read of page %d accepted", page)
+		}
+		if err := s.WriteNotepad(page, nil); err == nil {
+			t.Errorf("write of page %d accepted", page)
+		}
+	}
+	if err := s.WriteNotepad(0, make([]byte, NotepadPageSize+1)); err == nil {
+		t.Error("33 bytes accepted")
+	}
+	if len(l.sent) != 0 {
+		t.Fatalf("sent %x", l.sent)
+	}
+
+	full := bytes.Repeat([]byte{0xA5}, NotepadPageSize)
+	if err := s.WriteNotepad(NotepadPages-1, full); err != nil || !bytes.Equal(pad[NotepadPages-1][:], full) {
+		t.Fatalf("last page: %v", err)
+	}
+	//Shorter data is padded, the command always carries a whole page
+	if err := s.WriteNotepad(0, []byte("door")); err != nil {
+		t.Fatal(err)
+	}
+	if last := l.sent[len(l.sent)-1]; len(last) != 2+NotepadPageSize || string(last[2:6]) != "door" || last[6] != 0 {
+		t.Errorf("sent %x", last)
+	}
+	if page, err := s.ReadNotepad(NotepadPages - 1); err != nil || !bytes.Equal(page, full) {
+		t.Errorf("ReadNotepad = %x, %v", page, err)
+	}
+
+	//Modules answering more than a page are cut to 32 bytes, less is an error
+	s, _ = newScripted(func(command []byte) [][]byte {
+		return [][]byte{append([]byte{FINGERPRINT_OK}, make([]byte, 40)...)}
+	})
+	if page, err := s.ReadNotepad(3); err != nil || len(page) != NotepadPageSize {
+		t.Errorf("long answer = %x, %v", page, err)
+	}
+	s, _ = newScripted(func(command []byte) [][]byte {
+		return [][]byte{append([]byte{FINGERPRINT_OK}, make([]byte, NotepadPageSize-1)...)}
+	})
+	if _, err := s.ReadNotepad(3); err != errShortPayload {
+		t.Errorf("short answer: %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	var pad [NotepadPages][NotepadPageSize]byte
+	writes := 0
+	s, _ := newScripted(notepadModule(&pad, &writes))
+	if _, err := ReadMetadata(s); err != ErrNoMetadata {
+		t.Fatalf("empty notepad: %v", err)
+	}
+
+	m := &Metadata{AssetTag: "A-17", SiteID: "berlin", SchemaVersion: 2, Labels: map[string]string{"floor": "3", "door": "north entrance"}}
+	if err := WriteMetadata(s, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMetadata(s)
+	if err != nil || got.AssetTag != "A-17" || got.SchemaVersion != 2 || got.Labels["door"] != "north entrance" {
+		t.Fatalf("ReadMetadata = %+v, %v", got, err)
+	}
+
+	//Unchanged pages are not written again
+	writes = 0
+	if err := WriteMetadata(s, m); err != nil || writes != 0 {
+		t.Errorf("rewrite = %v, %d pages written", err, writes)
+	}
+
+	//Pages of the longer metadata before are cleared
+	if err := WriteMetadata(s, &Metadata{SiteID: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	for page := 1; page < NotepadPages; page++ {
+		if pad[page] != [NotepadPageSize]byte{} {
+			t.Errorf("page %d not cleared: %x", page, pad[page])
+		}
+	}
+	if got, err = ReadMetadata(s); err != nil || got.SiteID != "x" || got.AssetTag != "" {
+		t.Errorf("ReadMetadata = %+v, %v", got, err)
+	}
+
+	if err := WriteMetadata(s, &Metadata{AssetTag: strings.Repeat("x", 500)}); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Errorf("500 byte asset tag: %v", err)
+	}
+	copy(pad[0][:], "FPMD\x01\xFF")
+	if _, err := ReadMetadata(s); err != ErrNoMetadata {
+		t.Errorf("length past the notepad: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/metrics"
+)
+
+//WithMetrics - Reports every command with its confirmation code and latency, checksum errors, reconnects,
+//outcomes of Identify, IdentifyGroup and Verify and the occupancy of the library to r under name
+func WithMetrics(r metrics.Recorder, name string) Option {
+	return func(s *scanner) {
+		s.metrics, s.metricsName = r, name
+		if ro, ok := s.link.(reconnectObserver); ok {
+			ro.observeReconnects(func() { r.Reconnect(name) })
+		}
+	}
+}
+
+//observeResponse - Counts damaged packets and ends the timing of the command in flight with its ack
+func (s *scanner) observeResponse(tp *ThumbPacket, err error) {
+	if err == ErrChecksum {
+		s.metrics.ChecksumError(s.metricsName)
+	}
+	if !s.inFlight {
+		return
+	}
+
+	var result string
+	switch {
+	case err == ErrChecksum:
+		result = metrics.ResultChecksum
+	case err == ErrTimeout:
+		result = metrics.ResultTimeout
+	case errors.Is(err, ErrLinkDown):
+		result = metrics.ResultLinkDown
+	case err == context.Canceled || err == context.DeadlineExceeded:
+		result = metrics.ResultCanceled
+	case err != nil:
+		result = metrics.ResultError
+	case tp.PacketType != FINGERPRINT_ACKPACKET || len(tp.PayLoad) == 0:
+		//Only the ack ends the command
+		return
+	default:
+		result = fmt.Sprintf("0x%02X", tp.PayLoad[0])
+	}
+	s.inFlight = false
+	s.metrics.Command(s.metricsName, InstructionName(s.instruction), result, time.Since(s.sentAt))
+}
+
+//identificationObserver - Scanners with metrics. Only Identify, IdentifyGroup and Verify report to it, searches
+//made for other purposes such as the duplicate checks are no identifications.
+type identificationObserver interface {
+	observeIdentification(result *SearchResult, err error)
+}
+
+func observeIdentification(s ScannerIO, result *SearchResult, err error) {
+	if o, ok := s.(identificationObserver); ok {
+		o.observeIdentification(result, err)
+	}
+}
+
+//observeIdentification - Outcome of the search or comparison, giving up on the finger is none
+func (s *scanner) observeIdentification(result *SearchResult, err error) {
+	switch {
+	case s.metrics == nil || err == context.Canceled || err == context.DeadlineExceeded:
+	case err == ErrNoMatch || err == nil && result.PositionNumber < 0:
+		s.metrics.Identification(s.metricsName, metrics.OutcomeNoMatch, 0)
+	case err != nil:
+		s.metrics.Identification(s.metricsName, metrics.OutcomeError, 0)
+	default:
+		s.metrics.Identification(s.metricsName, metrics.OutcomeMatch, result.AccuracyScore)
+	}
+}
+
+//observeLibrary - Occupancy from a template index
+func (s *scanner) observeLibrary(index []bool) {
+	if s.metrics == nil {
+		return
+	}
+	used := 0
+	for _, isUsed := range index {
+		if isUsed {
+			used++
+		}
+	}
+	s.metrics.Library(s.metricsName, used, len(index))
+}
@@ -0,0 +1,65 @@
+package fingerprint
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//outcomeRecorder - metrics.Recorder keeping the identification outcomes only
+type outcomeRecorder struct {
+	outcomes []string
+}
+
+func (r *outcomeRecorder) Command(scanner string, instruction string, result string, latency time.Duration) {
+}
+
+func (r *outcomeRecorder) ChecksumError(scanner string) {
+}
+
+func (r *outcomeRecorder) Reconnect(scanner string) {
+}
+
+func (r *outcomeRecorder) Identification(scanner string, outcome string, score int) {
+	r.outcomes = append(r.outcomes, outcome)
+}
+
+func (r *outcomeRecorder) Library(scanner string, used int, capacity int) {
+}
+
+func TestIdentificationMetrics(t *testing.T) {
+	library := libraryModule(map[int]string{1: "thumb", 9: "thumb"})
+	r := &outcomeRecorder{}
+	s, _ := newScripted(func(command []byte) [][]byte {
+		switch command[0] {
+		case FINGERPRINT_AUTOENROLL, FINGERPRINT_AUTOIDENTIFY:
+			return [][]byte{{FINGERPRINT_ERROR_COMMUNICATION}}
+		case FINGERPRINT_COMPARECHARACTERISTICS:
+			return [][]byte{{FINGERPRINT_OK, 0, 0}}
+		}
+		return library(command)
+	}, WithMetrics(r, "door"))
+	ctx := context.Background()
+
+	//Searches that identify nobody
+	if _, err := FindDuplicates(ctx, s, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Enroll(ctx, s, 4, nil); err != ErrAlreadyEnrolled {
+		t.Fatalf("Enroll = %v", err)
+	}
+	if len(r.outcomes) != 0 {
+		t.Fatalf("internal searches counted as %v", r.outcomes)
+	}
+
+	if result, err := Identify(ctx, s); err != nil || result.PositionNumber != 1 {
+		t.Fatalf("Identify = %+v, %v", result, err)
+	}
+	if _, err := Verify(ctx, s, 9); err != ErrNoMatch {
+		t.Fatalf("Verify = %v", err)
+	}
+	if want := []string{"match", "no_match"}; !reflect.DeepEqual(r.outcomes, want) {
+		t.Errorf("outcomes %v, want %v", r.outcomes, want)
+	}
+}
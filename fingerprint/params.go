@@ -0,0 +1,114 @@
+package fingerprint
+
+import "strings"
+
+//BaudRateUnit - SystemParameters.BaudRate and the baud rate parameter of SetSystemParameter are multiples of it
+const BaudRateUnit = 9600
+
+//DefaultBaud - Speed modules ship with
+const DefaultBaud = 6 * BaudRateUnit
+
+//Bits of SystemParameters.StatusRegister
+const (
+	StatusBusy             = 1 << 0
+	StatusPass             = 1 << 1
+	StatusPasswordVerified = 1 << 2
+	StatusImageBufferValid = 1 << 3
+)
+
+//Busy - The module is executing a command
+func (p *SystemParameters) Busy() bool {
+	return p.StatusRegister&StatusBusy != 0
+}
+
+//Pass - The last finger matched
+func (p *SystemParameters) Pass() bool {
+	return p.StatusRegister&StatusPass != 0
+}
+
+//PasswordVerified - The handshake password was verified
+func (p *SystemParameters) PasswordVerified() bool {
+	return p.StatusRegister&StatusPasswordVerified != 0
+}
+
+//ImageBufferValid - The image buffer holds a valid image
+func (p *SystemParameters) ImageBufferValid() bool {
+	return p.StatusRegister&StatusImageBufferValid != 0
+}
+
+//PacketSize - Data packet payload size in bytes, PacketLength holds the code 0-3 for 32, 64, 128 and 256 bytes
+func (p *SystemParameters) PacketSize() int {
+	return 32 << p.PacketLength
+}
+
+//Baud - Serial speed in bps, BaudRate holds the multiplier of BaudRateUnit
+func (p *SystemParameters) Baud() int {
+	return BaudRateUnit * int(p.BaudRate)
+}
+
+//Capabilities - What a module model offers
+type Capabilities struct {
+	Model           string `json:"model"`
+	StorageCapacity int    `json:"storage_capacity"`
+	Instructions    []int  `json:"instructions"`
+}
+
+//Supports - The model implements the instruction
+func (c *Capabilities) Supports(instruction int) bool {
+	for _, i := range c.Instructions {
+		if i == instruction {
+			return true
+		}
+	}
+	return false
+}
+
+//baseInstructions - Implemented by every ZFM compatible module
+var baseInstructions = []int{
+	FINGERPRINT_READIMAGE, FINGERPRINT_CONVERTIMAGE, FINGERPRINT_COMPARECHARACTERISTICS, FINGERPRINT_SEARCHTEMPLATE,
+	FINGERPRINT_CREATETEMPLATE, FINGERPRINT_STORETEMPLATE, FINGERPRINT_LOADTEMPLATE, FINGERPRINT_DOWNLOADCHARACTERISTICS,
+	FINGERPRINT_UPLOADCHARACTERISTICS, FINGERPRINT_DOWNLOADIMAGE, FINGERPRINT_DELETETEMPLATE, FINGERPRINT_CLEARDATABASE,
+	FINGERPRINT_SETSYSTEMPARAMETER, FINGERPRINT_GETSYSTEMPARAMETERS, FINGERPRINT_SETPASSWORD, FINGERPRINT_VERIFYPASSWORD,
+	FINGERPRINT_GENERATERANDOMNUMBER, FINGERPRINT_SETADDRESS, FINGERPRINT_WRITENOTEPAD, FINGERPRINT_READNOTEPAD,
+	FINGERPRINT_TEMPLATECOUNT, FINGERPRINT_TEMPLATEINDEX,
+}
+
+//r50xInstructions - LED ring, on-device workflows and self tests of the R502-A/R503 generation
+var r50xInstructions = []int{
+	FINGERPRINT_AURALEDCONFIG, FINGERPRINT_CANCEL, FINGERPRINT_AUTOENROLL, FINGERPRINT_AUTOIDENTIFY,
+	FINGERPRINT_CHECKSENSOR, FINGERPRINT_READPRODINFO, FINGERPRINT_HANDSHAKE,
+}
+
+//knownModules - Models by the name their product info reports, a capacity of 0 is taken from the system parameters
+var knownModules = []Capabilities{
+	{Model: "R305", Instructions: baseInstructions},
+	{Model: "R307", StorageCapacity: 1000, Instructions: baseInstructions},
+	{Model: "AS608", StorageCapacity: 300, Instructions: baseInstructions},
+	{Model: "R502-A", StorageCapacity: 200, Instructions: append(append([]int(nil), baseInstructions...), r50xInstructions...)},
+	{Model: "R503", StorageCapacity: 200, Instructions: append(append([]int(nil), baseInstructions...), r50xInstructions...)},
+}
+
+//systemIDFamilies - The system ID is fixed per protocol family, it only tells ZFM modules apart from others
+var systemIDFamilies = map[uint]string{
+	0x0009: "ZFM",
+}
+
+//LookupCapabilities - Capabilities of the model, e.g. from the product info. Unknown or empty models get the
+//instructions every module of the family implements and the capacity the system parameters report.
+func LookupCapabilities(p *SystemParameters, model string) *Capabilities {
+	for _, known := range knownModules {
+		if model != "" && strings.HasPrefix(strings.ToUpper(model), known.Model) {
+			c := known
+			if c.StorageCapacity == 0 {
+				c.StorageCapacity = int(p.StorageCapacity)
+			}
+			return &c
+		}
+	}
+
+	c := &Capabilities{Model: systemIDFamilies[p.SystemID], StorageCapacity: int(p.StorageCapacity), Instructions: baseInstructions}
+	if c.Model == "" {
+		c.Model = "unknown"
+	}
+	return c
+}
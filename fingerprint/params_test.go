@@ -0,0 +1,45 @@
+package fingerprint
+
+import "testing"
+
+func TestSystemParameters(t *testing.T) {
+	p := &SystemParameters{StatusRegister: 0xA, SystemID: 9, StorageCapacity: 150, PacketLength: 2, BaudRate: 12}
+	if p.Busy() || !p.Pass() || p.PasswordVerified() || !p.ImageBufferValid() {
+		t.Errorf("status register %#x decoded wrong", p.StatusRegister)
+	}
+	if p.PacketSize() != 128 || p.Baud() != 115200 {
+		t.Errorf("packet size %d, baud %d", p.PacketSize(), p.Baud())
+	}
+}
+
+func TestSystemParametersPacketLength(t *testing.T) {
+	for code := 0; code < 64; code++ {
+		s, _ := newScripted(func(command []byte) [][]byte {
+			return [][]byte{{FINGERPRINT_OK, 0, 0, 0, 9, 0, 200, 0, 3, 0xFF, 0xFF, 0xFF, 0xFF, 0, byte(code), 0, 6}}
+		})
+		p, err := s.GetSystemParameters()
+		if code <= 3 {
+			if err != nil || p.PacketSize() != 32<<code {
+				t.Errorf("code %d: %v", code, err)
+			}
+		} else if err == nil {
+			t.Errorf("code %d accepted, packet size %d", code, p.PacketSize())
+		}
+	}
+}
+
+func TestLookupCapabilities(t *testing.T) {
+	p := &SystemParameters{SystemID: 9, StorageCapacity: 150}
+	if c := LookupCapabilities(p, ""); c.Model != "ZFM" || c.StorageCapacity != 150 || c.Supports(FINGERPRINT_AURALEDCONFIG) {
+		t.Errorf("no model: %+v", c)
+	}
+	if c := LookupCapabilities(p, "r503-xyz"); c.Model != "R503" || c.StorageCapacity != 200 || !c.Supports(FINGERPRINT_AUTOENROLL) {
+		t.Errorf("R503: %+v", c)
+	}
+	if c := LookupCapabilities(p, "R305"); c.StorageCapacity != 150 {
+		t.Errorf("R305: %+v", c)
+	}
+	if c := LookupCapabilities(&SystemParameters{}, "x"); c.Model != "unknown" {
+		t.Errorf("unknown family: %+v", c)
+	}
+}
@@ -0,0 +1,46 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ErrWrongPassword - The module rejected the password
+var ErrWrongPassword = errors.New("wrong password")
+
+//ChangePassword - Verifies the old password, sets the new one and verifies it. When the change fails the host
+//keeps using whichever password the module still accepts, so a half-failed change does not lock the host out.
+func (s *scanner) ChangePassword(oldPassword uint, newPassword uint) error {
+	err := s.changePassword(oldPassword, newPassword)
+	s.record("change_password", nil, err)
+	return err
+}
+
+func (s *scanner) changePassword(oldPassword uint, newPassword uint) error {
+	previous := s.password
+	if err := s.verifyPassword(oldPassword); err != nil {
+		s.password = previous
+		return err
+	}
+	s.password = oldPassword
+
+	errSet := s.setPassword(newPassword)
+	if errSet == nil {
+		errSet = s.verifyPassword(newPassword)
+		if errSet == nil {
+			return nil
+		}
+	}
+
+	//The command may have been applied although its answer got lost, find out which password is in effect
+	if s.verifyPassword(oldPassword) == nil {
+		s.password = oldPassword
+		return fmt.Errorf("password unchanged: %w", errSet)
+	}
+	if s.verifyPassword(newPassword) == nil {
+		s.password = newPassword
+		return nil
+	}
+	s.password = oldPassword
+	return fmt.Errorf("module accepts neither the old nor the new password: %w", errSet)
+}
@@ -0,0 +1,95 @@
+package fingerprint
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+//passwordModule - Keeps a password. loseSetAck applies SetPassword without answering it, ignoreSet confirms it
+//without applying it.
+type passwordModule struct {
+	password   uint
+	loseSetAck bool
+	ignoreSet  bool
+}
+
+func (m *passwordModule) reply(command []byte) [][]byte {
+	switch command[0] {
+	case FINGERPRINT_VERIFYPASSWORD:
+		if be32(command[1:]) == m.password {
+			return [][]byte{{FINGERPRINT_OK}}
+		}
+		return [][]byte{{FINGERPRINT_ERROR_WRONGPASSWORD}}
+	case FINGERPRINT_SETPASSWORD:
+		if m.ignoreSet {
+			return [][]byte{{FINGERPRINT_OK}}
+		}
+		m.password = be32(command[1:])
+		if m.loseSetAck {
+			return nil
+		}
+		return [][]byte{{FINGERPRINT_OK}}
+	}
+	return ack(command)
+}
+
+func newPasswordScanner(password uint) (*scanner, *passwordModule, *scriptLink) {
+	m := &passwordModule{password: password}
+	s, l := newScripted(m.reply)
+	s.password = password
+	return s, m, l
+}
+
+func TestChangePassword(t *testing.T) {
+	s, m, _ := newPasswordScanner(7)
+	if err := s.ChangePassword(7, 9); err != nil || s.password != 9 || m.password != 9 {
+		t.Errorf("ChangePassword = %v, host %d, module %d", err, s.password, m.password)
+	}
+}
+
+func TestChangePasswordWrongOld(t *testing.T) {
+	s, m, l := newPasswordScanner(7)
+	if err := s.ChangePassword(8, 9); err != ErrWrongPassword {
+		t.Errorf("ChangePassword = %v", err)
+	}
+	if s.password != 7 || m.password != 7 || countSent(l, FINGERPRINT_SETPASSWORD) != 0 {
+		t.Errorf("host %d, module %d, sent %x", s.password, m.password, l.sent)
+	}
+}
+
+func TestChangePasswordLostAck(t *testing.T) {
+	s, m, _ := newPasswordScanner(7)
+	m.loseSetAck = true
+	s.responseTimeout = 50 * time.Millisecond
+	//The module took the new password, so the host follows
+	if err := s.ChangePassword(7, 9); err != nil || s.password != 9 || m.password != 9 {
+		t.Errorf("ChangePassword = %v, host %d, module %d", err, s.password, m.password)
+	}
+}
+
+func TestChangePasswordRollback(t *testing.T) {
+	s, m, l := newPasswordScanner(7)
+	m.ignoreSet = true
+	err := s.ChangePassword(7, 9)
+	if err == nil || s.password != 7 {
+		t.Fatalf("ChangePassword = %v, host %d", err, s.password)
+	}
+	if err.Error() != "password unchanged: "+ErrWrongPassword.Error() {
+		t.Errorf("error %q", err)
+	}
+	//Old password, new one, which is rejected, and the old one again
+	want := []uint{7, 9, 7}
+	var verified []uint
+	for _, command := range l.sent {
+		if command[0] == FINGERPRINT_VERIFYPASSWORD {
+			verified = append(verified, be32(command[1:]))
+		}
+	}
+	if !reflect.DeepEqual(verified, want) {
+		t.Errorf("verified %v, want %v", verified, want)
+	}
+	if !s.VerifyPassword() {
+		t.Error("host locked out after the rollback")
+	}
+}
@@ -0,0 +1,88 @@
+package fingerprint
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+//productInfoSize - Length of the product info following the confirmation code
+const productInfoSize = 46
+
+//ProductInfo - Model and manufacturing data answered by ReadProdInfo
+type ProductInfo struct {
+	Model           string `json:"model"`
+	BatchNumber     string `json:"batch_number"`
+	SerialNumber    string `json:"serial_number"`
+	HardwareVersion string `json:"hardware_version"`
+	SensorType      string `json:"sensor_type"`
+	ImageWidth      int    `json:"image_width"`
+	ImageHeight     int    `json:"image_height"`
+	TemplateSize    int    `json:"template_size"`
+	DatabaseSize    int    `json:"database_size"`
+}
+
+//productString - Fixed size ASCII field padded with zeros or spaces
+func productString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(bytes.TrimSpace(b))
+}
+
+func (p *ProductInfo) decode(b []byte) error {
+	if len(b) < productInfoSize {
+		return errShortPayload
+	}
+	p.Model = productString(b[0:16])
+	p.BatchNumber = productString(b[16:20])
+	p.SerialNumber = productString(b[20:28])
+	p.HardwareVersion = fmt.Sprintf("%d.%d", b[28], b[29])
+	p.SensorType = productString(b[30:38])
+	p.ImageWidth = be16(b[38:])
+	p.ImageHeight = be16(b[40:])
+	p.TemplateSize = be16(b[42:])
+	p.DatabaseSize = be16(b[44:])
+	return nil
+}
+
+func getPayloadForReadProdInfo() []byte {
+	return []byte{FINGERPRINT_READPRODINFO}
+}
+
+//ProductInfo - Reads model, batch and serial number, hardware version and sensor geometry.
+//Older modules return ErrNotSupported, they can only be told apart by their system parameters.
+func (s *scanner) ProductInfo() (*ProductInfo, error) {
+	responsePacket, err := s.optionalCommand(FINGERPRINT_READPRODINFO, getPayloadForReadProdInfo())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, errDesc := anyCommonErrors(responsePacket); errDesc != nil {
+		log.Println(errDesc.Error())
+		return nil, errDesc
+	}
+	result := &ProductInfo{}
+	if err = decodePayload(result, responsePacket.PayLoad); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//ModuleCapabilities - Capabilities of the connected module, by its product info where the module has one
+func ModuleCapabilities(s ScannerIO) (*Capabilities, error) {
+	param, err := s.GetSystemParameters()
+	if err != nil {
+		return nil, err
+	}
+	model := ""
+	info, err := s.ProductInfo()
+	switch err {
+	case nil:
+		model = info.Model
+	case ErrNotSupported:
+	default:
+		return nil, err
+	}
+	return LookupCapabilities(param, model), nil
+}
@@ -0,0 +1,63 @@
+package fingerprint
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProductInfoDecode(t *testing.T) {
+	var p ProductInfo
+	if err := p.decode(productInfoR503()[1:]); err != nil {
+		t.Fatal(err)
+	}
+	want := ProductInfo{
+		Model: "R503", BatchNumber: "B001", SerialNumber: "12345678", HardwareVersion: "1.3", SensorType: "FPC1021",
+		ImageWidth: 192, ImageHeight: 192, TemplateSize: 1536, DatabaseSize: 200,
+	}
+	if p != want {
+		t.Errorf("decoded %+v", p)
+	}
+
+	//Fields padded with spaces instead of zeros, bytes after the 46 are ignored
+	b := append(productInfoR503()[1:], 0xEE, 0xEE)
+	copy(b[0:16], "R307            ")
+	copy(b[16:20], "  7 ")
+	if err := p.decode(b); err != nil || p.Model != "R307" || p.BatchNumber != "7" || p.DatabaseSize != 200 {
+		t.Errorf("decoded %+v, %v", p, err)
+	}
+	//Text after the first zero is left out
+	copy(b[0:16], "R305\x00garbage\x00\x00\x00\x00")
+	if err := p.decode(b); err != nil || p.Model != "R305" {
+		t.Errorf("model %q, %v", p.Model, err)
+	}
+
+	if err := p.decode(make([]byte, productInfoSize-1)); err != errShortPayload {
+		t.Errorf("45 bytes: %v", err)
+	}
+}
+
+func TestProductInfo(t *testing.T) {
+	s, l := newScripted(healthModule(FINGERPRINT_OK))
+	p, err := s.ProductInfo()
+	if err != nil || p.Model != "R503" || p.DatabaseSize != 200 || !bytes.Equal(l.sent[0], []byte{FINGERPRINT_READPRODINFO}) {
+		t.Fatalf("ProductInfo = %+v, %v", p, err)
+	}
+	c, err := ModuleCapabilities(s)
+	if err != nil || c.Model != "R503" || c.StorageCapacity != 200 || !c.Supports(FINGERPRINT_AUTOIDENTIFY) {
+		t.Errorf("ModuleCapabilities = %+v, %v", c, err)
+	}
+
+	//Older modules are told apart by the system parameters only
+	s, _ = newScripted(healthModule(FINGERPRINT_OK, FINGERPRINT_READPRODINFO))
+	if _, err = s.ProductInfo(); err != ErrNotSupported {
+		t.Errorf("ProductInfo = %v", err)
+	}
+	if c, err = ModuleCapabilities(s); err != nil || c.Model != "ZFM" || c.Supports(FINGERPRINT_AUTOIDENTIFY) {
+		t.Errorf("ModuleCapabilities = %+v, %v", c, err)
+	}
+
+	s, _ = newScripted(ack)
+	if _, err = s.ProductInfo(); err != errShortPayload {
+		t.Errorf("confirmation code only: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+//Package protocol decodes raw sensor traffic into readable frames, for debugging and tooling
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//headerSize - start code, address, packet type and length
+const headerSize = 9
+
+//ErrTruncated - The data ends inside a frame
+var ErrTruncated = errors.New("frame is truncated")
+
+//Param - One typed parameter of a command or acknowledgement
+type Param struct {
+	Name  string
+	Value uint
+}
+
+//Frame - One decoded packet
+type Frame struct {
+	Offset           int
+	StartCode        uint
+	Address          uint
+	PacketType       byte
+	Length           int
+	Payload          []byte
+	Checksum         uint
+	ChecksumOK       bool
+	Instruction      byte
+	Confirmation     byte
+	Params           []Param
+	Trailing         []byte
+	Err              error
+	forInstruction   byte
+	hasInstruction   bool
+	hasConfirmation  bool
+	knownInstruction bool
+}
+
+type field struct {
+	name string
+	size int
+}
+
+var packetTypeNames = map[byte]string{
+	fingerprint.FINGERPRINT_COMMANDPACKET: "COMMAND",
+	fingerprint.FINGERPRINT_ACKPACKET:     "ACK",
+	fingerprint.FINGERPRINT_DATAPACKET:    "DATA",
+	fingerprint.FINGERPRINT_ENDDATAPACKET: "ENDDATA",
+}
+
+var confirmationText = map[byte]string{
+	fingerprint.FINGERPRINT_OK:                            "OK",
+	fingerprint.FINGERPRINT_ERROR_COMMUNICATION:           "error receiving packet",
+	fingerprint.FINGERPRINT_ERROR_NOFINGER:                "no finger on the sensor",
+	fingerprint.FINGERPRINT_ERROR_READIMAGE:               "failed to enroll the finger",
+	fingerprint.FINGERPRINT_ERROR_MESSYIMAGE:              "image too messy",
+	fingerprint.FINGERPRINT_ERROR_FEWFEATUREPOINTS:        "too few feature points",
+	fingerprint.FINGERPRINT_ERROR_NOTMATCHING:             "fingers do not match",
+	fingerprint.FINGERPRINT_ERROR_NOTEMPLATEFOUND:         "no matching template found",
+	fingerprint.FINGERPRINT_ERROR_CHARACTERISTICSMISMATCH: "failed to combine character files",
+	fingerprint.FINGERPRINT_ERROR_INVALIDPOSITION:         "position out of range",
+	fingerprint.FINGERPRINT_ERROR_LOADTEMPLATE:            "error reading template",
+	fingerprint.FINGERPRINT_ERROR_DOWNLOADCHARACTERISTICS: "error uploading template",
+	fingerprint.FINGERPRINT_PACKETRESPONSEFAIL:            "cannot receive following data packets",
+	fingerprint.FINGERPRINT_ERROR_DOWNLOADIMAGE:           "error uploading image",
+	fingerprint.FINGERPRINT_ERROR_DELETETEMPLATE:          "failed to delete template",
+	fingerprint.FINGERPRINT_ERROR_CLEARDATABASE:           "failed to clear database",
+	fingerprint.FINGERPRINT_ERROR_WRONGPASSWORD:           "wrong password",
+	fingerprint.FINGERPRINT_ERROR_INVALIDIMAGE:            "no valid primary image",
+	fingerprint.FINGERPRINT_ERROR_FLASH:                   "error writing flash",
+	fingerprint.FINGERPRINT_ERROR_INVALIDREGISTER:         "invalid register number",
+	fingerprint.FINGERPRINT_ADDRCODE:                      "wrong address code",
+	fingerprint.FINGERPRINT_PASSVERIFY:                    "password has to be verified",
+	fingerprint.FINGERPRINT_ERROR_LIBRARYFULL:             "library is full",
+	fingerprint.FINGERPRINT_ERROR_FINGERTIMEOUT:           "timed out waiting for the finger",
+	fingerprint.FINGERPRINT_ERROR_ALREADYENROLLED:         "fingerprint already enrolled",
+	fingerprint.FINGERPRINT_ERROR_SENSORABNORMAL:          "sensor abnormal",
+}
+
+//commandParams - Layout of the parameters following the instruction code
+var commandParams = map[byte][]field{
+	fingerprint.FINGERPRINT_CONVERTIMAGE:            {{"charBuffer", 1}},
+	fingerprint.FINGERPRINT_SEARCHTEMPLATE:          {{"charBuffer", 1}, {"startPosition", 2}, {"count", 2}},
+	fingerprint.FINGERPRINT_FASTSEARCH:              {{"charBuffer", 1}, {"startPosition", 2}, {"count", 2}},
+	fingerprint.FINGERPRINT_STORETEMPLATE:           {{"charBuffer", 1}, {"position", 2}},
+	fingerprint.FINGERPRINT_LOADTEMPLATE:            {{"charBuffer", 1}, {"position", 2}},
+	fingerprint.FINGERPRINT_DOWNLOADCHARACTERISTICS: {{"charBuffer", 1}},
+	fingerprint.FINGERPRINT_UPLOADCHARACTERISTICS:   {{"charBuffer", 1}},
+	fingerprint.FINGERPRINT_DELETETEMPLATE:          {{"position", 2}, {"count", 2}},
+	fingerprint.FINGERPRINT_SETSYSTEMPARAMETER:      {{"parameter", 1}, {"content", 1}},
+	fingerprint.FINGERPRINT_SETPASSWORD:             {{"password", 4}},
+	fingerprint.FINGERPRINT_VERIFYPASSWORD:          {{"password", 4}},
+	fingerprint.FINGERPRINT_SETADDRESS:              {{"address", 4}},
+	fingerprint.FINGERPRINT_TEMPLATEINDEX:           {{"page", 1}},
+	fingerprint.FINGERPRINT_WRITENOTEPAD:            {{"page", 1}},
+	fingerprint.FINGERPRINT_READNOTEPAD:             {{"page", 1}},
+	fingerprint.FINGERPRINT_AURALEDCONFIG:           {{"mode", 1}, {"speed", 1}, {"color", 1}, {"count", 1}},
+	fingerprint.FINGERPRINT_AUTOENROLL:              {{"position", 2}, {"captures", 1}, {"flags", 2}},
+	fingerprint.FINGERPRINT_AUTOIDENTIFY:            {{"securityLevel", 1}, {"startPosition", 2}, {"count", 2}, {"flags", 2}, {"attempts", 1}},
+}
+
+//ackParams - Layout of the parameters following the confirmation code, by the instruction answered
+var ackParams = map[byte][]field{
+	fingerprint.FINGERPRINT_SEARCHTEMPLATE:         {{"position", 2}, {"score", 2}},
+	fingerprint.FINGERPRINT_FASTSEARCH:             {{"position", 2}, {"score", 2}},
+	fingerprint.FINGERPRINT_COMPARECHARACTERISTICS: {{"score", 2}},
+	fingerprint.FINGERPRINT_TEMPLATECOUNT:          {{"count", 2}},
+	fingerprint.FINGERPRINT_GENERATERANDOMNUMBER:   {{"number", 4}},
+	fingerprint.FINGERPRINT_AUTOENROLL:             {{"step", 1}, {"entry", 1}},
+	fingerprint.FINGERPRINT_AUTOIDENTIFY:           {{"step", 1}, {"position", 2}, {"score", 2}},
+	fingerprint.FINGERPRINT_GETSYSTEMPARAMETERS: {
+		{"statusRegister", 2}, {"systemID", 2}, {"storageCapacity", 2}, {"securityLevel", 2},
+		{"deviceAddress", 4}, {"packetLength", 2}, {"baudRate", 2},
+	},
+}
+
+//InstructionName - Name of an instruction code, as in constant.go without the prefix
+func InstructionName(code byte) string {
+	return fingerprint.InstructionName(code)
+}
+
+//PacketTypeName - COMMAND, ACK, DATA or ENDDATA
+func PacketTypeName(packetType byte) string {
+	if name, ok := packetTypeNames[packetType]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(0x%02X)", packetType)
+}
+
+//ConfirmationText - Meaning of a confirmation code
+func ConfirmationText(code byte) string {
+	if text, ok := confirmationText[code]; ok {
+		return text
+	}
+	return "unknown confirmation code"
+}
+
+//Decoder - Decodes traffic fed in chunks, acknowledgements are matched to the last command seen
+type Decoder struct {
+	lastInstruction byte
+	haveCommand     bool
+}
+
+//Next - Returns the complete frames of data and the bytes of a frame that is not complete yet. Like the driver,
+//bytes before a start code are skipped, and so is a start code followed by an impossible length.
+func (d *Decoder) Next(data []byte) ([]Frame, []byte) {
+	var frames []Frame
+	rest := data
+	for {
+		packet, next := fingerprint.NextPacket(rest)
+		if packet == nil {
+			return frames, next
+		}
+		frame := decodeFrame(packet, d.lastInstruction, d.haveCommand)
+		frame.Offset = len(data) - len(next) - len(packet)
+		frames = append(frames, frame)
+		if frame.hasInstruction {
+			d.lastInstruction = frame.Instruction
+			d.haveCommand = true
+		}
+		rest = next
+	}
+}
+
+//Decode - Splits a complete dump into frames, a frame cut off at the end is returned with ErrTruncated
+func Decode(data []byte) []Frame {
+	var d Decoder
+	frames, rest := d.Next(data)
+	if len(rest) > 0 {
+		frame := decodeFrame(rest, d.lastInstruction, d.haveCommand)
+		frame.Offset = len(data) - len(rest)
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func decodeFrame(data []byte, lastInstruction byte, haveCommand bool) Frame {
+	var frame Frame
+	if len(data) < headerSize {
+		frame.Err = ErrTruncated
+		return frame
+	}
+	frame.StartCode = uint(binary.BigEndian.Uint16(data[0:2]))
+	frame.Address = uint(binary.BigEndian.Uint32(data[2:6]))
+	frame.PacketType = data[6]
+	frame.Length = int(binary.BigEndian.Uint16(data[7:9]))
+	if frame.Length < 2 {
+		frame.Err = fmt.Errorf("length %d leaves no room for the checksum", frame.Length)
+		return frame
+	}
+	if len(data) < headerSize+frame.Length {
+		frame.Err = ErrTruncated
+		frame.Payload = data[headerSize:]
+		return frame
+	}
+	frame.Payload = data[headerSize : headerSize+frame.Length-2]
+	frame.Checksum = uint(binary.BigEndian.Uint16(data[headerSize+frame.Length-2:]))
+
+	frame.ChecksumOK = fingerprint.Checksum(frame.PacketType, frame.Length, frame.Payload) == frame.Checksum
+
+	if len(frame.Payload) == 0 {
+		return frame
+	}
+	switch frame.PacketType {
+	case fingerprint.FINGERPRINT_COMMANDPACKET:
+		frame.Instruction = frame.Payload[0]
+		frame.hasInstruction = true
+		frame.Params, frame.Trailing = decodeParams(commandParams[frame.Instruction], frame.Payload[1:])
+	case fingerprint.FINGERPRINT_ACKPACKET:
+		frame.Confirmation = frame.Payload[0]
+		frame.hasConfirmation = true
+		if haveCommand {
+			frame.forInstruction = lastInstruction
+			frame.knownInstruction = true
+			if frame.Confirmation == fingerprint.FINGERPRINT_OK {
+				frame.Params, frame.Trailing = decodeParams(ackParams[lastInstruction], frame.Payload[1:])
+				break
+			}
+		}
+		frame.Trailing = frame.Payload[1:]
+	}
+	return frame
+}
+
+//decodeParams - Big endian fields, bytes the layout does not cover are returned as trailing
+func decodeParams(layout []field, data []byte) ([]Param, []byte) {
+	var params []Param
+	for _, f := range layout {
+		if len(data) < f.size {
+			break
+		}
+		var value uint
+		for _, b := range data[:f.size] {
+			value = value<<8 | uint(b)
+		}
+		params = append(params, Param{Name: f.name, Value: value})
+		data = data[f.size:]
+	}
+	return params, data
+}
+
+//String - Multi-line description of the frame
+func (f Frame) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@%d %s address=0x%08X length=%d", f.Offset, PacketTypeName(f.PacketType), f.Address, f.Length)
+	if f.Err != nil {
+		fmt.Fprintf(&sb, "\n  error: %v", f.Err)
+		return sb.String()
+	}
+	if f.ChecksumOK {
+		fmt.Fprintf(&sb, " checksum=0x%04X ok", f.Checksum)
+	} else {
+		fmt.Fprintf(&sb, " checksum=0x%04X BAD", f.Checksum)
+	}
+	if f.hasInstruction {
+		fmt.Fprintf(&sb, "\n  instruction 0x%02X %s", f.Instruction, InstructionName(f.Instruction))
+	}
+	if f.hasConfirmation {
+		fmt.Fprintf(&sb, "\n  confirmation 0x%02X %s", f.Confirmation, ConfirmationText(f.Confirmation))
+		if f.knownInstruction {
+			fmt.Fprintf(&sb, " (reply to %s)", InstructionName(f.forInstruction))
+		}
+	}
+	if len(f.Params) > 0 {
+		sb.WriteString("\n ")
+		for _, p := range f.Params {
+			fmt.Fprintf(&sb, " %s=%d", p.Name, p.Value)
+		}
+	}
+	if f.PacketType == fingerprint.FINGERPRINT_DATAPACKET || f.PacketType == fingerprint.FINGERPRINT_ENDDATAPACKET {
+		fmt.Fprintf(&sb, "\n  data % X", f.Payload)
+	} else if len(f.Trailing) > 0 {
+		fmt.Fprintf(&sb, "\n  trailing % X", f.Trailing)
+	}
+	return sb.String()
+}
+
+//ParseHex - Reads a dump as hex ("EF 01 FF", "ef01ff", "0xEF,0x01") or as the decimal debug output "[239 1 255]"
+func ParseHex(dump string) ([]byte, error) {
+	dump = strings.TrimSpace(dump)
+	base := 16
+	if strings.HasPrefix(dump, "[") {
+		base = 10
+	}
+	fields := strings.FieldsFunc(dump, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '[' || r == ']' || r == '\n' || r == '\r' || r == '\t' || r == ':'
+	})
+
+	var data []byte
+	for _, f := range fields {
+		if base == 10 {
+			b, err := strconv.ParseUint(f, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid byte %q", f)
+			}
+			data = append(data, byte(b))
+			continue
+		}
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "0x"), "0X")
+		if len(f)%2 != 0 {
+			return nil, fmt.Errorf("odd number of hex digits in %q", f)
+		}
+		for i := 0; i < len(f); i += 2 {
+			b, err := strconv.ParseUint(f[i:i+2], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex %q", f[i:i+2])
+			}
+			data = append(data, byte(b))
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//packet - A frame to the default address
+func packet(packetType byte, payload ...byte) []byte {
+	length := len(payload) + 2
+	b := []byte{0xEF, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, packetType, byte(length >> 8), byte(length)}
+	b = append(b, payload...)
+	sum := fingerprint.Checksum(packetType, length, payload)
+	return append(b, byte(sum>>8), byte(sum))
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+//summary - Offset, instruction or confirmation and checksum state of each frame
+type summary struct {
+	Offset int
+	Code   byte
+	OK     bool
+}
+
+func summarize(frames []Frame) []summary {
+	var s []summary
+	for _, f := range frames {
+		code := f.Instruction
+		if f.hasConfirmation {
+			code = f.Confirmation
+		}
+		s = append(s, summary{f.Offset, code, f.ChecksumOK && f.Err == nil})
+	}
+	return s
+}
+
+var (
+	search = packet(fingerprint.FINGERPRINT_COMMANDPACKET, fingerprint.FINGERPRINT_SEARCHTEMPLATE, 1, 0, 0, 0x03, 0xE8)
+	found  = packet(fingerprint.FINGERPRINT_ACKPACKET, fingerprint.FINGERPRINT_OK, 0, 7, 0, 99)
+	count  = packet(fingerprint.FINGERPRINT_COMMANDPACKET, fingerprint.FINGERPRINT_TEMPLATECOUNT)
+)
+
+func TestDecode(t *testing.T) {
+	badChecksum := append([]byte(nil), found...)
+	badChecksum[len(badChecksum)-1]++
+
+	for _, c := range []struct {
+		name string
+		data []byte
+		want []summary
+	}{
+		{"command and ack", join(search, found), []summary{{0, 0x04, true}, {17, 0x00, true}}},
+		{"noise in front", join([]byte{0x00, 0x13}, search), []summary{{2, 0x04, true}}},
+		{"noise between", join(search, []byte{0x55}, found), []summary{{0, 0x04, true}, {18, 0x00, true}}},
+		//A start code in the noise with a length no packet has
+		{"false start code", join(search, []byte{0xEF, 0x01, 0, 0, 0, 0, 0, 0, 1}, found, count),
+			[]summary{{0, 0x04, true}, {26, 0x00, true}, {42, 0x1D, true}}},
+		{"start code with an oversized length", join([]byte{0xEF, 0x01, 0, 0, 0, 0, 1, 0xFF, 0xFF}, count),
+			[]summary{{9, 0x1D, true}}},
+		{"bad checksum", join(badChecksum, count), []summary{{0, 0x00, false}, {16, 0x1D, true}}},
+		{"truncated", join(search, found[:10]), []summary{{0, 0x04, true}, {17, 0x00, false}}},
+	} {
+		if got := summarize(Decode(c.data)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	frames := Decode(join(search, found))
+	want := [][]Param{
+		{{"charBuffer", 1}, {"startPosition", 0}, {"count", 1000}},
+		{{"position", 7}, {"score", 99}},
+	}
+	for i, f := range frames {
+		if !reflect.DeepEqual(f.Params, want[i]) {
+			t.Errorf("frame %d params %+v, want %+v", i, f.Params, want[i])
+		}
+	}
+	if frames[1].forInstruction != fingerprint.FINGERPRINT_SEARCHTEMPLATE {
+		t.Errorf("ack matched to 0x%02X", frames[1].forInstruction)
+	}
+	if frames[1].Err != nil || Decode(found[:10])[0].Err != ErrTruncated {
+		t.Error("truncated frame not reported")
+	}
+}
+
+func TestDecoderChunks(t *testing.T) {
+	data := join([]byte{0x42}, search, found, count)
+	for split := 0; split <= len(data); split++ {
+		var d Decoder
+		frames, pending := d.Next(data[:split])
+		more, pending := d.Next(append(pending, data[split:]...))
+		if got := len(frames) + len(more); got != 3 || len(pending) != 0 {
+			t.Fatalf("split at %d: %d frames, %d bytes pending", split, got, len(pending))
+		}
+		if len(more) > 0 && more[len(more)-1].Instruction != fingerprint.FINGERPRINT_TEMPLATECOUNT {
+			t.Fatalf("split at %d: last frame %+v", split, more[len(more)-1])
+		}
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	want := []byte{0xEF, 0x01, 0xFF}
+	for _, dump := range []string{"EF 01 FF", "ef01ff", "0xEF,0x01,0xFF", "[239 1 255]", "EF:01:FF\n"} {
+		if got, err := ParseHex(dump); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%q = % X, %v", dump, got, err)
+		}
+	}
+	for _, dump := range []string{"EF0", "EG", "[256]"} {
+		if _, err := ParseHex(dump); err == nil {
+			t.Errorf("%q accepted", dump)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(join(search, found))
+	f.Add(join([]byte{0xEF, 0x01, 0, 0, 0, 0, 0, 0, 1}, count))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, frame := range Decode(data) {
+			_ = frame.String()
+		}
+	})
+}
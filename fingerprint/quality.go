@@ -0,0 +1,234 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint/match"
+)
+
+//ErrPoorImage - The captured image is not good enough to extract reliable characteristics
+var ErrPoorImage = errors.New("fingerprint image quality too low")
+
+//Hints of ImageQuality, directions are relative to the image
+const (
+	HintPlaceFinger  = "place a finger on the sensor"
+	HintPressHarder  = "press harder"
+	HintPressLighter = "press lighter"
+	HintMoveLeft     = "move left"
+	HintMoveRight    = "move right"
+	HintMoveUp       = "move up"
+	HintMoveDown     = "move down"
+	HintCleanSensor  = "wipe the finger and the sensor"
+)
+
+//DefaultMinQuality - Score below which EnrollWithQuality rejects a sample
+const DefaultMinQuality = 50
+
+//ImageQuality - Host side assessment of a finger image. Contrast, Coverage and Coherence are 0-1,
+//OffsetX and OffsetY of the finger centre are -1 (left, top) to 1 (right, bottom). Score combines them as 0-100.
+type ImageQuality struct {
+	Contrast   float64  `json:"contrast"`
+	Brightness float64  `json:"brightness"`
+	Coverage   float64  `json:"coverage"`
+	OffsetX    float64  `json:"offset_x"`
+	OffsetY    float64  `json:"offset_y"`
+	Coherence  float64  `json:"coherence"`
+	Score      int      `json:"score"`
+	Hints      []string `json:"hints,omitempty"`
+}
+
+func (q *ImageQuality) String() string {
+	return fmt.Sprintf("score %d (contrast %.2f, coverage %.2f, offset %.2f/%.2f, coherence %.2f) %v",
+		q.Score, q.Contrast, q.Coverage, q.OffsetX, q.OffsetY, q.Coherence, q.Hints)
+}
+
+//QualityError - Returned by EnrollWithQuality when no sample reached the minimum score
+type QualityError struct {
+	Quality *ImageQuality
+}
+
+func (e *QualityError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrPoorImage, e.Quality)
+}
+
+//Is - Lets errors.Is match ErrPoorImage
+func (e *QualityError) Is(target error) bool {
+	return target == ErrPoorImage
+}
+
+//AssessImage - Scores an image decoded with DecodeImage. Blocks with enough grey level deviation are the finger,
+//contrast is their median deviation relative to the largest possible one, ridge clarity the mean coherence of the
+//gradient orientation per block. Medians keep the blocks at the edge of the finger from dominating.
+func AssessImage(img *image.Gray) *ImageQuality {
+	q := &ImageQuality{}
+	all, cols, rows := match.Segment(img)
+
+	var means, deviations []float64
+	var sumX, sumY, coherence float64
+	for i := range all {
+		b := &all[i]
+		if !b.Foreground() {
+			continue
+		}
+		means = append(means, b.Mean)
+		deviations = append(deviations, b.Deviation)
+		sumX += float64(b.Col) + 0.5
+		sumY += float64(b.Row) + 0.5
+		coherence += blockCoherence(img, b.Rect)
+	}
+	blocks := len(means)
+	if blocks == 0 {
+		q.Hints = []string{HintPlaceFinger}
+		return q
+	}
+
+	q.Coverage = float64(blocks) / float64(cols*rows)
+	q.Brightness = median(means) / 255
+	q.Contrast = math.Min(median(deviations)/127.5, 1)
+	q.OffsetX = sumX/float64(blocks)/float64(cols)*2 - 1
+	q.OffsetY = sumY/float64(blocks)/float64(rows)*2 - 1
+	q.Coherence = coherence / float64(blocks)
+
+	offset := math.Max(math.Abs(q.OffsetX), math.Abs(q.OffsetY))
+	//Without clear ridges there are no features to extract, whatever the rest looks like
+	score := 0.3*clamp01(q.Contrast/0.5) + 0.45*clamp01(q.Coverage/0.6) + 0.25*clamp01(1-offset/0.5)
+	score *= clamp01(q.Coherence / 0.7)
+	q.Score = int(math.Round(100 * score))
+	q.Hints = qualityHints(q)
+	return q
+}
+
+//qualityHints - Advice for the next placement, most useful first
+func qualityHints(q *ImageQuality) []string {
+	var hints []string
+	switch {
+	case q.Contrast < 0.25 && q.Brightness < 0.4:
+		hints = append(hints, HintPressLighter)
+	case q.Contrast < 0.25 || q.Coverage < 0.4:
+		hints = append(hints, HintPressHarder)
+	}
+	if q.OffsetX < -0.25 {
+		hints = append(hints, HintMoveRight)
+	} else if q.OffsetX > 0.25 {
+		hints = append(hints, HintMoveLeft)
+	}
+	if q.OffsetY < -0.25 {
+		hints = append(hints, HintMoveDown)
+	} else if q.OffsetY > 0.25 {
+		hints = append(hints, HintMoveUp)
+	}
+	if q.Coherence < 0.5 {
+		hints = append(hints, HintCleanSensor)
+	}
+	return hints
+}
+
+//blockCoherence - 1 for parallel ridges, 0 for noise, from the Sobel gradients inside the block
+func blockCoherence(img *image.Gray, block image.Rectangle) float64 {
+	bounds := img.Bounds()
+	at := func(x, y int) float64 {
+		if x < bounds.Min.X {
+			x = bounds.Min.X
+		} else if x >= bounds.Max.X {
+			x = bounds.Max.X - 1
+		}
+		if y < bounds.Min.Y {
+			y = bounds.Min.Y
+		} else if y >= bounds.Max.Y {
+			y = bounds.Max.Y - 1
+		}
+		return float64(img.GrayAt(x, y).Y)
+	}
+
+	var gxx, gyy, gxy float64
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			gxx += gx * gx
+			gyy += gy * gy
+			gxy += gx * gy
+		}
+	}
+	if gxx+gyy == 0 {
+		return 0
+	}
+	return math.Sqrt((gxx-gyy)*(gxx-gyy)+4*gxy*gxy) / (gxx + gyy)
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	return values[len(values)/2]
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+//QualityCheck - Host side image check of EnrollWithQuality. Zero values use DefaultMinQuality and 3 attempts.
+//OnReject receives every rejected sample, e.g. to show its hints. Width and Height are the image geometry,
+//0 for the sizes DecodeImage knows; images that cannot be decoded are converted without the check.
+type QualityCheck struct {
+	MinScore int
+	Attempts int
+	OnReject func(q *ImageQuality)
+	Width    int
+	Height   int
+}
+
+//EnrollWithQuality - Enroll with every capture downloaded and scored by AssessImage before it is converted.
+//A sample below MinScore is reported with EnrollPoorQuality and the finger is asked for again. As the images
+//are needed on the host, the steps are always host driven, also on modules implementing AutoEnroll.
+func EnrollWithQuality(ctx context.Context, s ScannerIO, position int, check QualityCheck, progress func(EnrollStep)) (int, error) {
+	if check.MinScore <= 0 {
+		check.MinScore = DefaultMinQuality
+	}
+	if check.Attempts <= 0 {
+		check.Attempts = 3
+	}
+	report := func(step EnrollStep) {
+		if progress != nil {
+			progress(step)
+		}
+	}
+
+	capture := func(ctx context.Context, s ScannerIO, charBufferNo int) error {
+		var q *ImageQuality
+		for attempt := 0; attempt < check.Attempts; attempt++ {
+			if attempt > 0 {
+				if err := waitForRelease(ctx, s); err != nil {
+					return err
+				}
+			}
+			raw, err := CaptureImage(ctx, s)
+			if err != nil {
+				return err
+			}
+			img, err := DecodeImage(raw, check.Width, check.Height)
+			if err != nil {
+				//A sensor size DecodeImage does not know should not stop the enrollment
+				log.Println("Skipping the quality check:", err.Error())
+			} else {
+				q = AssessImage(img)
+			}
+			if err != nil || q.Score >= check.MinScore {
+				if s.ConvertImage(charBufferNo) == false {
+					return errors.New("unable to convert the image")
+				}
+				return nil
+			}
+			if check.OnReject != nil {
+				check.OnReject(q)
+			}
+			report(EnrollPoorQuality)
+		}
+		return &QualityError{Quality: q}
+	}
+	return enroll(ctx, s, position, report, capture)
+}
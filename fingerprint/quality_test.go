@@ -0,0 +1,88 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"testing"
+)
+
+//ridgeImage - Ridges of 9 pixels period inside an ellipse, on the white of the empty sensor
+func ridgeImage(width, height int, cx, cy, rx, ry float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := 255.0
+			dx, dy := (float64(x)-cx)/rx, (float64(y)-cy)/ry
+			if dx*dx+dy*dy < 1 {
+				v = 255 - 220*(0.5+0.5*math.Sin(float64(x+y)*2*math.Pi/9))
+			}
+			img.Pix[y*width+x] = byte(math.Round(v/17)) * 17
+		}
+	}
+	return img
+}
+
+//imageScanner - Shows the images one by one, the finger is lifted between them
+type imageScanner struct {
+	ScannerIO
+	images   [][]byte
+	pressed  bool
+	converts int
+}
+
+func (f *imageScanner) ReadImage() bool {
+	f.pressed = !f.pressed
+	return f.pressed
+}
+
+func (f *imageScanner) DownloadImage() ([]byte, error) {
+	img := f.images[0]
+	f.images = f.images[1:]
+	return img, nil
+}
+
+func (f *imageScanner) ConvertImage(charBufferNo int) bool {
+	f.converts++
+	return true
+}
+
+func (f *imageScanner) CompareCharacteristics() (int, error) {
+	return 80, nil
+}
+
+func (f *imageScanner) SearchTemplate(charBufferNo int, startPos int, count int) (*SearchResult, error) {
+	return &SearchResult{PositionNumber: -1}, nil
+}
+
+func (f *imageScanner) CreateTemplate() error {
+	return nil
+}
+
+func (f *imageScanner) StoreTemplate(position int, charBufferNo int) (int, error) {
+	return 5, nil
+}
+
+func TestEnrollWithQualityImageSize(t *testing.T) {
+	//A sensor size DecodeImage does not know
+	good := PackImage(ridgeImage(160, 160, 80, 80, 70, 70))
+	bad := PackImage(ridgeImage(160, 160, 20, 80, 20, 40))
+	ctx := context.Background()
+
+	f := &imageScanner{images: [][]byte{bad, bad}}
+	position, err := EnrollWithQuality(ctx, f, -1, QualityCheck{MinScore: 60}, nil)
+	if err != nil || position != 5 || f.converts != 2 {
+		t.Fatalf("unknown size: position %d, %v, %d converted", position, err, f.converts)
+	}
+
+	f = &imageScanner{images: [][]byte{bad, bad, good, good}}
+	check := QualityCheck{MinScore: 60, Attempts: 2, Width: 160, Height: 160}
+	if _, err = EnrollWithQuality(ctx, f, -1, check, nil); !errors.Is(err, ErrPoorImage) || f.converts != 0 {
+		t.Fatalf("given size: %v, %d converted", err, f.converts)
+	}
+	f.images = [][]byte{good, good}
+	if _, err = EnrollWithQuality(ctx, f, -1, check, nil); err != nil || f.converts != 2 {
+		t.Fatalf("given size: %v, %d converted", err, f.converts)
+	}
+}
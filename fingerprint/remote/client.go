@@ -0,0 +1,348 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+//DefaultCallTimeout - Deadline of every unary call made through the ScannerIO methods
+const DefaultCallTimeout = 30 * time.Second
+
+//Client - Remote scanner, implements fingerprint.ScannerIO so existing code can use it unchanged
+type Client struct {
+	CallTimeout time.Duration
+
+	cc   grpc.ClientConnInterface
+	conn *grpc.ClientConn
+}
+
+var _ fingerprint.ScannerIO = (*Client)(nil)
+
+//NewClient - Client on an existing connection, the caller stays responsible for closing it
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc, CallTimeout: DefaultCallTimeout}
+}
+
+//Dial - Client on a new insecure connection to target unless opts carry credentials, Release closes it
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(conn)
+	c.conn = conn
+	return c, nil
+}
+
+func (c *Client) invoke(method string, req message, reply message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.CallTimeout)
+	defer cancel()
+	return fromStatus(c.cc.Invoke(ctx, "/"+serviceName+"/"+method, req, reply, callOption()))
+}
+
+//Capture - Checks the remote sensor answers, the port itself is owned by the server
+func (c *Client) Capture() error {
+	return c.invoke("Capture", &Empty{}, &Empty{})
+}
+
+//Release - Closes the connection if it was opened by Dial
+func (c *Client) Release() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *Client) VerifyPassword() bool {
+	reply := &BoolValue{}
+	if err := c.invoke("VerifyPassword", &Empty{}, reply); err != nil {
+		return false
+	}
+	return reply.Value
+}
+
+func (c *Client) GetSystemParameters() (*fingerprint.SystemParameters, error) {
+	reply := &SystemParameters{}
+	if err := c.invoke("GetSystemParameters", &Empty{}, reply); err != nil {
+		return nil, err
+	}
+	return &fingerprint.SystemParameters{
+		StatusRegister:  reply.StatusRegister,
+		SystemID:        reply.SystemID,
+		StorageCapacity: reply.StorageCapacity,
+		SecurityLevel:   reply.SecurityLevel,
+		DeviceAddress:   reply.DeviceAddress,
+		PacketLength:    reply.PacketLength,
+		BaudRate:        reply.BaudRate,
+	}, nil
+}
+
+func (c *Client) ReadImage() bool {
+	reply := &BoolValue{}
+	if err := c.invoke("ReadImage", &Empty{}, reply); err != nil {
+		return false
+	}
+	return reply.Value
+}
+
+func (c *Client) DeleteFingerprint(position int, count int) (bool, error) {
+	reply := &BoolValue{}
+	if err := c.invoke("DeleteFingerprint", &DeleteRequest{Position: position, Count: count}, reply); err != nil {
+		return false, err
+	}
+	return reply.Value, nil
+}
+
+func (c *Client) ConvertImage(charBufferNo int) bool {
+	reply := &BoolValue{}
+	if err := c.invoke("ConvertImage", &CharBuffer{CharBuffer: charBufferNo}, reply); err != nil {
+		return false
+	}
+	return reply.Value
+}
+
+func (c *Client) SearchTemplate(charBufferNo int, startPos int, count int) (*fingerprint.SearchResult, error) {
+	reply := &SearchResult{}
+	req := &SearchRequest{CharBuffer: charBufferNo, StartPosition: startPos, Count: count}
+	if err := c.invoke("SearchTemplate", req, reply); err != nil {
+		return nil, err
+	}
+	return &fingerprint.SearchResult{PositionNumber: reply.Position, AccuracyScore: reply.AccuracyScore}, nil
+}
+
+func (c *Client) FastSearchTemplate(charBufferNo int, startPos int, count int) (*fingerprint.SearchResult, error) {
+	reply := &SearchResult{}
+	req := &SearchRequest{CharBuffer: charBufferNo, StartPosition: startPos, Count: count}
+	if err := c.invoke("FastSearchTemplate", req, reply); err != nil {
+		return nil, err
+	}
+	return &fingerprint.SearchResult{PositionNumber: reply.Position, AccuracyScore: reply.AccuracyScore}, nil
+}
+
+func (c *Client) SearchGroup(charBufferNo int, name string) (*fingerprint.SearchResult, error) {
+	reply := &SearchResult{}
+	if err := c.invoke("SearchGroup", &SearchGroupRequest{CharBuffer: charBufferNo, Name: name}, reply); err != nil {
+		return nil, err
+	}
+	return &fingerprint.SearchResult{PositionNumber: reply.Position, AccuracyScore: reply.AccuracyScore}, nil
+}
+
+func (c *Client) SlotRange(name string) (fingerprint.SlotRange, error) {
+	reply := &SlotRange{}
+	if err := c.invoke("SlotRange", &SlotRange{Name: name}, reply); err != nil {
+		return fingerprint.SlotRange{}, err
+	}
+	return fingerprint.SlotRange{Name: reply.Name, First: reply.First, Last: reply.Last}, nil
+}
+
+func (c *Client) ReserveSlot(group string) (int, error) {
+	reply := &Position{}
+	if err := c.invoke("ReserveSlot", &SlotRange{Name: group}, reply); err != nil {
+		return -1, err
+	}
+	return reply.Position, nil
+}
+
+func (c *Client) ReserveSlotAt(position int) error {
+	return c.invoke("ReserveSlotAt", &Position{Position: position}, &Empty{})
+}
+
+//ReleaseSlot - A reservation the server cannot release is gone with the server
+func (c *Client) ReleaseSlot(position int) {
+	c.invoke("ReleaseSlot", &Position{Position: position}, &Empty{})
+}
+
+func (c *Client) CreateTemplate() error {
+	return c.invoke("CreateTemplate", &Empty{}, &Empty{})
+}
+
+func (c *Client) StoreTemplate(position int, CharBufferNo int) (int, error) {
+	reply := &Position{}
+	if err := c.invoke("StoreTemplate", &TemplateRequest{Position: position, CharBuffer: CharBufferNo}, reply); err != nil {
+		return -1, err
+	}
+	return reply.Position, nil
+}
+
+func (c *Client) ClearDatabase() error {
+	return c.invoke("ClearDatabase", &Empty{}, &Empty{})
+}
+
+func (c *Client) CompareCharacteristics() (int, error) {
+	reply := &Score{}
+	if err := c.invoke("CompareCharacteristics", &Empty{}, reply); err != nil {
+		return 0, err
+	}
+	return reply.Score, nil
+}
+
+func (c *Client) LoadTemplate(Position int, CharBufferNo int) error {
+	return c.invoke("LoadTemplate", &TemplateRequest{Position: Position, CharBuffer: CharBufferNo}, &Empty{})
+}
+
+func (c *Client) TemplateIndex() ([]bool, error) {
+	reply := &TemplateIndex{}
+	if err := c.invoke("TemplateIndex", &Empty{}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Used, nil
+}
+
+func (c *Client) DownloadCharacteristics(charBufferNo int) ([]byte, error) {
+	reply := &Data{}
+	if err := c.invoke("DownloadCharacteristics", &CharBuffer{CharBuffer: charBufferNo}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (c *Client) UploadCharacteristics(charBufferNo int, data []byte) error {
+	return c.invoke("UploadCharacteristics", &UploadRequest{CharBuffer: charBufferNo, Data: data}, &Empty{})
+}
+
+func (c *Client) DownloadImage() ([]byte, error) {
+	reply := &Data{}
+	if err := c.invoke("DownloadImage", &Empty{}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (c *Client) UploadImage(data []byte) error {
+	return c.invoke("UploadImage", &Data{Data: data}, &Empty{})
+}
+
+func (c *Client) SetSystemParameter(parameterNo int, content int) error {
+	return c.invoke("SetSystemParameter", &SystemParameterRequest{Parameter: parameterNo, Content: content}, &Empty{})
+}
+
+func (c *Client) SetLED(color fingerprint.LEDColor, mode fingerprint.LEDMode, speed int, count int) error {
+	return c.invoke("SetLED", &LEDRequest{Color: int(color), Mode: int(mode), Speed: speed, Count: count}, &Empty{})
+}
+
+func (c *Client) ReadNotepad(page int) ([]byte, error) {
+	reply := &Data{}
+	if err := c.invoke("ReadNotepad", &NotepadRequest{Page: page}, reply); err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+func (c *Client) WriteNotepad(page int, data []byte) error {
+	return c.invoke("WriteNotepad", &NotepadRequest{Page: page, Data: data}, &Empty{})
+}
+
+func (c *Client) Handshake() error {
+	return c.invoke("Handshake", &Empty{}, &Empty{})
+}
+
+func (c *Client) CheckSensor() error {
+	return c.invoke("CheckSensor", &Empty{}, &Empty{})
+}
+
+func (c *Client) GenerateRandomNumber() (uint32, error) {
+	reply := &RandomNumber{}
+	if err := c.invoke("GenerateRandomNumber", &Empty{}, reply); err != nil {
+		return 0, err
+	}
+	return reply.Value, nil
+}
+
+func (c *Client) ProductInfo() (*fingerprint.ProductInfo, error) {
+	reply := &ProductInfo{}
+	if err := c.invoke("ProductInfo", &Empty{}, reply); err != nil {
+		return nil, err
+	}
+	return &fingerprint.ProductInfo{
+		Model:           reply.Model,
+		BatchNumber:     reply.BatchNumber,
+		SerialNumber:    reply.SerialNumber,
+		HardwareVersion: reply.HardwareVersion,
+		SensorType:      reply.SensorType,
+		ImageWidth:      reply.ImageWidth,
+		ImageHeight:     reply.ImageHeight,
+		TemplateSize:    reply.TemplateSize,
+		DatabaseSize:    reply.DatabaseSize,
+	}, nil
+}
+
+func (c *Client) SetPassword(password uint) error {
+	return c.invoke("SetPassword", &PasswordRequest{Password: password}, &Empty{})
+}
+
+func (c *Client) ChangePassword(oldPassword uint, newPassword uint) error {
+	return c.invoke("ChangePassword", &ChangePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword}, &Empty{})
+}
+
+//FingerChange - Finger placed on (Down) or lifted from the sensor
+type FingerChange struct {
+	Down bool
+	Time time.Time
+}
+
+//WatchFinger - Streams finger changes until ctx is done, the channel is closed when the stream ends
+func (c *Client) WatchFinger(ctx context.Context, interval time.Duration) (<-chan FingerChange, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], "/"+serviceName+"/WatchFinger", callOption())
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if err = stream.SendMsg(&WatchRequest{PollIntervalMs: uint(interval / time.Millisecond)}); err != nil {
+		return nil, fromStatus(err)
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, fromStatus(err)
+	}
+
+	changes := make(chan FingerChange)
+	go func() {
+		defer close(changes)
+		for {
+			event := &FingerEvent{}
+			if err := stream.RecvMsg(event); err != nil {
+				return
+			}
+			select {
+			case changes <- FingerChange{Down: event.Down, Time: time.Unix(0, event.UnixNano)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+//Enroll - Runs the enrollment on the server, progress is optional and receives every step as it starts
+func (c *Client) Enroll(ctx context.Context, position int, progress func(fingerprint.EnrollStep)) (int, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[1], "/"+serviceName+"/Enroll", callOption())
+	if err != nil {
+		return -1, fromStatus(err)
+	}
+	if err = stream.SendMsg(&EnrollRequest{Position: position}); err != nil {
+		return -1, fromStatus(err)
+	}
+	if err = stream.CloseSend(); err != nil {
+		return -1, fromStatus(err)
+	}
+
+	for {
+		update := &EnrollProgress{}
+		err = stream.RecvMsg(update)
+		if err == io.EOF {
+			return -1, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return -1, fromStatus(err)
+		}
+		if progress != nil {
+			progress(fingerprint.EnrollStep(update.Step))
+		}
+		if fingerprint.EnrollStep(update.Step) == fingerprint.EnrollDone {
+			return update.Position, nil
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package remote
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+//codec - Encodes the messages of this package as protobuf, anything else goes to the registered proto codec
+//so the option can be shared with generated services on the same grpc.Server.
+type codec struct{}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(message); ok {
+		return m.marshal(), nil
+	}
+	if fallback := encoding.GetCodec("proto"); fallback != nil {
+		return fallback.Marshal(v)
+	}
+	return nil, fmt.Errorf("remote: unable to marshal %T", v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(message); ok {
+		return m.unmarshal(data)
+	}
+	if fallback := encoding.GetCodec("proto"); fallback != nil {
+		return fallback.Unmarshal(data, v)
+	}
+	return fmt.Errorf("remote: unable to unmarshal %T", v)
+}
+
+//ServerOption - Has to be passed to grpc.NewServer before the scanner service is registered
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+func callOption() grpc.CallOption {
+	return grpc.ForceCodec(codec{})
+}
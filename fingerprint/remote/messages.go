@@ -0,0 +1,722 @@
+package remote
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+//message - Hand written protobuf messages of scanner.proto
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+var errMalformed = errors.New("malformed message")
+
+//appendInt - int32 fields, negative values are sign extended as protobuf does
+func appendInt(b []byte, num protowire.Number, v int) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(int64(int32(v))))
+}
+
+func appendUint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func toInt(v uint64) int {
+	return int(int32(v))
+}
+
+//decodeFields - Walks the wire format handing varint and bytes fields to set, other fields are skipped
+func decodeFields(b []byte, set func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			if err := set(num, typ, v, nil); err != nil {
+				return err
+			}
+		case protowire.BytesType:
+			bs, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			if err := set(num, typ, 0, bs); err != nil {
+				return err
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return errMalformed
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+//Empty -
+type Empty struct{}
+
+func (m *Empty) marshal() []byte { return nil }
+
+func (m *Empty) unmarshal(b []byte) error {
+	return decodeFields(b, func(protowire.Number, protowire.Type, uint64, []byte) error { return nil })
+}
+
+//BoolValue -
+type BoolValue struct {
+	Value bool
+}
+
+func (m *BoolValue) marshal() []byte {
+	return appendBool(nil, 1, m.Value)
+}
+
+func (m *BoolValue) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Value = v != 0
+		}
+		return nil
+	})
+}
+
+//SystemParameters -
+type SystemParameters struct {
+	StatusRegister  uint
+	SystemID        uint
+	StorageCapacity uint
+	SecurityLevel   uint
+	DeviceAddress   uint
+	PacketLength    uint
+	BaudRate        uint
+}
+
+func (m *SystemParameters) marshal() []byte {
+	var b []byte
+	b = appendUint(b, 1, uint64(m.StatusRegister))
+	b = appendUint(b, 2, uint64(m.SystemID))
+	b = appendUint(b, 3, uint64(m.StorageCapacity))
+	b = appendUint(b, 4, uint64(m.SecurityLevel))
+	b = appendUint(b, 5, uint64(m.DeviceAddress))
+	b = appendUint(b, 6, uint64(m.PacketLength))
+	b = appendUint(b, 7, uint64(m.BaudRate))
+	return b
+}
+
+func (m *SystemParameters) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.StatusRegister = uint(uint32(v))
+		case 2:
+			m.SystemID = uint(uint32(v))
+		case 3:
+			m.StorageCapacity = uint(uint32(v))
+		case 4:
+			m.SecurityLevel = uint(uint32(v))
+		case 5:
+			m.DeviceAddress = uint(uint32(v))
+		case 6:
+			m.PacketLength = uint(uint32(v))
+		case 7:
+			m.BaudRate = uint(uint32(v))
+		}
+		return nil
+	})
+}
+
+//DeleteRequest -
+type DeleteRequest struct {
+	Position int
+	Count    int
+}
+
+func (m *DeleteRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.Position)
+	return appendInt(b, 2, m.Count)
+}
+
+func (m *DeleteRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Position = toInt(v)
+		case 2:
+			m.Count = toInt(v)
+		}
+		return nil
+	})
+}
+
+//CharBuffer -
+type CharBuffer struct {
+	CharBuffer int
+}
+
+func (m *CharBuffer) marshal() []byte {
+	return appendInt(nil, 1, m.CharBuffer)
+}
+
+func (m *CharBuffer) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.CharBuffer = toInt(v)
+		}
+		return nil
+	})
+}
+
+//SearchRequest -
+type SearchRequest struct {
+	CharBuffer    int
+	StartPosition int
+	Count         int
+}
+
+func (m *SearchRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.CharBuffer)
+	b = appendInt(b, 2, m.StartPosition)
+	return appendInt(b, 3, m.Count)
+}
+
+func (m *SearchRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.CharBuffer = toInt(v)
+		case 2:
+			m.StartPosition = toInt(v)
+		case 3:
+			m.Count = toInt(v)
+		}
+		return nil
+	})
+}
+
+//SearchGroupRequest -
+type SearchGroupRequest struct {
+	CharBuffer int
+	Name       string
+}
+
+func (m *SearchGroupRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.CharBuffer)
+	return appendString(b, 2, m.Name)
+}
+
+func (m *SearchGroupRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.CharBuffer = toInt(v)
+		case 2:
+			m.Name = string(bs)
+		}
+		return nil
+	})
+}
+
+//SlotRange - Request with the name only, the reply complete
+type SlotRange struct {
+	Name  string
+	First int
+	Last  int
+}
+
+func (m *SlotRange) marshal() []byte {
+	b := appendString(nil, 1, m.Name)
+	b = appendInt(b, 2, m.First)
+	return appendInt(b, 3, m.Last)
+}
+
+func (m *SlotRange) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Name = string(bs)
+		case 2:
+			m.First = toInt(v)
+		case 3:
+			m.Last = toInt(v)
+		}
+		return nil
+	})
+}
+
+//SearchResult -
+type SearchResult struct {
+	Position      int
+	AccuracyScore int
+}
+
+func (m *SearchResult) marshal() []byte {
+	b := appendInt(nil, 1, m.Position)
+	return appendInt(b, 2, m.AccuracyScore)
+}
+
+func (m *SearchResult) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Position = toInt(v)
+		case 2:
+			m.AccuracyScore = toInt(v)
+		}
+		return nil
+	})
+}
+
+//TemplateRequest -
+type TemplateRequest struct {
+	Position   int
+	CharBuffer int
+}
+
+func (m *TemplateRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.Position)
+	return appendInt(b, 2, m.CharBuffer)
+}
+
+func (m *TemplateRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Position = toInt(v)
+		case 2:
+			m.CharBuffer = toInt(v)
+		}
+		return nil
+	})
+}
+
+//Position -
+type Position struct {
+	Position int
+}
+
+func (m *Position) marshal() []byte {
+	return appendInt(nil, 1, m.Position)
+}
+
+func (m *Position) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Position = toInt(v)
+		}
+		return nil
+	})
+}
+
+//Score -
+type Score struct {
+	Score int
+}
+
+func (m *Score) marshal() []byte {
+	return appendInt(nil, 1, m.Score)
+}
+
+func (m *Score) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Score = toInt(v)
+		}
+		return nil
+	})
+}
+
+//ProductInfo -
+type ProductInfo struct {
+	Model           string
+	BatchNumber     string
+	SerialNumber    string
+	HardwareVersion string
+	SensorType      string
+	ImageWidth      int
+	ImageHeight     int
+	TemplateSize    int
+	DatabaseSize    int
+}
+
+func (m *ProductInfo) marshal() []byte {
+	b := appendString(nil, 1, m.Model)
+	b = appendString(b, 2, m.BatchNumber)
+	b = appendString(b, 3, m.SerialNumber)
+	b = appendString(b, 4, m.HardwareVersion)
+	b = appendString(b, 5, m.SensorType)
+	b = appendInt(b, 6, m.ImageWidth)
+	b = appendInt(b, 7, m.ImageHeight)
+	b = appendInt(b, 8, m.TemplateSize)
+	return appendInt(b, 9, m.DatabaseSize)
+}
+
+func (m *ProductInfo) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Model = string(bs)
+		case 2:
+			m.BatchNumber = string(bs)
+		case 3:
+			m.SerialNumber = string(bs)
+		case 4:
+			m.HardwareVersion = string(bs)
+		case 5:
+			m.SensorType = string(bs)
+		case 6:
+			m.ImageWidth = toInt(v)
+		case 7:
+			m.ImageHeight = toInt(v)
+		case 8:
+			m.TemplateSize = toInt(v)
+		case 9:
+			m.DatabaseSize = toInt(v)
+		}
+		return nil
+	})
+}
+
+//PasswordRequest -
+type PasswordRequest struct {
+	Password uint
+}
+
+func (m *PasswordRequest) marshal() []byte {
+	return appendUint(nil, 1, uint64(m.Password))
+}
+
+func (m *PasswordRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Password = uint(uint32(v))
+		}
+		return nil
+	})
+}
+
+//ChangePasswordRequest -
+type ChangePasswordRequest struct {
+	OldPassword uint
+	NewPassword uint
+}
+
+func (m *ChangePasswordRequest) marshal() []byte {
+	b := appendUint(nil, 1, uint64(m.OldPassword))
+	return appendUint(b, 2, uint64(m.NewPassword))
+}
+
+func (m *ChangePasswordRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.OldPassword = uint(uint32(v))
+		case 2:
+			m.NewPassword = uint(uint32(v))
+		}
+		return nil
+	})
+}
+
+//RandomNumber -
+type RandomNumber struct {
+	Value uint32
+}
+
+func (m *RandomNumber) marshal() []byte {
+	return appendUint(nil, 1, uint64(m.Value))
+}
+
+func (m *RandomNumber) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Value = uint32(v)
+		}
+		return nil
+	})
+}
+
+//TemplateIndex - Used is sent packed, unpacked input is accepted as well
+type TemplateIndex struct {
+	Used []bool
+}
+
+func (m *TemplateIndex) marshal() []byte {
+	if len(m.Used) == 0 {
+		return nil
+	}
+	packed := make([]byte, 0, len(m.Used))
+	for _, used := range m.Used {
+		if used {
+			packed = append(packed, 1)
+		} else {
+			packed = append(packed, 0)
+		}
+	}
+	return appendBytes(nil, 1, packed)
+}
+
+func (m *TemplateIndex) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num != 1 {
+			return nil
+		}
+		if typ == protowire.VarintType {
+			m.Used = append(m.Used, v != 0)
+			return nil
+		}
+		for len(bs) > 0 {
+			v, n := protowire.ConsumeVarint(bs)
+			if n < 0 {
+				return errMalformed
+			}
+			bs = bs[n:]
+			m.Used = append(m.Used, v != 0)
+		}
+		return nil
+	})
+}
+
+//Data -
+type Data struct {
+	Data []byte
+}
+
+func (m *Data) marshal() []byte {
+	return appendBytes(nil, 1, m.Data)
+}
+
+func (m *Data) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Data = append([]byte(nil), bs...)
+		}
+		return nil
+	})
+}
+
+//UploadRequest -
+type UploadRequest struct {
+	CharBuffer int
+	Data       []byte
+}
+
+func (m *UploadRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.CharBuffer)
+	return appendBytes(b, 2, m.Data)
+}
+
+func (m *UploadRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.CharBuffer = toInt(v)
+		case 2:
+			m.Data = append([]byte(nil), bs...)
+		}
+		return nil
+	})
+}
+
+//NotepadRequest - Data is only set on writes
+type NotepadRequest struct {
+	Page int
+	Data []byte
+}
+
+func (m *NotepadRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.Page)
+	return appendBytes(b, 2, m.Data)
+}
+
+func (m *NotepadRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Page = toInt(v)
+		case 2:
+			m.Data = append([]byte(nil), bs...)
+		}
+		return nil
+	})
+}
+
+//SystemParameterRequest -
+type SystemParameterRequest struct {
+	Parameter int
+	Content   int
+}
+
+func (m *SystemParameterRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.Parameter)
+	return appendInt(b, 2, m.Content)
+}
+
+func (m *SystemParameterRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Parameter = toInt(v)
+		case 2:
+			m.Content = toInt(v)
+		}
+		return nil
+	})
+}
+
+//LEDRequest -
+type LEDRequest struct {
+	Color int
+	Mode  int
+	Speed int
+	Count int
+}
+
+func (m *LEDRequest) marshal() []byte {
+	b := appendInt(nil, 1, m.Color)
+	b = appendInt(b, 2, m.Mode)
+	b = appendInt(b, 3, m.Speed)
+	return appendInt(b, 4, m.Count)
+}
+
+func (m *LEDRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Color = toInt(v)
+		case 2:
+			m.Mode = toInt(v)
+		case 3:
+			m.Speed = toInt(v)
+		case 4:
+			m.Count = toInt(v)
+		}
+		return nil
+	})
+}
+
+//WatchRequest -
+type WatchRequest struct {
+	PollIntervalMs uint
+}
+
+func (m *WatchRequest) marshal() []byte {
+	return appendUint(nil, 1, uint64(m.PollIntervalMs))
+}
+
+func (m *WatchRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.PollIntervalMs = uint(uint32(v))
+		}
+		return nil
+	})
+}
+
+//FingerEvent -
+type FingerEvent struct {
+	Down     bool
+	UnixNano int64
+}
+
+func (m *FingerEvent) marshal() []byte {
+	b := appendBool(nil, 1, m.Down)
+	return appendUint(b, 2, uint64(m.UnixNano))
+}
+
+func (m *FingerEvent) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Down = v != 0
+		case 2:
+			m.UnixNano = int64(v)
+		}
+		return nil
+	})
+}
+
+//EnrollRequest -
+type EnrollRequest struct {
+	Position int
+}
+
+func (m *EnrollRequest) marshal() []byte {
+	return appendInt(nil, 1, m.Position)
+}
+
+func (m *EnrollRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		if num == 1 {
+			m.Position = toInt(v)
+		}
+		return nil
+	})
+}
+
+//EnrollProgress - Step carries the fingerprint.EnrollStep value
+type EnrollProgress struct {
+	Step     int
+	Position int
+}
+
+func (m *EnrollProgress) marshal() []byte {
+	b := appendInt(nil, 1, m.Step)
+	return appendInt(b, 2, m.Position)
+}
+
+func (m *EnrollProgress) unmarshal(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			m.Step = toInt(v)
+		case 2:
+			m.Position = toInt(v)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,387 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//fakeScanner - ScannerIO with a library of 8 positions, a finger that alternates between placed and lifted on
+//every ReadImage and searches answered with match
+type fakeScanner struct {
+	index    []bool
+	reads    int
+	match    int
+	pad      map[int][]byte
+	image    []byte
+	slots    *fingerprint.Allocator
+	released []int
+}
+
+func newFakeScanner() *fakeScanner {
+	return &fakeScanner{index: make([]bool, 8), match: -1, pad: make(map[int][]byte), slots: fingerprint.NewAllocator(nil)}
+}
+
+func (f *fakeScanner) Capture() error        { return nil }
+func (f *fakeScanner) Release()              {}
+func (f *fakeScanner) VerifyPassword() bool  { return true }
+func (f *fakeScanner) ConvertImage(int) bool { return true }
+func (f *fakeScanner) CreateTemplate() error { return nil }
+func (f *fakeScanner) ClearDatabase() error  { return nil }
+func (f *fakeScanner) Handshake() error      { return nil }
+func (f *fakeScanner) CheckSensor() error    { return fingerprint.ErrSensorAbnormal }
+
+func (f *fakeScanner) GetSystemParameters() (*fingerprint.SystemParameters, error) {
+	return &fingerprint.SystemParameters{StorageCapacity: uint(len(f.index)), SecurityLevel: 3, DeviceAddress: 0xFFFFFFFF}, nil
+}
+
+func (f *fakeScanner) ReadImage() bool {
+	f.reads++
+	return f.reads%2 == 1
+}
+
+func (f *fakeScanner) DeleteFingerprint(position int, count int) (bool, error) {
+	for i := position; i < position+count; i++ {
+		f.index[i] = false
+	}
+	return true, nil
+}
+
+func (f *fakeScanner) SearchTemplate(int, int, int) (*fingerprint.SearchResult, error) {
+	if f.match < 0 {
+		return &fingerprint.SearchResult{PositionNumber: -1, AccuracyScore: -1}, nil
+	}
+	return &fingerprint.SearchResult{PositionNumber: f.match, AccuracyScore: 120}, nil
+}
+
+func (f *fakeScanner) FastSearchTemplate(int, int, int) (*fingerprint.SearchResult, error) {
+	return nil, fingerprint.ErrNotSupported
+}
+
+func (f *fakeScanner) SearchGroup(charBufferNo int, name string) (*fingerprint.SearchResult, error) {
+	r, err := f.SlotRange(name)
+	if err != nil {
+		return nil, err
+	}
+	return &fingerprint.SearchResult{PositionNumber: r.First, AccuracyScore: 50}, nil
+}
+
+func (f *fakeScanner) SlotRange(name string) (fingerprint.SlotRange, error) {
+	if name == "staff" {
+		return fingerprint.SlotRange{Name: "staff", First: 2, Last: 5}, nil
+	}
+	return fingerprint.SlotRange{}, fmt.Errorf("%w: %s", fingerprint.ErrUnknownGroup, name)
+}
+
+func (f *fakeScanner) ReserveSlot(group string) (int, error) {
+	r, err := f.SlotRange(group)
+	if err != nil {
+		return -1, err
+	}
+	return f.slots.Allocate(f.index, r.First, r.Last, true)
+}
+
+func (f *fakeScanner) ReserveSlotAt(position int) error { return f.slots.Reserve(position) }
+
+func (f *fakeScanner) ReleaseSlot(position int) {
+	f.released = append(f.released, position)
+	f.slots.Release(position)
+}
+
+func (f *fakeScanner) StoreTemplate(position int, charBufferNo int) (int, error) {
+	if position < 0 {
+		position = 3
+	}
+	f.index[position] = true
+	return position, nil
+}
+
+func (f *fakeScanner) CompareCharacteristics() (int, error)        { return 77, nil }
+func (f *fakeScanner) LoadTemplate(int, int) error                 { return nil }
+func (f *fakeScanner) TemplateIndex() ([]bool, error)              { return f.index, nil }
+func (f *fakeScanner) DownloadCharacteristics(int) ([]byte, error) { return []byte{1, 2, 3}, nil }
+func (f *fakeScanner) UploadCharacteristics(int, []byte) error     { return nil }
+func (f *fakeScanner) DownloadImage() ([]byte, error)              { return []byte{9, 9}, nil }
+func (f *fakeScanner) SetSystemParameter(int, int) error           { return nil }
+func (f *fakeScanner) GenerateRandomNumber() (uint32, error)       { return 0xDEADBEEF, nil }
+func (f *fakeScanner) SetPassword(uint) error                      { return nil }
+func (f *fakeScanner) SetLED(fingerprint.LEDColor, fingerprint.LEDMode, int, int) error {
+	return fingerprint.ErrNotSupported
+}
+
+func (f *fakeScanner) UploadImage(data []byte) error {
+	f.image = data
+	return nil
+}
+
+func (f *fakeScanner) ReadNotepad(page int) ([]byte, error) {
+	if data, ok := f.pad[page]; ok {
+		return data, nil
+	}
+	return make([]byte, 32), nil
+}
+
+func (f *fakeScanner) WriteNotepad(page int, data []byte) error {
+	f.pad[page] = append(data, make([]byte, 32-len(data))...)
+	return nil
+}
+
+func (f *fakeScanner) ProductInfo() (*fingerprint.ProductInfo, error) {
+	return &fingerprint.ProductInfo{Model: "R503", SerialNumber: "00001234", HardwareVersion: "1.2", ImageWidth: 192, ImageHeight: 192}, nil
+}
+
+func (f *fakeScanner) ChangePassword(oldPassword uint, newPassword uint) error {
+	if oldPassword != 0 {
+		return fingerprint.ErrWrongPassword
+	}
+	return nil
+}
+
+//dialFake - Client connected over an in-memory listener to a server for f
+func dialFake(t *testing.T, f *fakeScanner) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer(ServerOption())
+	NewServer(f).Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	dialer := func(ctx context.Context, target string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}
+	c, err := Dial("passthrough:///bufnet", grpc.WithContextDialer(dialer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Release)
+	return c
+}
+
+var _ fingerprint.ScannerIO = (*Client)(nil)
+
+func TestClientUnary(t *testing.T) {
+	f := newFakeScanner()
+	c := dialFake(t, f)
+
+	if err := c.Capture(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.VerifyPassword() {
+		t.Error("VerifyPassword = false")
+	}
+	p, err := c.GetSystemParameters()
+	if err != nil || p.StorageCapacity != 8 || p.SecurityLevel != 3 || p.DeviceAddress != 0xFFFFFFFF {
+		t.Errorf("GetSystemParameters = %+v, %v", p, err)
+	}
+	if r, err := c.SearchTemplate(fingerprint.FINGERPRINT_CHARBUFFER1, 0, -1); err != nil || r.PositionNumber != -1 {
+		t.Errorf("SearchTemplate = %+v, %v", r, err)
+	}
+	if position, err := c.StoreTemplate(-1, fingerprint.FINGERPRINT_CHARBUFFER1); err != nil || position != 3 {
+		t.Errorf("StoreTemplate = %d, %v", position, err)
+	}
+	if index, err := c.TemplateIndex(); err != nil || !reflect.DeepEqual(index, f.index) {
+		t.Errorf("TemplateIndex = %v, %v", index, err)
+	}
+	if data, err := fingerprint.ExportTemplate(c, 3); err != nil || !reflect.DeepEqual(data, []byte{1, 2, 3}) {
+		t.Errorf("ExportTemplate = %v, %v", data, err)
+	}
+	if score, err := fingerprint.Verify(context.Background(), c, 3); err != nil || score != 77 {
+		t.Errorf("Verify = %d, %v", score, err)
+	}
+	if ok, err := c.DeleteFingerprint(3, 1); err != nil || !ok || f.index[3] {
+		t.Errorf("DeleteFingerprint = %v, %v", ok, err)
+	}
+	if err := c.WriteNotepad(2, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := c.ReadNotepad(2); err != nil || len(data) != 32 || string(data[:5]) != "hello" {
+		t.Errorf("ReadNotepad = %q, %v", data, err)
+	}
+	if v, err := c.GenerateRandomNumber(); err != nil || v != 0xDEADBEEF {
+		t.Errorf("GenerateRandomNumber = %x, %v", v, err)
+	}
+	if p, err := c.ProductInfo(); err != nil || p.Model != "R503" || p.ImageHeight != 192 {
+		t.Errorf("ProductInfo = %+v, %v", p, err)
+	}
+	if err := c.UploadImage([]byte{1, 2, 3}); err != nil || !reflect.DeepEqual(f.image, []byte{1, 2, 3}) {
+		t.Errorf("UploadImage = %v, image %v", err, f.image)
+	}
+	if r, err := c.SearchGroup(1, "staff"); err != nil || r.PositionNumber != 2 {
+		t.Errorf("SearchGroup = %+v, %v", r, err)
+	}
+	if r, err := c.SlotRange("staff"); err != nil || r.First != 2 || r.Last != 5 {
+		t.Errorf("SlotRange = %+v, %v", r, err)
+	}
+}
+
+func TestClientSlots(t *testing.T) {
+	f := newFakeScanner()
+	c := dialFake(t, f)
+
+	for _, want := range []int{2, 3} {
+		if position, err := c.ReserveSlot("staff"); err != nil || position != want {
+			t.Fatalf("ReserveSlot = %d, %v, want %d", position, err, want)
+		}
+	}
+	c.ReleaseSlot(2)
+	if !reflect.DeepEqual(f.released, []int{2}) {
+		t.Errorf("released %v", f.released)
+	}
+	if err := c.ReserveSlotAt(2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 4; i <= 5; i++ {
+		if err := c.ReserveSlotAt(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.ReserveSlot("staff"); err != fingerprint.ErrLibraryFull {
+		t.Errorf("ReserveSlot on a full group = %v", err)
+	}
+}
+
+func TestWatchFinger(t *testing.T) {
+	c := dialFake(t, newFakeScanner())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := c.WatchFinger(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false, true} {
+		select {
+		case change := <-changes:
+			if change.Down != want || change.Time.IsZero() {
+				t.Fatalf("change %d = %+v, want down %v", i, change, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change %d", i)
+		}
+	}
+
+	cancel()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("channel not closed after cancel")
+		}
+	}
+}
+
+func TestEnroll(t *testing.T) {
+	f := newFakeScanner()
+	c := dialFake(t, f)
+
+	var steps []fingerprint.EnrollStep
+	position, err := c.Enroll(context.Background(), -1, func(step fingerprint.EnrollStep) {
+		steps = append(steps, step)
+	})
+	if err != nil || position != 3 || !f.index[3] {
+		t.Fatalf("Enroll = %d, %v", position, err)
+	}
+	want := []fingerprint.EnrollStep{
+		fingerprint.EnrollWaitFirstFinger, fingerprint.EnrollCheckDuplicate, fingerprint.EnrollRemoveFinger,
+		fingerprint.EnrollWaitSecondFinger, fingerprint.EnrollCompare, fingerprint.EnrollStore, fingerprint.EnrollDone,
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps %v, want %v", steps, want)
+	}
+
+	f.match = 3
+	if _, err = c.Enroll(context.Background(), -1, nil); err != fingerprint.ErrAlreadyEnrolled {
+		t.Errorf("Enroll of a stored finger = %v", err)
+	}
+}
+
+func TestErrorsOverTheWire(t *testing.T) {
+	c := dialFake(t, newFakeScanner())
+
+	if err := c.CheckSensor(); err != fingerprint.ErrSensorAbnormal {
+		t.Errorf("CheckSensor = %v", err)
+	}
+	if err := c.ChangePassword(1, 2); err != fingerprint.ErrWrongPassword {
+		t.Errorf("ChangePassword = %v", err)
+	}
+	if err := c.SetLED(fingerprint.LEDGreen, fingerprint.LEDOn, 0, 0); err != fingerprint.ErrNotSupported {
+		t.Errorf("SetLED = %v", err)
+	}
+	if _, err := c.FastSearchTemplate(1, 0, 10); err != fingerprint.ErrNotSupported {
+		t.Errorf("FastSearchTemplate = %v", err)
+	}
+	_, err := c.SearchGroup(1, "nobody")
+	if !errors.Is(err, fingerprint.ErrUnknownGroup) || err.Error() != "unknown slot group: nobody" {
+		t.Errorf("SearchGroup = %v", err)
+	}
+	if err = c.ReserveSlotAt(2); err != nil {
+		t.Fatal(err)
+	}
+	err = c.ReserveSlotAt(2)
+	if !errors.Is(err, fingerprint.ErrSlotReserved) || err.Error() != "position is reserved: 2" {
+		t.Errorf("ReserveSlotAt = %v", err)
+	}
+}
+
+func TestStatusMapping(t *testing.T) {
+	sentinels := []error{
+		fingerprint.ErrNoMatch,
+		fingerprint.ErrAlreadyEnrolled,
+		fingerprint.ErrNotSupported,
+		fingerprint.ErrSensorAbnormal,
+		fingerprint.ErrWrongPassword,
+		fingerprint.ErrLibraryFull,
+		context.DeadlineExceeded,
+		context.Canceled,
+	}
+	for _, err := range sentinels {
+		if got := fromStatus(toStatus(err)); got != err {
+			t.Errorf("%v came back as %v", err, got)
+		}
+	}
+
+	wrapped := []error{
+		fmt.Errorf("%w: night", fingerprint.ErrUnknownGroup),
+		fmt.Errorf("%w: 7", fingerprint.ErrSlotReserved),
+		fmt.Errorf("%w: 9", fingerprint.ErrSlotOccupied),
+	}
+	for _, err := range wrapped {
+		got := fromStatus(toStatus(err))
+		if !errors.Is(got, errors.Unwrap(err)) || got.Error() != err.Error() {
+			t.Errorf("%v came back as %v", err, got)
+		}
+	}
+
+	if toStatus(nil) != nil || fromStatus(nil) != nil {
+		t.Error("nil is not kept")
+	}
+	other := errors.New("Unable to capture")
+	if st := status.Convert(toStatus(other)); st.Code() != codes.Unknown || st.Message() != other.Error() {
+		t.Errorf("toStatus(%v) = %v", other, st)
+	}
+	if got := fromStatus(toStatus(other)); got.Error() != other.Error() {
+		t.Errorf("%v came back as %v", other, got)
+	}
+	//Codes shared with gRPC itself stay plain errors unless the message is ours
+	oversized := status.Error(codes.ResourceExhausted, "message larger than max")
+	if got := fromStatus(oversized); got == fingerprint.ErrLibraryFull {
+		t.Errorf("%v taken for ErrLibraryFull", oversized)
+	}
+	if got := fromStatus(status.Error(codes.Aborted, "stream reset")); errors.Is(got, fingerprint.ErrSlotReserved) {
+		t.Errorf("plain abort taken for ErrSlotReserved")
+	}
+}
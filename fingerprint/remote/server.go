@@ -0,0 +1,394 @@
+//Package remote gives access to a fingerprint scanner over gRPC, the service is described in scanner.proto
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "fingerprint.remote.v1.Scanner"
+
+//DefaultPollInterval - How often WatchFinger reads the sensor when the caller does not ask for an interval
+const DefaultPollInterval = 200 * time.Millisecond
+
+//Server - Serves a captured scanner, calls are serialised as the sensor handles one command at a time
+type Server struct {
+	mu      sync.Mutex
+	scanner fingerprint.ScannerIO
+}
+
+//NewServer - Wrap a captured scanner
+func NewServer(s fingerprint.ScannerIO) *Server {
+	return &Server{scanner: s}
+}
+
+//Register - Add the scanner service to gs, which has to be created with ServerOption
+func (srv *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, srv)
+}
+
+//call - Runs fn while holding the scanner
+func (srv *Server) call(fn func(s fingerprint.ScannerIO) error) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return toStatus(fn(srv.scanner))
+}
+
+//toStatus - Sentinel errors of the fingerprint package get their own codes so the client can restore them
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == fingerprint.ErrNoMatch:
+		return status.Error(codes.NotFound, err.Error())
+	case err == fingerprint.ErrAlreadyEnrolled:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case err == fingerprint.ErrNotSupported:
+		return status.Error(codes.Unimplemented, err.Error())
+	case err == fingerprint.ErrSensorAbnormal:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case err == fingerprint.ErrWrongPassword:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, fingerprint.ErrUnknownGroup):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, fingerprint.ErrSlotReserved), errors.Is(err, fingerprint.ErrSlotOccupied):
+		return status.Error(codes.Aborted, err.Error())
+	case err == fingerprint.ErrLibraryFull:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case err == context.DeadlineExceeded || err == context.Canceled:
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+//fromStatus - Reverse of toStatus
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return fingerprint.ErrNoMatch
+	case codes.AlreadyExists:
+		return fingerprint.ErrAlreadyEnrolled
+	case codes.Unimplemented:
+		//Also returned by servers older than the method
+		return fingerprint.ErrNotSupported
+	case codes.FailedPrecondition:
+		return fingerprint.ErrSensorAbnormal
+	case codes.PermissionDenied:
+		return fingerprint.ErrWrongPassword
+	case codes.Aborted:
+		for _, sentinel := range []error{fingerprint.ErrSlotReserved, fingerprint.ErrSlotOccupied} {
+			if position := strings.TrimPrefix(st.Message(), sentinel.Error()+": "); position != st.Message() {
+				return fmt.Errorf("%w: %s", sentinel, position)
+			}
+		}
+	case codes.ResourceExhausted:
+		//Also used by gRPC for oversized messages
+		if st.Message() == fingerprint.ErrLibraryFull.Error() {
+			return fingerprint.ErrLibraryFull
+		}
+	case codes.InvalidArgument:
+		if name := strings.TrimPrefix(st.Message(), fingerprint.ErrUnknownGroup.Error()+": "); name != st.Message() {
+			return fmt.Errorf("%w: %s", fingerprint.ErrUnknownGroup, name)
+		}
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	case codes.Canceled:
+		return context.Canceled
+	}
+	return errors.New(st.Message())
+}
+
+func (srv *Server) watchFinger(req *WatchRequest, stream grpc.ServerStream) error {
+	interval := time.Duration(req.PollIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	down := false
+	for {
+		var current bool
+		srv.call(func(s fingerprint.ScannerIO) error {
+			current = s.ReadImage()
+			return nil
+		})
+		if current != down {
+			down = current
+			event := &FingerEvent{Down: down, UnixNano: time.Now().UnixNano()}
+			if err := stream.SendMsg(event); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (srv *Server) enroll(req *EnrollRequest, stream grpc.ServerStream) error {
+	var sendErr error
+	progress := func(step fingerprint.EnrollStep) {
+		if step == fingerprint.EnrollDone || sendErr != nil {
+			return
+		}
+		sendErr = stream.SendMsg(&EnrollProgress{Step: int(step), Position: req.Position})
+	}
+
+	var position int
+	err := srv.call(func(s fingerprint.ScannerIO) (err error) {
+		position, err = fingerprint.Enroll(stream.Context(), s, req.Position, progress)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	return stream.SendMsg(&EnrollProgress{Step: int(fingerprint.EnrollDone), Position: position})
+}
+
+//unary - Method descriptor decoding newReq and running fn with the scanner held
+func unary(name string, newReq func() message, fn func(s fingerprint.ScannerIO, req message) (message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				var reply message
+				err := srv.(*Server).call(func(s fingerprint.ScannerIO) (err error) {
+					reply, err = fn(s, req.(message))
+					return err
+				})
+				if err != nil {
+					return nil, err
+				}
+				return reply, nil
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+func newEmpty() message           { return &Empty{} }
+func newCharBuffer() message      { return &CharBuffer{} }
+func newTemplateRequest() message { return &TemplateRequest{} }
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Capture", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			//The server owns the port, a remote capture only checks the sensor answers
+			_, err := s.GetSystemParameters()
+			return &Empty{}, err
+		}),
+		unary("VerifyPassword", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &BoolValue{Value: s.VerifyPassword()}, nil
+		}),
+		unary("GetSystemParameters", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			p, err := s.GetSystemParameters()
+			if err != nil {
+				return nil, err
+			}
+			return &SystemParameters{
+				StatusRegister:  p.StatusRegister,
+				SystemID:        p.SystemID,
+				StorageCapacity: p.StorageCapacity,
+				SecurityLevel:   p.SecurityLevel,
+				DeviceAddress:   p.DeviceAddress,
+				PacketLength:    p.PacketLength,
+				BaudRate:        p.BaudRate,
+			}, nil
+		}),
+		unary("ReadImage", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &BoolValue{Value: s.ReadImage()}, nil
+		}),
+		unary("DeleteFingerprint", func() message { return &DeleteRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*DeleteRequest)
+			ok, err := s.DeleteFingerprint(r.Position, r.Count)
+			return &BoolValue{Value: ok}, err
+		}),
+		unary("ConvertImage", newCharBuffer, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &BoolValue{Value: s.ConvertImage(req.(*CharBuffer).CharBuffer)}, nil
+		}),
+		unary("SearchTemplate", func() message { return &SearchRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*SearchRequest)
+			result, err := s.SearchTemplate(r.CharBuffer, r.StartPosition, r.Count)
+			if err != nil {
+				return nil, err
+			}
+			return &SearchResult{Position: result.PositionNumber, AccuracyScore: result.AccuracyScore}, nil
+		}),
+		unary("FastSearchTemplate", func() message { return &SearchRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*SearchRequest)
+			result, err := s.FastSearchTemplate(r.CharBuffer, r.StartPosition, r.Count)
+			if err != nil {
+				return nil, err
+			}
+			return &SearchResult{Position: result.PositionNumber, AccuracyScore: result.AccuracyScore}, nil
+		}),
+		unary("SlotRange", func() message { return &SlotRange{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r, err := s.SlotRange(req.(*SlotRange).Name)
+			return &SlotRange{Name: r.Name, First: r.First, Last: r.Last}, err
+		}),
+		unary("ReserveSlot", func() message { return &SlotRange{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			position, err := s.ReserveSlot(req.(*SlotRange).Name)
+			return &Position{Position: position}, err
+		}),
+		unary("ReserveSlotAt", func() message { return &Position{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.ReserveSlotAt(req.(*Position).Position)
+		}),
+		unary("ReleaseSlot", func() message { return &Position{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			s.ReleaseSlot(req.(*Position).Position)
+			return &Empty{}, nil
+		}),
+		unary("SearchGroup", func() message { return &SearchGroupRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*SearchGroupRequest)
+			result, err := s.SearchGroup(r.CharBuffer, r.Name)
+			if err != nil {
+				return nil, err
+			}
+			return &SearchResult{Position: result.PositionNumber, AccuracyScore: result.AccuracyScore}, nil
+		}),
+		unary("CreateTemplate", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.CreateTemplate()
+		}),
+		unary("StoreTemplate", newTemplateRequest, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*TemplateRequest)
+			position, err := s.StoreTemplate(r.Position, r.CharBuffer)
+			return &Position{Position: position}, err
+		}),
+		unary("ClearDatabase", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.ClearDatabase()
+		}),
+		unary("CompareCharacteristics", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			score, err := s.CompareCharacteristics()
+			return &Score{Score: score}, err
+		}),
+		unary("LoadTemplate", newTemplateRequest, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*TemplateRequest)
+			return &Empty{}, s.LoadTemplate(r.Position, r.CharBuffer)
+		}),
+		unary("TemplateIndex", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			index, err := s.TemplateIndex()
+			return &TemplateIndex{Used: index}, err
+		}),
+		unary("DownloadCharacteristics", newCharBuffer, func(s fingerprint.ScannerIO, req message) (message, error) {
+			data, err := s.DownloadCharacteristics(req.(*CharBuffer).CharBuffer)
+			return &Data{Data: data}, err
+		}),
+		unary("UploadCharacteristics", func() message { return &UploadRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*UploadRequest)
+			return &Empty{}, s.UploadCharacteristics(r.CharBuffer, r.Data)
+		}),
+		unary("DownloadImage", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			data, err := s.DownloadImage()
+			return &Data{Data: data}, err
+		}),
+		unary("UploadImage", func() message { return &Data{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.UploadImage(req.(*Data).Data)
+		}),
+		unary("SetSystemParameter", func() message { return &SystemParameterRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*SystemParameterRequest)
+			return &Empty{}, s.SetSystemParameter(r.Parameter, r.Content)
+		}),
+		unary("SetLED", func() message { return &LEDRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*LEDRequest)
+			return &Empty{}, s.SetLED(fingerprint.LEDColor(r.Color), fingerprint.LEDMode(r.Mode), r.Speed, r.Count)
+		}),
+		unary("ReadNotepad", func() message { return &NotepadRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			data, err := s.ReadNotepad(req.(*NotepadRequest).Page)
+			return &Data{Data: data}, err
+		}),
+		unary("WriteNotepad", func() message { return &NotepadRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*NotepadRequest)
+			return &Empty{}, s.WriteNotepad(r.Page, r.Data)
+		}),
+		unary("Handshake", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.Handshake()
+		}),
+		unary("CheckSensor", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.CheckSensor()
+		}),
+		unary("GenerateRandomNumber", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			value, err := s.GenerateRandomNumber()
+			return &RandomNumber{Value: value}, err
+		}),
+		unary("ProductInfo", newEmpty, func(s fingerprint.ScannerIO, req message) (message, error) {
+			p, err := s.ProductInfo()
+			if err != nil {
+				return nil, err
+			}
+			return &ProductInfo{
+				Model:           p.Model,
+				BatchNumber:     p.BatchNumber,
+				SerialNumber:    p.SerialNumber,
+				HardwareVersion: p.HardwareVersion,
+				SensorType:      p.SensorType,
+				ImageWidth:      p.ImageWidth,
+				ImageHeight:     p.ImageHeight,
+				TemplateSize:    p.TemplateSize,
+				DatabaseSize:    p.DatabaseSize,
+			}, nil
+		}),
+		unary("SetPassword", func() message { return &PasswordRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			return &Empty{}, s.SetPassword(req.(*PasswordRequest).Password)
+		}),
+		unary("ChangePassword", func() message { return &ChangePasswordRequest{} }, func(s fingerprint.ScannerIO, req message) (message, error) {
+			r := req.(*ChangePasswordRequest)
+			return &Empty{}, s.ChangePassword(r.OldPassword, r.NewPassword)
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchFinger",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := &WatchRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*Server).watchFinger(req, stream)
+			},
+		},
+		{
+			StreamName:    "Enroll",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := &EnrollRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*Server).enroll(req, stream)
+			},
+		},
+	},
+	Metadata: "scanner.proto",
+}
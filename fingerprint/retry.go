@@ -0,0 +1,97 @@
+package fingerprint
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+//ErrCommunication - The module reported an error receiving the command packet
+var ErrCommunication = errors.New("Communication error")
+
+//ErrChecksum - A packet from the module arrived with a wrong checksum
+var ErrChecksum = errors.New("Checksum match error")
+
+//Defaults of RetryPolicy
+const (
+	DefaultRetryAttempts   = 3
+	DefaultRetryBackoff    = 20 * time.Millisecond
+	DefaultRetryMaxBackoff = time.Second
+)
+
+//RetryPolicy - Repeats commands failing with ErrCommunication or ErrChecksum, as they do on noisy long cables.
+//Only commands which read from the module are repeated: GetSystemParameters, TemplateIndex, SearchTemplate,
+//FastSearchTemplate and CompareCharacteristics. The module may have carried out a store, delete or clear whose
+//response got damaged, so those are only repeated with StateChanging. Zero values take the defaults.
+type RetryPolicy struct {
+	//MaxAttempts - Including the first one
+	MaxAttempts int
+	//Backoff - Delay before the second attempt, doubling with every further one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	//Jitter - Fraction 0-1 of each delay taken off at random, so scanners on one bus do not retry in step
+	Jitter float64
+	//StateChanging - Also repeat StoreTemplate, DeleteFingerprint and ClearDatabase
+	StateChanging bool
+	//OnRetry - Optional, called with the failed attempt before waiting for the next one
+	OnRetry func(operation string, attempt int, err error)
+}
+
+//WithRetry - Repeats commands failing with transient errors under policy
+func WithRetry(policy RetryPolicy) Option {
+	return func(s *scanner) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = DefaultRetryAttempts
+		}
+		if policy.Backoff <= 0 {
+			policy.Backoff = DefaultRetryBackoff
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = DefaultRetryMaxBackoff
+		}
+		if policy.Jitter < 0 {
+			policy.Jitter = 0
+		} else if policy.Jitter > 1 {
+			policy.Jitter = 1
+		}
+		s.retryPolicy = &policy
+	}
+}
+
+//delay - Wait after the given failed attempt
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d - time.Duration(p.Jitter*rand.Float64()*float64(d))
+}
+
+//retryable - Transient failures of the link, the command did not get through or its response was damaged
+func retryable(err error) bool {
+	return err == ErrCommunication || err == ErrChecksum
+}
+
+//retry - Runs the exchange of a command with the module again while it fails with a transient error,
+//state changing commands only when the policy allows it
+func (s *scanner) retry(operation string, stateChanging bool, exchange func() error) error {
+	p := s.retryPolicy
+	if p == nil || (stateChanging && !p.StateChanging) {
+		return exchange()
+	}
+	for attempt := 1; ; attempt++ {
+		err := exchange()
+		if err == nil || !retryable(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+		//Whatever followed the damaged packet belongs to it
+		s.rxBuffer = nil
+		if p.OnRetry != nil {
+			p.OnRetry(operation, attempt, err)
+		}
+		time.Sleep(p.delay(attempt))
+	}
+}
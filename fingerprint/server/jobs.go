@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//Job states
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+//Job - Asynchronous enrollment tracked by the server
+type Job struct {
+	ID       string   `json:"id"`
+	Scanner  string   `json:"scanner"`
+	State    string   `json:"state"`
+	Step     string   `json:"step"`
+	Steps    []string `json:"steps"`
+	Position int      `json:"position"`
+	Error    string   `json:"error,omitempty"`
+	Hints    []string `json:"hints,omitempty"`
+
+	finished time.Time
+}
+
+//enrollRequest - min_quality above 0 scores every capture on the host before it is converted,
+//group picks the free position from a slot group
+type enrollRequest struct {
+	Position   *int   `json:"position"`
+	Group      string `json:"group"`
+	MinQuality int    `json:"min_quality"`
+}
+
+func (srv *Server) handleEnroll(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	req := enrollRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	position := -1
+	if req.Position != nil && *req.Position >= 0 {
+		position = *req.Position
+		if req.Group != "" {
+			writeError(w, http.StatusBadRequest, errors.New("position and group are exclusive"))
+			return
+		}
+		//Held from now on, so jobs queued for a free position do not take it first
+		if err := d.scanner.ReserveSlotAt(position); err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+	} else if req.Group != "" {
+		if _, err := d.scanner.SlotRange(req.Group); err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+	}
+
+	srv.mu.Lock()
+	srv.pruneJobs(time.Now())
+	srv.lastJob++
+	job := &Job{
+		ID:       strconv.Itoa(srv.lastJob),
+		Scanner:  d.name,
+		State:    JobRunning,
+		Steps:    []string{},
+		Position: position,
+	}
+	srv.jobs[job.ID] = job
+	snapshot := *job
+	srv.mu.Unlock()
+
+	go srv.runEnroll(d, job, req.Group, req.MinQuality)
+
+	w.Header().Set("Location", "/scanners/"+d.name+"/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+//runEnroll - Holds the scanner for the whole enrollment and records each step on the job,
+//with minQuality the hints of the last rejected capture as well. Without a position one is
+//reserved in group once the scanner is held, the reservation ends with the job.
+func (srv *Server) runEnroll(d *device, job *Job, group string, minQuality int) {
+	ctx, cancel := context.WithTimeout(context.Background(), srv.EnrollTimeout)
+	defer cancel()
+
+	progress := func(step fingerprint.EnrollStep) {
+		srv.mu.Lock()
+		job.Step = step.String()
+		job.Steps = append(job.Steps, job.Step)
+		srv.mu.Unlock()
+	}
+
+	check := fingerprint.QualityCheck{
+		MinScore: minQuality,
+		OnReject: func(q *fingerprint.ImageQuality) {
+			srv.mu.Lock()
+			job.Hints = q.Hints
+			srv.mu.Unlock()
+		},
+	}
+
+	srv.mu.Lock()
+	slot := job.Position
+	srv.mu.Unlock()
+	position := -1
+	err := srv.with(ctx, d, func() (err error) {
+		if slot < 0 {
+			if slot, err = d.scanner.ReserveSlot(group); err != nil {
+				return err
+			}
+			srv.mu.Lock()
+			job.Position = slot
+			srv.mu.Unlock()
+		}
+		if minQuality > 0 {
+			position, err = fingerprint.EnrollWithQuality(ctx, d.scanner, slot, check, progress)
+		} else {
+			position, err = fingerprint.Enroll(ctx, d.scanner, slot, progress)
+		}
+		return err
+	})
+	if slot >= 0 {
+		d.scanner.ReleaseSlot(slot)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	job.Position = position
+	job.finished = time.Now()
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+		return
+	}
+	job.State = JobDone
+}
+
+//pruneJobs - Forgets the jobs finished more than JobTTL ago, srv.mu has to be held
+func (srv *Server) pruneJobs(now time.Time) {
+	for id, job := range srv.jobs {
+		if job.State != JobRunning && now.Sub(job.finished) > srv.JobTTL {
+			delete(srv.jobs, id)
+		}
+	}
+}
+
+func (srv *Server) handleJob(w http.ResponseWriter, r *http.Request, d *device, id string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	srv.mu.Lock()
+	job, ok := srv.jobs[id]
+	var snapshot Job
+	if ok {
+		snapshot = *job
+		snapshot.Steps = append([]string(nil), job.Steps...)
+	}
+	srv.mu.Unlock()
+
+	if !ok || snapshot.Scanner != d.name {
+		writeError(w, http.StatusNotFound, errors.New("unknown job "+id))
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
@@ -0,0 +1,493 @@
+package server
+
+//OpenAPI - Description of the REST API, served at /openapi.yaml
+const OpenAPI = `openapi: 3.0.3
+info:
+  title: fpd fingerprint scanner API
+  version: 1.0.0
+  description: REST/JSON access to fingerprint scanners owned by the fpd daemon.
+paths:
+  /scanners:
+    get:
+      summary: List the registered scanners with the product info of modules that have one
+      responses:
+        "200":
+          description: Scanner names
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  scanners:
+                    type: array
+                    items:
+                      type: string
+                  devices:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        name:
+                          type: string
+                        product:
+                          $ref: "#/components/schemas/ProductInfo"
+  /scanners/{name}/parameters:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: Read the system parameters of the scanner
+      responses:
+        "200":
+          description: System parameters
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/SystemParameters"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/templates:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: List the occupied positions of the template library
+      responses:
+        "200":
+          description: Template index
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/TemplateIndex"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/templates/{position}:
+    parameters:
+      - $ref: "#/components/parameters/name"
+      - $ref: "#/components/parameters/position"
+    delete:
+      summary: Delete the template stored at a position
+      responses:
+        "204":
+          description: Template deleted
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/enroll:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    post:
+      summary: Start an asynchronous enrollment
+      requestBody:
+        required: false
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/EnrollRequest"
+      responses:
+        "202":
+          description: Enrollment job started, poll the Location header for progress
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Job"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/jobs/{id}:
+    parameters:
+      - $ref: "#/components/parameters/name"
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      summary: Read the progress of an enrollment job, finished jobs are answered with 404 ten minutes after they ended
+      responses:
+        "200":
+          description: Job state
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Job"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/identify:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    post:
+      summary: Wait for a finger and search the whole library or the positions of one group
+      parameters:
+        - name: group
+          in: query
+          required: false
+          description: Slot group configured for the scanner, 400 if there is none of that name
+          schema:
+            type: string
+      responses:
+        "200":
+          description: Search outcome
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/MatchResult"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/verify:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    post:
+      summary: Wait for a finger and compare it with one stored template
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/PositionRequest"
+      responses:
+        "200":
+          description: Comparison outcome
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/MatchResult"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/backup:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: Download every stored template
+      responses:
+        "200":
+          description: Library backup
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Backup"
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/duplicates:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: Search the library for fingers stored at more than one position, takes one search per template
+      responses:
+        "200":
+          description: Pairs of positions holding the same finger
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  pairs:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        first:
+                          type: integer
+                        second:
+                          type: integer
+                        score:
+                          type: integer
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/restore:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    post:
+      summary: Write templates from a backup back to the library, overwriting the templates at their positions
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Backup"
+      responses:
+        "200":
+          description: Number of restored templates
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  restored:
+                    type: integer
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/image:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: Wait for a finger and download the raw image, two 4 bit pixels per byte
+      responses:
+        "200":
+          description: Raw image
+          content:
+            application/octet-stream:
+              schema:
+                type: string
+                format: binary
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/led:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    post:
+      summary: Set the LED ring of R502-A/R503 modules
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/LEDRequest"
+      responses:
+        "204":
+          description: LED set
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/metadata:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: Read the metadata stored in the module notepad
+      responses:
+        "200":
+          description: Stored metadata, 404 when the notepad holds none
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Metadata"
+        default:
+          $ref: "#/components/responses/Error"
+    put:
+      summary: Store metadata in the module notepad, at most 506 bytes as JSON
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Metadata"
+      responses:
+        "204":
+          description: Metadata stored
+        default:
+          $ref: "#/components/responses/Error"
+  /scanners/{name}/health:
+    parameters:
+      - $ref: "#/components/parameters/name"
+    get:
+      summary: Liveness probe with link latency, sensor self test and library occupancy
+      responses:
+        "200":
+          description: Scanner healthy
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Health"
+        "503":
+          description: Sensor self test failed
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Health"
+        default:
+          $ref: "#/components/responses/Error"
+components:
+  parameters:
+    name:
+      name: name
+      in: path
+      required: true
+      schema:
+        type: string
+    position:
+      name: position
+      in: path
+      required: true
+      schema:
+        type: integer
+        minimum: 0
+  responses:
+    Error:
+      description: 404 unknown scanner, 408 no finger presented in time, 409 position held by another enrollment or holding a template, 429 locked out after failed attempts (see Retry-After), 501 not supported by the module, 502 device error, 507 no free position
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              error:
+                type: string
+  schemas:
+    SystemParameters:
+      type: object
+      properties:
+        StatusRegister:
+          type: integer
+          description: Bit 0 busy, 1 pass, 2 password verified, 3 image buffer valid
+        SystemID:
+          type: integer
+        StorageCapacity:
+          type: integer
+        SecurityLevel:
+          type: integer
+        DeviceAddress:
+          type: integer
+        PacketLength:
+          type: integer
+          description: Code 0-3 for data packets of 32, 64, 128 and 256 bytes
+        BaudRate:
+          type: integer
+          description: Multiplier N of 9600 bps
+    TemplateIndex:
+      type: object
+      properties:
+        capacity:
+          type: integer
+        used:
+          type: array
+          items:
+            type: integer
+    PositionRequest:
+      type: object
+      properties:
+        position:
+          type: integer
+          description: Library position, omit or -1 on enroll to pick a free one
+    EnrollRequest:
+      type: object
+      properties:
+        position:
+          type: integer
+          description: Library position, omit or -1 to pick a free one. 409 while another enrollment holds it or it holds a template
+        group:
+          type: string
+          description: Slot group to pick the free position from when there is no position
+        min_quality:
+          type: integer
+          description: Minimum host side image quality score 0-100 of each capture, omit or 0 to skip the check
+    LEDRequest:
+      type: object
+      properties:
+        color:
+          type: string
+          enum: [red, blue, purple, green, yellow, cyan, white]
+        mode:
+          type: string
+          enum: [breathing, flashing, "on", "off", fade_in, fade_out]
+        speed:
+          type: integer
+          minimum: 0
+          maximum: 255
+        count:
+          type: integer
+          minimum: 0
+          maximum: 255
+          description: Cycles of breathing or flashing, 0 repeats forever
+    Metadata:
+      type: object
+      properties:
+        asset_tag:
+          type: string
+        site_id:
+          type: string
+        schema_version:
+          type: integer
+        labels:
+          type: object
+          additionalProperties:
+            type: string
+    Health:
+      type: object
+      properties:
+        latency_ns:
+          type: integer
+          description: Round trip of a handshake, or of reading the system parameters on modules without it
+        sensor:
+          type: string
+          enum: [ok, abnormal, unknown]
+          description: Sensor self test, unknown on modules without it
+        busy:
+          type: boolean
+        pass:
+          type: boolean
+        password_verified:
+          type: boolean
+        image_buffer_valid:
+          type: boolean
+        templates:
+          type: integer
+        capacity:
+          type: integer
+        product:
+          $ref: "#/components/schemas/ProductInfo"
+    ProductInfo:
+      type: object
+      properties:
+        model:
+          type: string
+        batch_number:
+          type: string
+        serial_number:
+          type: string
+        hardware_version:
+          type: string
+        sensor_type:
+          type: string
+        image_width:
+          type: integer
+        image_height:
+          type: integer
+        template_size:
+          type: integer
+        database_size:
+          type: integer
+    MatchResult:
+      type: object
+      properties:
+        matched:
+          type: boolean
+        position:
+          type: integer
+        score:
+          type: integer
+    Job:
+      type: object
+      properties:
+        id:
+          type: string
+        scanner:
+          type: string
+        state:
+          type: string
+          enum: [running, done, failed]
+        step:
+          type: string
+          enum: [wait_first_finger, check_duplicate, remove_finger, wait_second_finger, compare, store, done, poor_quality]
+        steps:
+          type: array
+          items:
+            type: string
+        position:
+          type: integer
+        error:
+          type: string
+        hints:
+          type: array
+          description: Advice for the last capture rejected for its quality
+          items:
+            type: string
+    Backup:
+      type: object
+      properties:
+        capacity:
+          type: integer
+        templates:
+          type: array
+          items:
+            type: object
+            properties:
+              position:
+                type: integer
+              data:
+                type: string
+                format: byte
+`
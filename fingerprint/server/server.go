@@ -0,0 +1,648 @@
+//Package server exposes one or more fingerprint scanners over a REST/JSON API
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//DefaultFingerTimeout - How long identify, verify and image capture wait for a finger
+const DefaultFingerTimeout = 15 * time.Second
+
+//DefaultEnrollTimeout - How long an enroll job waits for both captures
+const DefaultEnrollTimeout = 60 * time.Second
+
+//DefaultJobTTL - How long a finished enroll job can still be read
+const DefaultJobTTL = 10 * time.Minute
+
+//device - A scanner together with the lock serialising access to its port
+type device struct {
+	name    string
+	scanner fingerprint.ScannerIO
+	lock    chan struct{}
+	//guard - Set when the server has a lockout policy
+	guard *fingerprint.Guard
+
+	//product - Read once, the module does not change while registered. productMu guards it
+	//as the cached value is returned without the scanner lock while a command runs
+	productMu   sync.Mutex
+	product     *fingerprint.ProductInfo
+	productRead bool
+}
+
+func (d *device) acquire(ctx context.Context) error {
+	select {
+	case d.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *device) release() {
+	<-d.lock
+}
+
+//identify - The whole library, or the positions of the named group
+func (d *device) identify(ctx context.Context, group string) (*fingerprint.SearchResult, error) {
+	switch {
+	case d.guard != nil && group != "":
+		return d.guard.IdentifyGroup(ctx, group)
+	case d.guard != nil:
+		return d.guard.Identify(ctx)
+	case group != "":
+		return fingerprint.IdentifyGroup(ctx, d.scanner, group)
+	}
+	return fingerprint.Identify(ctx, d.scanner)
+}
+
+func (d *device) verify(ctx context.Context, position int) (int, error) {
+	if d.guard != nil {
+		return d.guard.Verify(ctx, position)
+	}
+	return fingerprint.Verify(ctx, d.scanner, position)
+}
+
+//productInfo - Cached product info, nil for modules without it or while the scanner is busy
+func (d *device) productInfo() *fingerprint.ProductInfo {
+	d.productMu.Lock()
+	product, read := d.product, d.productRead
+	d.productMu.Unlock()
+	if read {
+		return product
+	}
+
+	select {
+	case d.lock <- struct{}{}:
+	default:
+		return nil
+	}
+	defer d.release()
+	info, err := d.scanner.ProductInfo()
+	if err != nil && err != fingerprint.ErrNotSupported {
+		return nil
+	}
+	d.productMu.Lock()
+	defer d.productMu.Unlock()
+	d.product, d.productRead = info, true
+	return info
+}
+
+//Server - HTTP handler owning a set of named scanners
+type Server struct {
+	FingerTimeout time.Duration
+	EnrollTimeout time.Duration
+	//JobTTL - Finished jobs are forgotten this long after they ended
+	JobTTL time.Duration
+	//Lockout - Optional, set before adding scanners to guard identify and verify against brute force
+	Lockout *fingerprint.LockoutPolicy
+
+	mu      sync.Mutex
+	devices map[string]*device
+	jobs    map[string]*Job
+	lastJob int
+}
+
+//New - Create an empty server, scanners are added with Add
+func New() *Server {
+	return &Server{
+		FingerTimeout: DefaultFingerTimeout,
+		EnrollTimeout: DefaultEnrollTimeout,
+		JobTTL:        DefaultJobTTL,
+		devices:       make(map[string]*device),
+		jobs:          make(map[string]*Job),
+	}
+}
+
+//Add - Register a captured scanner under the given name
+func (srv *Server) Add(name string, s fingerprint.ScannerIO) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid scanner name %q", name)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if _, ok := srv.devices[name]; ok {
+		return fmt.Errorf("scanner %q already registered", name)
+	}
+	d := &device{name: name, scanner: s, lock: make(chan struct{}, 1)}
+	if srv.Lockout != nil {
+		policy := *srv.Lockout
+		if onEvent := srv.Lockout.OnEvent; onEvent != nil {
+			policy.OnEvent = func(ev fingerprint.SecurityEvent) {
+				ev.Scanner = name
+				onEvent(ev)
+			}
+		}
+		d.guard = fingerprint.NewGuard(s, policy)
+	}
+	srv.devices[name] = d
+	return nil
+}
+
+//Names - Registered scanner names in sorted order
+func (srv *Server) Names() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	names := make([]string, 0, len(srv.devices))
+	for name := range srv.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (srv *Server) device(name string) *device {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.devices[name]
+}
+
+//ServeHTTP - Routes /scanners/{name}/{operation}[/{argument}]
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case path == "openapi.yaml":
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write([]byte(OpenAPI))
+		return
+	case path == "scanners":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		srv.handleList(w)
+		return
+	case !strings.HasPrefix(path, "scanners/"):
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "scanners/"), "/")
+	d := srv.device(parts[0])
+	if d == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown scanner %q", parts[0]))
+		return
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	arg := ""
+	if len(parts) == 3 {
+		arg = parts[2]
+	}
+
+	switch {
+	case parts[1] == "parameters" && arg == "":
+		srv.handleParameters(w, r, d)
+	case parts[1] == "templates" && arg == "":
+		srv.handleIndex(w, r, d)
+	case parts[1] == "templates":
+		srv.handleDelete(w, r, d, arg)
+	case parts[1] == "identify" && arg == "":
+		srv.handleIdentify(w, r, d)
+	case parts[1] == "verify" && arg == "":
+		srv.handleVerify(w, r, d)
+	case parts[1] == "enroll" && arg == "":
+		srv.handleEnroll(w, r, d)
+	case parts[1] == "jobs" && arg != "":
+		srv.handleJob(w, r, d, arg)
+	case parts[1] == "backup" && arg == "":
+		srv.handleBackup(w, r, d)
+	case parts[1] == "duplicates" && arg == "":
+		srv.handleDuplicates(w, r, d)
+	case parts[1] == "restore" && arg == "":
+		srv.handleRestore(w, r, d)
+	case parts[1] == "image" && arg == "":
+		srv.handleImage(w, r, d)
+	case parts[1] == "led" && arg == "":
+		srv.handleLED(w, r, d)
+	case parts[1] == "metadata" && arg == "":
+		srv.handleMetadata(w, r, d)
+	case parts[1] == "health" && arg == "":
+		srv.handleHealth(w, r, d)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+//Template - Stored template as exchanged by backup and restore
+type Template struct {
+	Position int    `json:"position"`
+	Data     []byte `json:"data"`
+}
+
+//Backup - Every used position of a scanner library
+type Backup struct {
+	Capacity  int        `json:"capacity"`
+	Templates []Template `json:"templates"`
+}
+
+//MatchResult - Outcome of identify and verify
+type MatchResult struct {
+	Matched  bool `json:"matched"`
+	Position int  `json:"position"`
+	Score    int  `json:"score"`
+}
+
+//TemplateIndex - Occupied positions of a scanner library
+type TemplateIndex struct {
+	Capacity int   `json:"capacity"`
+	Used     []int `json:"used"`
+}
+
+type positionRequest struct {
+	Position *int `json:"position"`
+}
+
+func (srv *Server) handleParameters(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var params *fingerprint.SystemParameters
+	err := srv.with(r.Context(), d, func() (err error) {
+		params, err = d.scanner.GetSystemParameters()
+		return err
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, params)
+}
+
+func (srv *Server) handleIndex(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var index []bool
+	err := srv.with(r.Context(), d, func() (err error) {
+		index, err = d.scanner.TemplateIndex()
+		return err
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TemplateIndex{Capacity: len(index), Used: fingerprint.UsedPositions(index)})
+}
+
+func (srv *Server) handleDelete(w http.ResponseWriter, r *http.Request, d *device, arg string) {
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+	position, err := strconv.Atoi(arg)
+	if err != nil || position < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid position %q", arg))
+		return
+	}
+	err = srv.with(r.Context(), d, func() error {
+		_, err := d.scanner.DeleteFingerprint(position, 1)
+		return err
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) handleIdentify(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), srv.FingerTimeout)
+	defer cancel()
+
+	var result *fingerprint.SearchResult
+	err := srv.with(ctx, d, func() (err error) {
+		result, err = d.identify(ctx, r.URL.Query().Get("group"))
+		return err
+	})
+	if err == fingerprint.ErrNoMatch {
+		writeJSON(w, http.StatusOK, MatchResult{Matched: false, Position: -1})
+		return
+	}
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MatchResult{Matched: true, Position: result.PositionNumber, Score: result.AccuracyScore})
+}
+
+func (srv *Server) handleVerify(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req positionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Position == nil {
+		writeError(w, http.StatusBadRequest, errors.New("position is required"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), srv.FingerTimeout)
+	defer cancel()
+
+	var score int
+	err := srv.with(ctx, d, func() (err error) {
+		score, err = d.verify(ctx, *req.Position)
+		return err
+	})
+	if err == fingerprint.ErrNoMatch {
+		writeJSON(w, http.StatusOK, MatchResult{Matched: false, Position: *req.Position})
+		return
+	}
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MatchResult{Matched: true, Position: *req.Position, Score: score})
+}
+
+func (srv *Server) handleBackup(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	backup := Backup{Templates: []Template{}}
+	err := srv.with(r.Context(), d, func() error {
+		index, err := d.scanner.TemplateIndex()
+		if err != nil {
+			return err
+		}
+		backup.Capacity = len(index)
+		for _, position := range fingerprint.UsedPositions(index) {
+			data, err := fingerprint.ExportTemplate(d.scanner, position)
+			if err != nil {
+				return fmt.Errorf("position %d: %v", position, err)
+			}
+			backup.Templates = append(backup.Templates, Template{Position: position, Data: data})
+		}
+		return nil
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, backup)
+}
+
+//handleDuplicates - Runs as long as the library takes to search, the request is cancelled with the client
+func (srv *Server) handleDuplicates(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var pairs []fingerprint.DuplicatePair
+	err := srv.with(r.Context(), d, func() (err error) {
+		pairs, err = fingerprint.FindDuplicates(r.Context(), d.scanner, nil)
+		return err
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]fingerprint.DuplicatePair{"pairs": pairs})
+}
+
+func (srv *Server) handleRestore(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var backup Backup
+	if err := json.NewDecoder(r.Body).Decode(&backup); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	restored := 0
+	err := srv.with(r.Context(), d, func() error {
+		for _, t := range backup.Templates {
+			if err := fingerprint.ImportTemplate(d.scanner, t.Position, t.Data); err != nil {
+				return fmt.Errorf("position %d: %v", t.Position, err)
+			}
+			restored++
+		}
+		return nil
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"restored": restored})
+}
+
+func (srv *Server) handleImage(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), srv.FingerTimeout)
+	defer cancel()
+
+	var image []byte
+	err := srv.with(ctx, d, func() (err error) {
+		image, err = fingerprint.CaptureImage(ctx, d.scanner)
+		return err
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(image)
+}
+
+var ledColors = map[string]fingerprint.LEDColor{
+	"red": fingerprint.LEDRed, "blue": fingerprint.LEDBlue, "purple": fingerprint.LEDPurple, "green": fingerprint.LEDGreen,
+	"yellow": fingerprint.LEDYellow, "cyan": fingerprint.LEDCyan, "white": fingerprint.LEDWhite,
+}
+
+var ledModes = map[string]fingerprint.LEDMode{
+	"breathing": fingerprint.LEDBreathing, "flashing": fingerprint.LEDFlashing, "on": fingerprint.LEDOn,
+	"off": fingerprint.LEDOff, "fade_in": fingerprint.LEDFadeIn, "fade_out": fingerprint.LEDFadeOut,
+}
+
+//ledRequest - Colour and mode by name, e.g. green and on to signal access granted
+type ledRequest struct {
+	Color string `json:"color"`
+	Mode  string `json:"mode"`
+	Speed int    `json:"speed"`
+	Count int    `json:"count"`
+}
+
+func (srv *Server) handleLED(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req ledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	color, okColor := ledColors[req.Color]
+	mode, okMode := ledModes[req.Mode]
+	if !okColor || !okMode {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown LED colour %q or mode %q", req.Color, req.Mode))
+		return
+	}
+	err := srv.with(r.Context(), d, func() error {
+		return d.scanner.SetLED(color, mode, req.Speed, req.Count)
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//deviceInfo - Entry of the scanner list
+type deviceInfo struct {
+	Name    string                   `json:"name"`
+	Product *fingerprint.ProductInfo `json:"product,omitempty"`
+}
+
+//handleList - Names, and for inventory the product info of every scanner not busy with a command
+func (srv *Server) handleList(w http.ResponseWriter) {
+	names := srv.Names()
+	devices := make([]deviceInfo, 0, len(names))
+	for _, name := range names {
+		if d := srv.device(name); d != nil {
+			devices = append(devices, deviceInfo{Name: name, Product: d.productInfo()})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"scanners": names, "devices": devices})
+}
+
+//handleMetadata - GET reads the metadata kept in the module notepad, PUT replaces it
+func (srv *Server) handleMetadata(w http.ResponseWriter, r *http.Request, d *device) {
+	switch r.Method {
+	case http.MethodGet:
+		var m *fingerprint.Metadata
+		err := srv.with(r.Context(), d, func() (err error) {
+			m, err = fingerprint.ReadMetadata(d.scanner)
+			return err
+		})
+		if err == fingerprint.ErrNoMetadata {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	case http.MethodPut:
+		var m fingerprint.Metadata
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err := srv.with(r.Context(), d, func() error {
+			return fingerprint.WriteMetadata(d.scanner, &m)
+		})
+		if errors.Is(err, fingerprint.ErrMetadataTooLarge) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+//handleHealth - Liveness probe for monitoring, 503 when the sensor self test fails
+func (srv *Server) handleHealth(w http.ResponseWriter, r *http.Request, d *device) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var health *fingerprint.Health
+	err := srv.with(r.Context(), d, func() (err error) {
+		health, err = fingerprint.HealthCheck(d.scanner)
+		return err
+	})
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if health.Sensor == fingerprint.SensorAbnormal {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+//with - Runs fn while holding the scanner lock
+func (srv *Server) with(ctx context.Context, d *device, fn func() error) error {
+	if err := d.acquire(ctx); err != nil {
+		return err
+	}
+	defer d.release()
+	return fn()
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+//writeDeviceError - Timeouts waiting for a finger or the scanner lock map to 408, commands the module
+//does not implement to 501, lockouts to 429, reserved or occupied positions to 409, a full library to 507,
+//everything else to 502
+func writeDeviceError(w http.ResponseWriter, err error) {
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		writeError(w, http.StatusRequestTimeout, err)
+		return
+	}
+	if err == fingerprint.ErrNotSupported {
+		writeError(w, http.StatusNotImplemented, err)
+		return
+	}
+	if errors.Is(err, fingerprint.ErrUnknownGroup) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, fingerprint.ErrSlotReserved) || errors.Is(err, fingerprint.ErrSlotOccupied) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err == fingerprint.ErrLibraryFull {
+		writeError(w, http.StatusInsufficientStorage, err)
+		return
+	}
+	var lockout *fingerprint.LockoutError
+	if errors.As(err, &lockout) {
+		retry := time.Until(lockout.Until).Round(time.Second) / time.Second
+		if retry < 1 {
+			retry = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(retry)))
+		writeError(w, http.StatusTooManyRequests, err)
+		return
+	}
+	writeError(w, http.StatusBadGateway, err)
+}
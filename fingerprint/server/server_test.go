@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SachinPuranik/verizy-go-fingerprint/fingerprint"
+)
+
+//fakeScanner - Library of 8 positions, a finger is on the sensor at every other ReadImage and matches position
+//match, -1 for none. Methods the handlers do not use are left to the embedded nil interface.
+type fakeScanner struct {
+	fingerprint.ScannerIO
+	index []bool
+	match int
+	reads int
+	slots *fingerprint.Allocator
+}
+
+func newFake(used ...int) *fakeScanner {
+	f := &fakeScanner{index: make([]bool, 8), match: -1, slots: fingerprint.NewAllocator(nil)}
+	for _, position := range used {
+		f.index[position] = true
+	}
+	return f
+}
+
+func (f *fakeScanner) GetSystemParameters() (*fingerprint.SystemParameters, error) {
+	return &fingerprint.SystemParameters{StorageCapacity: uint(len(f.index)), PacketLength: 2}, nil
+}
+
+func (f *fakeScanner) ReadImage() bool {
+	f.reads++
+	return f.reads%2 == 1
+}
+
+func (f *fakeScanner) ConvertImage(charBufferNo int) bool {
+	return true
+}
+
+func (f *fakeScanner) SearchTemplate(charBufferNo int, startPos int, count int) (*fingerprint.SearchResult, error) {
+	if f.match < 0 {
+		return &fingerprint.SearchResult{PositionNumber: -1, AccuracyScore: -1}, nil
+	}
+	return &fingerprint.SearchResult{PositionNumber: f.match, AccuracyScore: 90}, nil
+}
+
+func (f *fakeScanner) CompareCharacteristics() (int, error) {
+	return 80, nil
+}
+
+func (f *fakeScanner) LoadTemplate(position int, charBufferNo int) error {
+	return nil
+}
+
+func (f *fakeScanner) CreateTemplate() error {
+	return nil
+}
+
+func (f *fakeScanner) StoreTemplate(position int, charBufferNo int) (int, error) {
+	f.index[position] = true
+	return position, nil
+}
+
+func (f *fakeScanner) DeleteFingerprint(position int, count int) (bool, error) {
+	f.index[position] = false
+	return true, nil
+}
+
+func (f *fakeScanner) TemplateIndex() ([]bool, error) {
+	return f.index, nil
+}
+
+func (f *fakeScanner) DownloadCharacteristics(charBufferNo int) ([]byte, error) {
+	return []byte{1, 2, 3}, nil
+}
+
+func (f *fakeScanner) UploadCharacteristics(charBufferNo int, data []byte) error {
+	return nil
+}
+
+func (f *fakeScanner) DownloadImage() ([]byte, error) {
+	return []byte{0xAB}, nil
+}
+
+func (f *fakeScanner) SetLED(color fingerprint.LEDColor, mode fingerprint.LEDMode, speed int, count int) error {
+	return fingerprint.ErrNotSupported
+}
+
+func (f *fakeScanner) ProductInfo() (*fingerprint.ProductInfo, error) {
+	return nil, fingerprint.ErrNotSupported
+}
+
+func (f *fakeScanner) ReserveSlot(group string) (int, error) {
+	return f.slots.Allocate(f.index, 0, len(f.index)-1, true)
+}
+
+func (f *fakeScanner) ReserveSlotAt(position int) error {
+	return f.slots.Reserve(position)
+}
+
+func (f *fakeScanner) ReleaseSlot(position int) {
+	f.slots.Release(position)
+}
+
+func (f *fakeScanner) SlotRange(name string) (fingerprint.SlotRange, error) {
+	return fingerprint.SlotRange{}, fmt.Errorf("%w: %s", fingerprint.ErrUnknownGroup, name)
+}
+
+func request(t *testing.T, ts *httptest.Server, method string, path string, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestHandlers(t *testing.T) {
+	srv := New()
+	f := newFake(1, 4)
+	f.match = 4
+	if err := srv.Add("door", f); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"GET", "/scanners", "", http.StatusOK, `"scanners":["door"]`},
+		{"GET", "/scanners/door/parameters", "", http.StatusOK, `"StorageCapacity":8`},
+		{"GET", "/scanners/door/templates", "", http.StatusOK, `{"capacity":8,"used":[1,4]}`},
+		{"POST", "/scanners/door/identify", "", http.StatusOK, `{"matched":true,"position":4,"score":90}`},
+		{"POST", "/scanners/door/verify", `{"position":1}`, http.StatusOK, `{"matched":true,"position":1,"score":80}`},
+		{"POST", "/scanners/door/verify", `{}`, http.StatusBadRequest, "position is required"},
+		{"GET", "/scanners/door/backup", "", http.StatusOK, `{"position":1,"data":"AQID"},{"position":4,"data":"AQID"}`},
+		{"POST", "/scanners/door/restore", `{"templates":[{"position":4,"data":"AQID"},{"position":6,"data":"AQID"}]}`, http.StatusOK, `{"restored":2}`},
+		{"DELETE", "/scanners/door/templates/6", "", http.StatusNoContent, ""},
+		{"DELETE", "/scanners/door/templates/x", "", http.StatusBadRequest, "invalid position"},
+		{"GET", "/scanners/door/image", "", http.StatusOK, "\xab"},
+		{"POST", "/scanners/door/led", `{"color":"green","mode":"on"}`, http.StatusNotImplemented, "does not support"},
+		{"POST", "/scanners/door/led", `{"color":"pink","mode":"on"}`, http.StatusBadRequest, "unknown LED colour"},
+		{"POST", "/scanners/door/identify?group=staff", "", http.StatusBadRequest, "staff"},
+		{"GET", "/scanners/door/identify", "", http.StatusMethodNotAllowed, "not allowed"},
+		{"GET", "/scanners/gate/templates", "", http.StatusNotFound, "unknown scanner"},
+		{"GET", "/scanners/door/nothing", "", http.StatusNotFound, "not found"},
+	} {
+		resp, body := request(t, ts, c.method, c.path, c.body)
+		if resp.StatusCode != c.status || !strings.Contains(body, c.want) {
+			t.Errorf("%s %s = %d %s, want %d %s", c.method, c.path, resp.StatusCode, body, c.status, c.want)
+		}
+	}
+	if got := fmt.Sprint(f.index); got != "[false true false false true false false false]" {
+		t.Errorf("library after restore and delete %s", got)
+	}
+}
+
+func TestWriteDeviceError(t *testing.T) {
+	for _, c := range []struct {
+		err    error
+		status int
+	}{
+		{context.DeadlineExceeded, http.StatusRequestTimeout},
+		{context.Canceled, http.StatusRequestTimeout},
+		{fingerprint.ErrNotSupported, http.StatusNotImplemented},
+		{fmt.Errorf("%w: staff", fingerprint.ErrUnknownGroup), http.StatusBadRequest},
+		{fmt.Errorf("%w: 3", fingerprint.ErrSlotReserved), http.StatusConflict},
+		{fmt.Errorf("%w: 3", fingerprint.ErrSlotOccupied), http.StatusConflict},
+		{fingerprint.ErrLibraryFull, http.StatusInsufficientStorage},
+		{errors.New("the received packet is no ack packet"), http.StatusBadGateway},
+	} {
+		w := httptest.NewRecorder()
+		writeDeviceError(w, c.err)
+		if w.Code != c.status {
+			t.Errorf("%v: %d, want %d", c.err, w.Code, c.status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	writeDeviceError(w, &fingerprint.LockoutError{Until: time.Now().Add(30 * time.Second)})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("lockout: %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+//waitJob - Polls the job until it finished
+func waitJob(t *testing.T, ts *httptest.Server, location string) Job {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		_, body := request(t, ts, "GET", location, "")
+		var job Job
+		if err := json.Unmarshal([]byte(body), &job); err != nil {
+			t.Fatalf("%s: %s", location, body)
+		}
+		if job.State != JobRunning {
+			return job
+		}
+	}
+	t.Fatalf("%s still running", location)
+	return Job{}
+}
+
+func TestEnrollJob(t *testing.T) {
+	srv := New()
+	srv.Add("door", newFake(0, 1))
+	srv.Add("full", newFake(0, 1, 2, 3, 4, 5, 6, 7))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	resp, body := request(t, ts, "POST", "/scanners/door/enroll", `{"position":5}`)
+	if resp.StatusCode != http.StatusAccepted || !strings.Contains(body, `"position":5`) {
+		t.Fatalf("enroll = %d %s", resp.StatusCode, body)
+	}
+	job := waitJob(t, ts, resp.Header.Get("Location"))
+	if job.State != JobDone || job.Position != 5 || job.Steps[len(job.Steps)-1] != "done" {
+		t.Errorf("job %+v", job)
+	}
+	if resp, body = request(t, ts, "POST", "/scanners/door/enroll", `{"position":1}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("enroll = %d %s", resp.StatusCode, body)
+	}
+	if job = waitJob(t, ts, resp.Header.Get("Location")); job.State != JobFailed || job.Position != -1 ||
+		!strings.Contains(job.Error, "holds a template") {
+		t.Errorf("occupied position: %+v", job)
+	}
+
+	//The reservation fails before anything is enrolled
+	resp, _ = request(t, ts, "POST", "/scanners/full/enroll", `{}`)
+	if job = waitJob(t, ts, resp.Header.Get("Location")); job.State != JobFailed || job.Position != -1 ||
+		job.Error != fingerprint.ErrLibraryFull.Error() {
+		t.Errorf("full library: %+v", job)
+	}
+	if resp, _ = request(t, ts, "GET", "/scanners/door/jobs/3", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("job of another scanner: %d", resp.StatusCode)
+	}
+}
+
+func TestJobExpiry(t *testing.T) {
+	srv := New()
+	srv.JobTTL = time.Millisecond
+	srv.Add("door", newFake())
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	resp, _ := request(t, ts, "POST", "/scanners/door/enroll", `{}`)
+	first := resp.Header.Get("Location")
+	waitJob(t, ts, first)
+	time.Sleep(5 * time.Millisecond)
+
+	resp, _ = request(t, ts, "POST", "/scanners/door/enroll", `{}`)
+	if resp, body := request(t, ts, "GET", first, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expired job = %d %s", resp.StatusCode, body)
+	}
+	if job := waitJob(t, ts, resp.Header.Get("Location")); job.State != JobDone {
+		t.Errorf("job %+v", job)
+	}
+}
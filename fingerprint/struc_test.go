@@ -0,0 +1,94 @@
+package fingerprint
+
+import (
+	"bytes"
+
+	"github.com/lunixbochs/struc"
+)
+
+//The struc based encoder replaced by commands.go, kept to compare wire output and speed
+
+type strucPacket struct {
+	StartCode      uint `struc:"uint16,big"`
+	Address        uint `struc:"uint32,big"`
+	PacketType     uint `struc:"uint8,big"`
+	PacketLength   uint `struc:"uint16,big"`
+	PayLoad        string
+	PacketChecksum uint `struc:"int16,big"`
+}
+
+func strucBuildCommandPacket(packetType int, packetPayload []byte) []byte {
+	var buf bytes.Buffer
+	packetLength := len(packetPayload) + 2
+	tp := &strucPacket{
+		StartCode:      FINGERPRINT_STARTCODE,
+		Address:        0xFFFFFFFF,
+		PacketType:     uint(packetType),
+		PacketLength:   uint(packetLength),
+		PayLoad:        string(packetPayload),
+		PacketChecksum: uint(calculateChecksum(packetType, packetLength, packetPayload)),
+	}
+	if err := struc.Pack(&buf, tp); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func strucDecodeResponsePacket(opBuf []byte) (*strucPacket, error) {
+	op := new(strucPacket)
+	if err := struc.Unpack(bytes.NewBuffer(opBuf), op); err != nil {
+		return nil, err
+	}
+	//struc does not know the payload length
+	l := len(opBuf)
+	op.PayLoad = string(opBuf[9 : l-2])
+	op.PacketChecksum = uint(opBuf[l-2])<<8 | uint(opBuf[l-1])
+	return op, nil
+}
+
+func strucBytes(pl interface{}) []byte {
+	var payLoad bytes.Buffer
+	if err := struc.Pack(&payLoad, pl); err != nil {
+		panic(err)
+	}
+	return payLoad.Bytes()
+}
+
+type strucPayload8 struct {
+	PayLoadType uint `struc:"int8,big"`
+}
+
+type strucPayload8N32 struct {
+	PayLoadType uint `struc:"uint8,big"`
+	DataValue   uint `struc:"uint32,big"`
+}
+
+type strucPayload8N8 struct {
+	PayLoadType int `struc:"int8,big"`
+	DataValue   int `struc:"int8,big"`
+}
+
+type strucSystemParameter struct {
+	PayLoadType int `struc:"int8,big"`
+	ParameterNo int `struc:"int8,big"`
+	Content     int `struc:"int8,big"`
+}
+
+type strucSearch struct {
+	PayLoadType   int `struc:"int8,big"`
+	CharBufferNo  int `struc:"int8,big"`
+	StartPos      int `struc:"int16,big"`
+	TemplateCount int `struc:"int16,big"`
+}
+
+type strucTemplate struct {
+	PayLoadType    int `struc:"int8,big"`
+	CharBufferNo   int `struc:"int8,big"`
+	PositionNumber int `struc:"int16,big"`
+}
+
+type strucDelete struct {
+	PayLoadType    int `struc:"int8,big"`
+	PositionNumber int `struc:"int16,big"`
+	Count          int `struc:"int16,big"`
+}
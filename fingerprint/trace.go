@@ -0,0 +1,248 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+//Trace directions
+const (
+	TraceTX = "tx"
+	TraceRX = "rx"
+)
+
+//ErrReplayEnd - The recorded session has no more responses for the scanner
+var ErrReplayEnd = fmt.Errorf("%w: end of recorded session", ErrLinkDown)
+
+//TracePacket - Fields of a complete packet found in the traced bytes
+type TracePacket struct {
+	Type         string `json:"type"`
+	Address      uint   `json:"address"`
+	Length       uint   `json:"length"`
+	Instruction  *int   `json:"instruction,omitempty"`
+	Confirmation *int   `json:"confirmation,omitempty"`
+	Payload      string `json:"payload"`
+	ChecksumOK   bool   `json:"checksum_ok"`
+}
+
+//TraceRecord - One chunk written to or read from the sensor, stored as one JSON line
+type TraceRecord struct {
+	Time      time.Time     `json:"time"`
+	Direction string        `json:"dir"`
+	Data      string        `json:"data"`
+	Packets   []TracePacket `json:"packets,omitempty"`
+}
+
+var packetTypeNames = map[uint]string{
+	FINGERPRINT_COMMANDPACKET: "command",
+	FINGERPRINT_ACKPACKET:     "ack",
+	FINGERPRINT_DATAPACKET:    "data",
+	FINGERPRINT_ENDDATAPACKET: "enddata",
+}
+
+//Option - Optional scanner settings passed to the constructors
+type Option func(*scanner)
+
+func (s *scanner) applyOptions(opts []Option) {
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.allocator == nil {
+		s.allocator = NewAllocator(nil)
+	}
+}
+
+//WithTrace - Records every chunk exchanged with the sensor to w as JSON lines
+func WithTrace(w io.Writer) Option {
+	return func(s *scanner) {
+		s.link = &traceTransport{link: s.link, out: json.NewEncoder(w)}
+	}
+}
+
+//traceTransport - Passes everything through to link and records it
+type traceTransport struct {
+	link transport
+	out  *json.Encoder
+	tx   []byte
+	rx   []byte
+}
+
+func (t *traceTransport) open() error {
+	t.tx, t.rx = nil, nil
+	return t.link.open()
+}
+
+func (t *traceTransport) close() {
+	t.link.close()
+}
+
+func (t *traceTransport) write(payLoad []byte) (int, error) {
+	numBytes, err := t.link.write(payLoad)
+	if numBytes > 0 {
+		t.record(TraceTX, payLoad[:numBytes], &t.tx)
+	}
+	return numBytes, err
+}
+
+func (t *traceTransport) readFragement(readSize int) ([]byte, int, error) {
+	buf, readBytes, err := t.link.readFragement(readSize)
+	if readBytes > 0 {
+		t.record(TraceRX, buf[:readBytes], &t.rx)
+	}
+	return buf, readBytes, err
+}
+
+func (t *traceTransport) checkBaud(baud int) error {
+	if bs, ok := t.link.(baudSetter); ok {
+		return bs.checkBaud(baud)
+	}
+	return nil
+}
+
+func (t *traceTransport) setBaud(baud int) error {
+	if bs, ok := t.link.(baudSetter); ok {
+		return bs.setBaud(baud)
+	}
+	return nil
+}
+
+func (t *traceTransport) observeReconnects(fn func()) {
+	if ro, ok := t.link.(reconnectObserver); ok {
+		ro.observeReconnects(fn)
+	}
+}
+
+//record - Writes the chunk together with every packet it completes in the direction's stream
+func (t *traceTransport) record(direction string, chunk []byte, pending *[]byte) {
+	rec := TraceRecord{Time: time.Now(), Direction: direction, Data: hex.EncodeToString(chunk)}
+
+	*pending = append(*pending, chunk...)
+	var packet []byte
+	for {
+		packet, *pending = nextPacket(*pending)
+		if packet == nil {
+			break
+		}
+		rec.Packets = append(rec.Packets, tracePacket(packet))
+	}
+
+	if err := t.out.Encode(rec); err != nil {
+		log.Println("Unable to write trace:", err.Error())
+	}
+}
+
+func tracePacket(packet []byte) TracePacket {
+	tp, err := decodeResponsePacket(packet)
+	if err != nil {
+		return TracePacket{Type: "invalid", Payload: hex.EncodeToString(packet)}
+	}
+	result := TracePacket{
+		Type:       packetTypeNames[tp.PacketType],
+		Address:    tp.Address,
+		Length:     tp.PacketLength,
+		Payload:    hex.EncodeToString(tp.PayLoad),
+		ChecksumOK: verifyChecksum(tp) == nil,
+	}
+	if result.Type == "" {
+		result.Type = fmt.Sprintf("unknown(0x%02x)", tp.PacketType)
+	}
+	if len(tp.PayLoad) > 0 {
+		code := int(tp.PayLoad[0])
+		switch tp.PacketType {
+		case FINGERPRINT_COMMANDPACKET:
+			result.Instruction = &code
+		case FINGERPRINT_ACKPACKET:
+			result.Confirmation = &code
+		}
+	}
+	return result
+}
+
+//ReadTrace - Parses a trace written by WithTrace
+func ReadTrace(r io.Reader) ([]TraceRecord, error) {
+	var records []TraceRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("trace line %d: %v", line, err)
+		}
+		if rec.Direction != TraceTX && rec.Direction != TraceRX {
+			return nil, fmt.Errorf("trace line %d: unknown direction %q", line, rec.Direction)
+		}
+		if _, err := hex.DecodeString(rec.Data); err != nil {
+			return nil, fmt.Errorf("trace line %d: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+//NewReplay - Create Scanner answering from a trace written by WithTrace, for regression tests without a sensor.
+//Every packet the scanner sends has to match the recorded one, otherwise the command fails.
+func NewReplay(trace io.Reader, password uint, opts ...Option) (ScannerIO, error) {
+	records, err := ReadTrace(trace)
+	if err != nil {
+		return nil, err
+	}
+	s := &scanner{}
+	s.link = &myReplay{records: records}
+	s.password = password
+	s.applyOptions(opts)
+	return s, nil
+}
+
+//myReplay - Transport playing back recorded chunks, timing is not reproduced
+type myReplay struct {
+	records []TraceRecord
+	next    int
+	offset  int
+}
+
+func (r *myReplay) open() error {
+	return nil
+}
+
+func (r *myReplay) close() {
+}
+
+func (r *myReplay) write(payLoad []byte) (int, error) {
+	if r.next >= len(r.records) {
+		return -1, ErrReplayEnd
+	}
+	rec := r.records[r.next]
+	expected, _ := hex.DecodeString(rec.Data)
+	if rec.Direction != TraceTX || !bytes.Equal(expected, payLoad) {
+		return -1, fmt.Errorf("replay diverged at record %d: expected %s %x, got tx %x", r.next+1, rec.Direction, expected, payLoad)
+	}
+	r.next++
+	return len(payLoad), nil
+}
+
+func (r *myReplay) readFragement(readSize int) ([]byte, int, error) {
+	if r.next >= len(r.records) || r.records[r.next].Direction != TraceRX {
+		return nil, -1, ErrReplayEnd
+	}
+	data, _ := hex.DecodeString(r.records[r.next].Data)
+	data = data[r.offset:]
+	if len(data) > readSize {
+		r.offset += readSize
+		data = data[:readSize]
+	} else {
+		r.next++
+		r.offset = 0
+	}
+	buf := make([]byte, readSize)
+	copy(buf, data)
+	return buf, len(data), nil
+}
@@ -0,0 +1,121 @@
+package fingerprint
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+//openSession - Replays testdata/session.jsonl, recorded while a finger was identified at position 5 and
+//another one stored at position 7
+func openSession(t *testing.T) ScannerIO {
+	f, err := os.Open("testdata/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := NewReplay(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestReplaySession(t *testing.T) {
+	s := openSession(t)
+	if err := s.Capture(); err != nil {
+		t.Fatal(err)
+	}
+	if s.ReadImage() {
+		t.Error("finger on the sensor before it was placed")
+	}
+	if !s.ReadImage() || !s.ConvertImage(FINGERPRINT_CHARBUFFER1) {
+		t.Fatal("finger not captured")
+	}
+	result, err := s.SearchTemplate(FINGERPRINT_CHARBUFFER1, 0, -1)
+	if err != nil || result.PositionNumber != 5 || result.AccuracyScore != 80 {
+		t.Fatalf("first search = %+v, %v", result, err)
+	}
+
+	if !s.ReadImage() || !s.ConvertImage(FINGERPRINT_CHARBUFFER1) {
+		t.Fatal("second finger not captured")
+	}
+	result, err = s.SearchTemplate(FINGERPRINT_CHARBUFFER1, 0, -1)
+	if err != nil || result.PositionNumber != -1 {
+		t.Fatalf("second search = %+v, %v", result, err)
+	}
+	if err = s.CreateTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	position, err := s.StoreTemplate(7, FINGERPRINT_CHARBUFFER1)
+	if err != nil || position != 7 {
+		t.Fatalf("StoreTemplate = %d, %v", position, err)
+	}
+
+	if _, err = s.GetSystemParameters(); !errors.Is(err, ErrReplayEnd) || !errors.Is(err, ErrLinkDown) {
+		t.Errorf("command after the end of the session returned %v", err)
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	s := openSession(t)
+	if err := s.Capture(); err != nil {
+		t.Fatal(err)
+	}
+	//The session continues with ReadImage
+	err := s.ClearDatabase()
+	if err == nil || !strings.HasPrefix(err.Error(), "replay diverged at record 5: expected tx ef01ffffffff01000301") {
+		t.Errorf("ClearDatabase = %v", err)
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	f, err := os.Open("testdata/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := ReadTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 22 {
+		t.Fatalf("%d records", len(records))
+	}
+	search := records[11].Packets
+	if len(search) != 1 || search[0].Type != "ack" || *search[0].Confirmation != FINGERPRINT_OK || search[0].Payload != "0000050050" {
+		t.Errorf("search response %+v", search)
+	}
+
+	for _, bad := range []string{`{"dir":"up","data":""}`, `{"dir":"tx","data":"zz"}`, `{"dir":`} {
+		if _, err = ReadTrace(strings.NewReader(bad)); err == nil || !strings.HasPrefix(err.Error(), "trace line 1: ") {
+			t.Errorf("%s: %v", bad, err)
+		}
+	}
+}
+
+//TestTraceReplaysLiveSession - A session recorded from a bridge plays back
+func TestTraceReplaysLiveSession(t *testing.T) {
+	b := startBridge(t, true)
+	var trace bytes.Buffer
+	s := b.connect(t, WithTrace(&trace))
+	if err := s.CreateTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(trace.String(), `"instruction":5`) || !strings.Contains(trace.String(), `"confirmation":0`) {
+		t.Errorf("packets not decoded in the trace:\n%s", trace.String())
+	}
+
+	r, err := NewReplay(&trace, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Capture(); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.CreateTemplate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,233 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+//ErrNoMatch - The presented finger does not match any template
+var ErrNoMatch = errors.New("fingerprint does not match")
+
+//ErrAlreadyEnrolled - The presented finger is already stored in the library
+var ErrAlreadyEnrolled = errors.New("fingerprint already enrolled")
+
+//EnrollStep - Stages reported while enrolling a finger
+type EnrollStep int
+
+const (
+	//EnrollWaitFirstFinger - Waiting for the finger to be placed
+	EnrollWaitFirstFinger EnrollStep = iota
+	//EnrollCheckDuplicate - Searching the library for the finger
+	EnrollCheckDuplicate
+	//EnrollRemoveFinger - Waiting for the finger to be lifted
+	EnrollRemoveFinger
+	//EnrollWaitSecondFinger - Waiting for the same finger to be placed again
+	EnrollWaitSecondFinger
+	//EnrollCompare - Comparing both captures
+	EnrollCompare
+	//EnrollStore - Creating and storing the template
+	EnrollStore
+	//EnrollDone - Template stored
+	EnrollDone
+	//EnrollPoorQuality - A capture was rejected by EnrollWithQuality, the finger is asked for again
+	EnrollPoorQuality
+)
+
+var enrollStepNames = map[EnrollStep]string{
+	EnrollWaitFirstFinger:  "wait_first_finger",
+	EnrollCheckDuplicate:   "check_duplicate",
+	EnrollRemoveFinger:     "remove_finger",
+	EnrollWaitSecondFinger: "wait_second_finger",
+	EnrollCompare:          "compare",
+	EnrollStore:            "store",
+	EnrollDone:             "done",
+	EnrollPoorQuality:      "poor_quality",
+}
+
+func (e EnrollStep) String() string {
+	if name, ok := enrollStepNames[e]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+//waitForFinger - Polls ReadImage until a finger is on the sensor and converts it into the char buffer
+func waitForFinger(ctx context.Context, s ScannerIO, charBufferNo int) error {
+	for s.ReadImage() == false {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if s.ConvertImage(charBufferNo) == false {
+		return errors.New("unable to convert the image")
+	}
+	return nil
+}
+
+//waitForRelease - Polls ReadImage until the finger is lifted
+func waitForRelease(ctx context.Context, s ScannerIO) error {
+	for s.ReadImage() == true {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Identify - Captures a finger and searches the whole library for it, on the module itself if it implements AutoIdentify
+func Identify(ctx context.Context, s ScannerIO) (*SearchResult, error) {
+	if a, ok := s.(AutoEnroller); ok {
+		result, err := a.AutoIdentify(ctx, nil)
+		if err != ErrNotSupported {
+			observeIdentification(s, result, err)
+			return result, err
+		}
+	}
+
+	if err := waitForFinger(ctx, s, FINGERPRINT_CHARBUFFER1); err != nil {
+		return nil, err
+	}
+	result, err := s.SearchTemplate(FINGERPRINT_CHARBUFFER1, 0, -1)
+	observeIdentification(s, result, err)
+	if err != nil {
+		return nil, err
+	}
+	if result.PositionNumber < 0 {
+		return result, ErrNoMatch
+	}
+	return result, nil
+}
+
+//Verify - Captures a finger and compares it with the template at the given position, returns the accuracy score
+func Verify(ctx context.Context, s ScannerIO, position int) (int, error) {
+	if err := waitForFinger(ctx, s, FINGERPRINT_CHARBUFFER1); err != nil {
+		return 0, err
+	}
+	if err := s.LoadTemplate(position, FINGERPRINT_CHARBUFFER2); err != nil {
+		return 0, err
+	}
+	score, err := s.CompareCharacteristics()
+	if err == nil && score == 0 {
+		err = ErrNoMatch
+	}
+	observeIdentification(s, &SearchResult{PositionNumber: position, AccuracyScore: score}, err)
+	if err != nil {
+		return 0, err
+	}
+	return score, nil
+}
+
+//Enroll - Captures the same finger twice and stores the template, position -1 picks a free one. A given position
+//holding a template returns ErrSlotOccupied before a finger is asked for.
+//progress is optional and receives every step as it starts. Modules implementing AutoEnroll run the steps on their own.
+func Enroll(ctx context.Context, s ScannerIO, position int, progress func(EnrollStep)) (int, error) {
+	if position >= 0 {
+		index, err := s.TemplateIndex()
+		if err != nil {
+			return -1, err
+		}
+		if position < len(index) && index[position] {
+			return -1, fmt.Errorf("%w: %d", ErrSlotOccupied, position)
+		}
+	}
+	if a, ok := s.(AutoEnroller); ok {
+		enrolled, err := a.AutoEnroll(ctx, position, progress)
+		if err != ErrNotSupported {
+			return enrolled, err
+		}
+	}
+
+	report := func(step EnrollStep) {
+		if progress != nil {
+			progress(step)
+		}
+	}
+	return enroll(ctx, s, position, report, waitForFinger)
+}
+
+//enroll - Host driven steps of Enroll, capture waits for a finger and converts it into the char buffer
+func enroll(ctx context.Context, s ScannerIO, position int, report func(EnrollStep), capture func(context.Context, ScannerIO, int) error) (int, error) {
+	report(EnrollWaitFirstFinger)
+	if err := capture(ctx, s, FINGERPRINT_CHARBUFFER1); err != nil {
+		return -1, err
+	}
+
+	report(EnrollCheckDuplicate)
+	result, err := s.SearchTemplate(FINGERPRINT_CHARBUFFER1, 0, -1)
+	if err != nil {
+		return -1, err
+	}
+	if result.PositionNumber >= 0 {
+		return result.PositionNumber, ErrAlreadyEnrolled
+	}
+
+	report(EnrollRemoveFinger)
+	if err = waitForRelease(ctx, s); err != nil {
+		return -1, err
+	}
+
+	report(EnrollWaitSecondFinger)
+	if err = capture(ctx, s, FINGERPRINT_CHARBUFFER2); err != nil {
+		return -1, err
+	}
+
+	report(EnrollCompare)
+	score, err := s.CompareCharacteristics()
+	if err != nil {
+		return -1, err
+	}
+	if score == 0 {
+		return -1, ErrNoMatch
+	}
+
+	report(EnrollStore)
+	if err = s.CreateTemplate(); err != nil {
+		return -1, err
+	}
+	if position, err = s.StoreTemplate(position, FINGERPRINT_CHARBUFFER1); err != nil {
+		return -1, err
+	}
+
+	report(EnrollDone)
+	return position, nil
+}
+
+//ExportTemplate - Reads the template stored at the given position to the host
+func ExportTemplate(s ScannerIO, position int) ([]byte, error) {
+	if err := s.LoadTemplate(position, FINGERPRINT_CHARBUFFER1); err != nil {
+		return nil, err
+	}
+	return s.DownloadCharacteristics(FINGERPRINT_CHARBUFFER1)
+}
+
+//ImportTemplate - Writes a template previously read by ExportTemplate to the given position
+func ImportTemplate(s ScannerIO, position int, data []byte) error {
+	if err := s.UploadCharacteristics(FINGERPRINT_CHARBUFFER1, data); err != nil {
+		return err
+	}
+	_, err := s.StoreTemplate(position, FINGERPRINT_CHARBUFFER1)
+	return err
+}
+
+//ConvertArchivedImage - Sends an image read earlier with DownloadImage to the sensor and extracts its
+//characteristics into the given char buffer, as if the finger had been placed again
+func ConvertArchivedImage(s ScannerIO, image []byte, charBufferNo int) error {
+	if err := s.UploadImage(image); err != nil {
+		return err
+	}
+	if s.ConvertImage(charBufferNo) == false {
+		return errors.New("unable to convert the image")
+	}
+	return nil
+}
+
+//CaptureImage - Waits for a finger and reads the raw image to the host
+func CaptureImage(ctx context.Context, s ScannerIO) ([]byte, error) {
+	for s.ReadImage() == false {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return s.DownloadImage()
+}